@@ -0,0 +1,616 @@
+// config/config.go
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server settings. Values are resolved from, in increasing
+// order of precedence: built-in defaults, the YAML config file, NAAS_*
+// environment variables and command line flags.
+type Config struct {
+	Server  Server  `yaml:"server"`
+	CORS    CORS    `yaml:"cors"`
+	Storage Storage `yaml:"storage"`
+	Auth    Auth    `yaml:"auth"`
+	Log     Log     `yaml:"log"`
+	Tracing Tracing `yaml:"tracing"`
+
+	Kubernetes Kubernetes `yaml:"kubernetes"`
+	Network    Network    `yaml:"network"`
+	Deletion   Deletion   `yaml:"deletion"`
+	Expiry     Expiry     `yaml:"expiry"`
+	Drift      Drift      `yaml:"drift"`
+	GitOps     GitOps     `yaml:"gitops"`
+
+	Idempotency Idempotency `yaml:"idempotency"`
+
+	// PrintConfig makes the server print the effective configuration and exit.
+	PrintConfig bool `yaml:"-"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr"`
+	TLS  TLS    `yaml:"tls"`
+
+	ReadTimeout       Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      Duration `yaml:"writeTimeout"`
+	IdleTimeout       Duration `yaml:"idleTimeout"`
+	// RequestTimeout is the deadline set on each request's context, and so
+	// on the service and storage operations it performs. Zero disables it.
+	RequestTimeout Duration `yaml:"requestTimeout"`
+	// ShutdownDelay is how long requests are still served after SIGTERM,
+	// while readiness already fails, so that load balancers stop sending
+	// traffic before the listener closes. It should cover the readiness
+	// probe period.
+	ShutdownDelay Duration `yaml:"shutdownDelay"`
+	// ShutdownTimeout bounds how long in-flight requests are drained after
+	// SIGTERM before remaining connections are closed. Background work and
+	// shutdown hooks share what is left of it.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout"`
+}
+
+type TLS struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type CORS struct {
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+type Storage struct {
+	Backend string `yaml:"backend"`
+}
+
+type Auth struct {
+	Tokens []string `yaml:"tokens"`
+}
+
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+type Tracing struct {
+	// Exporter is one of none, stdout or otlp.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector host:port. When empty the
+	// standard OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+type Kubernetes struct {
+	// Mode selects how the cluster is reached: none disables all cluster
+	// side effects, incluster uses the pod's service account and kubeconfig
+	// reads Kubeconfig (or the default loading rules when empty).
+	Mode       string `yaml:"mode"`
+	Kubeconfig string `yaml:"kubeconfig"`
+	Context    string `yaml:"context"`
+	// ResyncInterval is how often every namespace is reconciled again to
+	// retry failures and correct drift.
+	ResyncInterval Duration `yaml:"resyncInterval"`
+}
+
+type Network struct {
+	// Isolation gives every namespace default network policies that deny
+	// ingress from other tenants.
+	Isolation bool `yaml:"isolation"`
+	// IngressNamespace is where the ingress controller runs. Its traffic is
+	// allowed into every namespace; empty allows none.
+	IngressNamespace string `yaml:"ingressNamespace"`
+	// DNSNamespace is where the cluster DNS runs, reachable from namespaces
+	// that isolate their egress.
+	DNSNamespace string `yaml:"dnsNamespace"`
+}
+
+type Deletion struct {
+	// Retention is how long deleted tenants and namespaces can be restored
+	// before they are purged for good.
+	Retention Duration `yaml:"retention"`
+	// PurgeInterval is how often expired records are looked for.
+	PurgeInterval Duration `yaml:"purgeInterval"`
+}
+
+type Expiry struct {
+	// ReapInterval is how often namespaces are checked for an expired lease.
+	ReapInterval Duration `yaml:"reapInterval"`
+	// WarningPeriod is how long before its expiry a namespace is warned
+	// about.
+	WarningPeriod Duration `yaml:"warningPeriod"`
+}
+
+type Drift struct {
+	// ScanInterval is how often namespaces are compared with the cluster.
+	ScanInterval Duration `yaml:"scanInterval"`
+	// Remediate reconciles the namespaces found drifted right away instead
+	// of waiting for the next resync.
+	Remediate bool `yaml:"remediate"`
+}
+
+type GitOps struct {
+	// Repository is a local Git working tree the manifests of every tenant
+	// are committed to, for Argo CD or Flux to apply. Empty disables it.
+	Repository  string `yaml:"repository"`
+	AuthorName  string `yaml:"authorName"`
+	AuthorEmail string `yaml:"authorEmail"`
+}
+
+type Idempotency struct {
+	// Window is how long the response to a request with an Idempotency-Key
+	// header is replayed to retries. Zero disables idempotency keys.
+	Window Duration `yaml:"window"`
+	// MaxKeys is the most keys remembered at once. The oldest responses
+	// are forgotten early to make room for new keys.
+	MaxKeys int `yaml:"maxKeys"`
+}
+
+// Duration is a time.Duration written as a Go duration string ("30s") in YAML.
+type Duration time.Duration
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) set(v string) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+const (
+	StorageMemory = "memory"
+
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+
+	KubernetesNone       = "none"
+	KubernetesInCluster  = "incluster"
+	KubernetesKubeconfig = "kubeconfig"
+)
+
+var (
+	storageBackends  = []string{StorageMemory}
+	logLevels        = []string{"debug", "info", "warn", "error"}
+	tracingExporters = []string{TracingNone, TracingStdout, TracingOTLP}
+	kubernetesModes  = []string{KubernetesNone, KubernetesInCluster, KubernetesKubeconfig}
+)
+
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:              ":8082",
+			ReadTimeout:       Duration(15 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			RequestTimeout:    Duration(10 * time.Second),
+			ShutdownDelay:     Duration(5 * time.Second),
+			ShutdownTimeout:   Duration(25 * time.Second),
+		},
+		Storage: Storage{Backend: StorageMemory},
+		Log:     Log{Level: "info"},
+		Tracing: Tracing{Exporter: TracingNone, SampleRatio: 1},
+		Kubernetes: Kubernetes{
+			Mode:           KubernetesNone,
+			ResyncInterval: Duration(5 * time.Minute),
+		},
+		Network: Network{
+			Isolation:        true,
+			IngressNamespace: "ingress-nginx",
+			DNSNamespace:     "kube-system",
+		},
+		Deletion: Deletion{
+			Retention:     Duration(72 * time.Hour),
+			PurgeInterval: Duration(10 * time.Minute),
+		},
+		Expiry: Expiry{
+			ReapInterval:  Duration(time.Minute),
+			WarningPeriod: Duration(time.Hour),
+		},
+		Drift: Drift{
+			ScanInterval: Duration(10 * time.Minute),
+		},
+		GitOps: GitOps{
+			AuthorName:  "naas",
+			AuthorEmail: "naas@localhost",
+		},
+		Idempotency: Idempotency{
+			Window:  Duration(24 * time.Hour),
+			MaxKeys: 10000,
+		},
+	}
+}
+
+// setting binds a single value to its flag and environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+var settings = []setting{
+	{"listen-addr", "NAAS_LISTEN_ADDR", "address the HTTP server listens on", func(cfg *Config, v string) error {
+		cfg.Server.Addr = v
+		return nil
+	}},
+	{"tls-cert-file", "NAAS_TLS_CERT_FILE", "TLS certificate file", func(cfg *Config, v string) error {
+		cfg.Server.TLS.CertFile = v
+		return nil
+	}},
+	{"tls-key-file", "NAAS_TLS_KEY_FILE", "TLS private key file", func(cfg *Config, v string) error {
+		cfg.Server.TLS.KeyFile = v
+		return nil
+	}},
+	{"read-timeout", "NAAS_READ_TIMEOUT", "maximum duration for reading a request", func(cfg *Config, v string) error {
+		return cfg.Server.ReadTimeout.set(v)
+	}},
+	{"read-header-timeout", "NAAS_READ_HEADER_TIMEOUT", "maximum duration for reading request headers", func(cfg *Config, v string) error {
+		return cfg.Server.ReadHeaderTimeout.set(v)
+	}},
+	{"write-timeout", "NAAS_WRITE_TIMEOUT", "maximum duration for writing a response", func(cfg *Config, v string) error {
+		return cfg.Server.WriteTimeout.set(v)
+	}},
+	{"idle-timeout", "NAAS_IDLE_TIMEOUT", "maximum time to keep idle keep-alive connections open", func(cfg *Config, v string) error {
+		return cfg.Server.IdleTimeout.set(v)
+	}},
+	{"request-timeout", "NAAS_REQUEST_TIMEOUT", "deadline for the operations performed by a request, 0 to disable", func(cfg *Config, v string) error {
+		return cfg.Server.RequestTimeout.set(v)
+	}},
+	{"shutdown-delay", "NAAS_SHUTDOWN_DELAY", "time requests are still served on shutdown while readiness fails", func(cfg *Config, v string) error {
+		return cfg.Server.ShutdownDelay.set(v)
+	}},
+	{"shutdown-timeout", "NAAS_SHUTDOWN_TIMEOUT", "time allowed for draining connections on shutdown", func(cfg *Config, v string) error {
+		return cfg.Server.ShutdownTimeout.set(v)
+	}},
+	{"cors-allowed-origins", "NAAS_CORS_ALLOWED_ORIGINS", "comma-separated list of allowed CORS origins, * for any", func(cfg *Config, v string) error {
+		cfg.CORS.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{"storage-backend", "NAAS_STORAGE_BACKEND", "storage backend (memory)", func(cfg *Config, v string) error {
+		cfg.Storage.Backend = v
+		return nil
+	}},
+	{"auth-tokens", "NAAS_AUTH_TOKENS", "comma-separated list of accepted bearer tokens, empty disables auth", func(cfg *Config, v string) error {
+		cfg.Auth.Tokens = splitList(v)
+		return nil
+	}},
+	{"log-level", "NAAS_LOG_LEVEL", "log level (debug, info, warn, error)", func(cfg *Config, v string) error {
+		cfg.Log.Level = v
+		return nil
+	}},
+	{"tracing-exporter", "NAAS_TRACING_EXPORTER", "trace exporter (none, stdout, otlp)", func(cfg *Config, v string) error {
+		cfg.Tracing.Exporter = v
+		return nil
+	}},
+	{"tracing-endpoint", "NAAS_TRACING_ENDPOINT", "OTLP/HTTP collector endpoint (host:port)", func(cfg *Config, v string) error {
+		cfg.Tracing.Endpoint = v
+		return nil
+	}},
+	{"tracing-insecure", "NAAS_TRACING_INSECURE", "send OTLP traces without TLS", func(cfg *Config, v string) error {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		cfg.Tracing.Insecure = insecure
+		return nil
+	}},
+	{"tracing-sample-ratio", "NAAS_TRACING_SAMPLE_RATIO", "fraction of new traces to sample, between 0 and 1", func(cfg *Config, v string) error {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		cfg.Tracing.SampleRatio = ratio
+		return nil
+	}},
+	{"kubernetes-mode", "NAAS_KUBERNETES_MODE", "how to reach the cluster (none, incluster, kubeconfig)", func(cfg *Config, v string) error {
+		cfg.Kubernetes.Mode = v
+		return nil
+	}},
+	{"kubeconfig", "NAAS_KUBECONFIG", "kubeconfig file used in kubeconfig mode", func(cfg *Config, v string) error {
+		cfg.Kubernetes.Kubeconfig = v
+		return nil
+	}},
+	{"kubernetes-context", "NAAS_KUBERNETES_CONTEXT", "kubeconfig context used in kubeconfig mode", func(cfg *Config, v string) error {
+		cfg.Kubernetes.Context = v
+		return nil
+	}},
+	{"resync-interval", "NAAS_RESYNC_INTERVAL", "interval between full reconciliations of the cluster", func(cfg *Config, v string) error {
+		return cfg.Kubernetes.ResyncInterval.set(v)
+	}},
+	{"network-isolation", "NAAS_NETWORK_ISOLATION", "generate default network policies isolating tenants", func(cfg *Config, v string) error {
+		isolation, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		cfg.Network.Isolation = isolation
+		return nil
+	}},
+	{"ingress-namespace", "NAAS_INGRESS_NAMESPACE", "namespace of the ingress controller, allowed into every namespace", func(cfg *Config, v string) error {
+		cfg.Network.IngressNamespace = v
+		return nil
+	}},
+	{"dns-namespace", "NAAS_DNS_NAMESPACE", "namespace of the cluster DNS", func(cfg *Config, v string) error {
+		cfg.Network.DNSNamespace = v
+		return nil
+	}},
+	{"deletion-retention", "NAAS_DELETION_RETENTION", "how long deleted tenants and namespaces can be restored", func(cfg *Config, v string) error {
+		return cfg.Deletion.Retention.set(v)
+	}},
+	{"purge-interval", "NAAS_PURGE_INTERVAL", "interval between purges of expired deleted records", func(cfg *Config, v string) error {
+		return cfg.Deletion.PurgeInterval.set(v)
+	}},
+	{"reap-interval", "NAAS_REAP_INTERVAL", "interval between checks for expired namespaces", func(cfg *Config, v string) error {
+		return cfg.Expiry.ReapInterval.set(v)
+	}},
+	{"expiry-warning-period", "NAAS_EXPIRY_WARNING_PERIOD", "how long before expiry a namespace is warned about", func(cfg *Config, v string) error {
+		return cfg.Expiry.WarningPeriod.set(v)
+	}},
+	{"drift-scan-interval", "NAAS_DRIFT_SCAN_INTERVAL", "interval between drift scans of the cluster", func(cfg *Config, v string) error {
+		return cfg.Drift.ScanInterval.set(v)
+	}},
+	{"drift-remediate", "NAAS_DRIFT_REMEDIATE", "reconcile drifted namespaces as soon as they are found", func(cfg *Config, v string) error {
+		remediate, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		cfg.Drift.Remediate = remediate
+		return nil
+	}},
+	{"gitops-repository", "NAAS_GITOPS_REPOSITORY", "git working tree to commit rendered manifests to", func(cfg *Config, v string) error {
+		cfg.GitOps.Repository = v
+		return nil
+	}},
+	{"gitops-author-name", "NAAS_GITOPS_AUTHOR_NAME", "author name of manifest commits", func(cfg *Config, v string) error {
+		cfg.GitOps.AuthorName = v
+		return nil
+	}},
+	{"gitops-author-email", "NAAS_GITOPS_AUTHOR_EMAIL", "author email of manifest commits", func(cfg *Config, v string) error {
+		cfg.GitOps.AuthorEmail = v
+		return nil
+	}},
+	{"idempotency-window", "NAAS_IDEMPOTENCY_WINDOW", "how long responses are replayed for retries with the same Idempotency-Key (0 disables)", func(cfg *Config, v string) error {
+		return cfg.Idempotency.Window.set(v)
+	}},
+	{"idempotency-max-keys", "NAAS_IDEMPOTENCY_MAX_KEYS", "most Idempotency-Keys remembered at once", func(cfg *Config, v string) error {
+		maxKeys, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		cfg.Idempotency.MaxKeys = maxKeys
+		return nil
+	}},
+}
+
+// Load resolves the configuration from args (without the program name) and
+// the environment, and validates the result.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	fs := flag.NewFlagSet("naas", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML config file (env NAAS_CONFIG)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("NAAS_CONFIG")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok {
+			if err := s.apply(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.apply(cfg, *values[s.flag]); err != nil {
+					flagErr = fmt.Errorf("--%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	cfg.PrintConfig = *printConfig
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("server.addr %q: %v", c.Server.Addr, err))
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"readTimeout", c.Server.ReadTimeout},
+		{"readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"writeTimeout", c.Server.WriteTimeout},
+		{"idleTimeout", c.Server.IdleTimeout},
+		{"requestTimeout", c.Server.RequestTimeout},
+		{"shutdownDelay", c.Server.ShutdownDelay},
+		{"shutdownTimeout", c.Server.ShutdownTimeout},
+	} {
+		if d.value < 0 {
+			problems = append(problems, fmt.Sprintf("server.%s: must not be negative", d.name))
+		}
+	}
+	if c.Server.TLS.Enabled() && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		problems = append(problems, "server.tls requires both certFile and keyFile")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("cors.allowedOrigins: invalid origin %q", origin))
+		}
+	}
+	if !contains(storageBackends, c.Storage.Backend) {
+		problems = append(problems, fmt.Sprintf("storage.backend %q: must be one of %s", c.Storage.Backend, strings.Join(storageBackends, ", ")))
+	}
+	for _, token := range c.Auth.Tokens {
+		if token == "" {
+			problems = append(problems, "auth.tokens: empty token")
+			break
+		}
+	}
+	if !contains(logLevels, c.Log.Level) {
+		problems = append(problems, fmt.Sprintf("log.level %q: must be one of %s", c.Log.Level, strings.Join(logLevels, ", ")))
+	}
+	if !contains(tracingExporters, c.Tracing.Exporter) {
+		problems = append(problems, fmt.Sprintf("tracing.exporter %q: must be one of %s", c.Tracing.Exporter, strings.Join(tracingExporters, ", ")))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sampleRatio: must be between 0 and 1")
+	}
+	if !contains(kubernetesModes, c.Kubernetes.Mode) {
+		problems = append(problems, fmt.Sprintf("kubernetes.mode %q: must be one of %s", c.Kubernetes.Mode, strings.Join(kubernetesModes, ", ")))
+	}
+	if c.Kubernetes.ResyncInterval <= 0 {
+		problems = append(problems, "kubernetes.resyncInterval: must be positive")
+	}
+	if c.Network.Isolation && c.Network.DNSNamespace == "" {
+		problems = append(problems, "network.dnsNamespace: required when network.isolation is enabled")
+	}
+	if c.Deletion.Retention < 0 {
+		problems = append(problems, "deletion.retention: must not be negative")
+	}
+	if c.Deletion.PurgeInterval <= 0 {
+		problems = append(problems, "deletion.purgeInterval: must be positive")
+	}
+	if c.Expiry.ReapInterval <= 0 {
+		problems = append(problems, "expiry.reapInterval: must be positive")
+	}
+	if c.Expiry.WarningPeriod < 0 {
+		problems = append(problems, "expiry.warningPeriod: must not be negative")
+	}
+	if c.Drift.ScanInterval <= 0 {
+		problems = append(problems, "drift.scanInterval: must be positive")
+	}
+	if c.GitOps.Repository != "" && (c.GitOps.AuthorName == "" || c.GitOps.AuthorEmail == "") {
+		problems = append(problems, "gitops: authorName and authorEmail are required with a repository")
+	}
+	if c.Idempotency.Window < 0 {
+		problems = append(problems, "idempotency.window: must not be negative")
+	}
+	if c.Idempotency.MaxKeys <= 0 {
+		problems = append(problems, "idempotency.maxKeys: must be positive")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Print writes the configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	redacted := *c
+	redacted.Auth.Tokens = make([]string, len(c.Auth.Tokens))
+	for i := range redacted.Auth.Tokens {
+		redacted.Auth.Tokens[i] = "<redacted>"
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&redacted); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func splitList(v string) []string {
+	var result []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
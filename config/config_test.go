@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/config"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "naas.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+cors:
+  allowedOrigins: ["https://file.example.com"]
+log:
+  level: warn
+`)
+
+	cfg, err := config.Load([]string{"--config", path}, env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, []string{"https://file.example.com"}, cfg.CORS.AllowedOrigins)
+	assert.Equal(t, "warn", cfg.Log.Level)
+
+	cfg, err = config.Load([]string{"--config", path}, env(map[string]string{
+		"NAAS_LISTEN_ADDR": ":9001",
+		"NAAS_LOG_LEVEL":   "error",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, ":9001", cfg.Server.Addr)
+	assert.Equal(t, "error", cfg.Log.Level)
+
+	cfg, err = config.Load([]string{"--listen-addr", ":9002"}, env(map[string]string{
+		"NAAS_CONFIG":      path,
+		"NAAS_LISTEN_ADDR": ":9001",
+		"NAAS_AUTH_TOKENS": "a, b",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, ":9002", cfg.Server.Addr)
+	assert.Equal(t, "warn", cfg.Log.Level)
+	assert.Equal(t, []string{"a", "b"}, cfg.Auth.Tokens)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := config.Load([]string{"--storage-backend", "etcd", "--log-level", "loud"}, env(nil))
+	assert.ErrorContains(t, err, `storage.backend "etcd"`)
+	assert.ErrorContains(t, err, `log.level "loud"`)
+
+	_, err = config.Load([]string{"--tls-cert-file", "cert.pem"}, env(nil))
+	assert.ErrorContains(t, err, "server.tls requires both certFile and keyFile")
+
+	_, err = config.Load(nil, env(map[string]string{"NAAS_CORS_ALLOWED_ORIGINS": "example.com"}))
+	assert.ErrorContains(t, err, `invalid origin "example.com"`)
+
+	_, err = config.Load([]string{"--config", writeFile(t, "unknown: true\n")}, env(nil))
+	assert.ErrorContains(t, err, "field unknown not found")
+
+	_, err = config.Load([]string{"--deletion-retention", "-1h", "--purge-interval", "0s"}, env(nil))
+	assert.ErrorContains(t, err, "deletion.retention: must not be negative")
+	assert.ErrorContains(t, err, "deletion.purgeInterval: must be positive")
+
+	_, err = config.Load(nil, env(map[string]string{"NAAS_REAP_INTERVAL": "0s"}))
+	assert.ErrorContains(t, err, "expiry.reapInterval: must be positive")
+
+	_, err = config.Load([]string{"--drift-scan-interval", "0s"}, env(nil))
+	assert.ErrorContains(t, err, "drift.scanInterval: must be positive")
+
+	_, err = config.Load([]string{"--gitops-repository", "/srv/manifests", "--gitops-author-email", ""}, env(nil))
+	assert.ErrorContains(t, err, "gitops: authorName and authorEmail are required")
+
+	_, err = config.Load([]string{"--idempotency-window", "-1h"}, env(nil))
+	assert.ErrorContains(t, err, "idempotency.window: must not be negative")
+	_, err = config.Load(nil, env(map[string]string{"NAAS_IDEMPOTENCY_MAX_KEYS": "0"}))
+	assert.ErrorContains(t, err, "idempotency.maxKeys: must be positive")
+
+	_, err = config.Load([]string{"--dns-namespace", ""}, env(nil))
+	assert.ErrorContains(t, err, "network.dnsNamespace: required")
+	_, err = config.Load([]string{"--dns-namespace", "", "--network-isolation", "false"}, env(nil))
+	assert.NoError(t, err)
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg, err := config.Load([]string{"--print-config", "--auth-tokens", "secret"}, env(nil))
+	assert.NoError(t, err)
+	assert.True(t, cfg.PrintConfig)
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.Print(&out))
+	assert.NotContains(t, out.String(), "secret")
+	assert.Contains(t, out.String(), "addr: :8082")
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Adoption brings namespaces that exist in a cluster but aren't managed by
+// naas under its management, without recreating them. Each namespace goes
+// to the tenant of the first rule matching its labels.
+type Adoption struct {
+	// Cluster is the registered cluster to adopt from; empty is the cluster
+	// naas runs with.
+	Cluster string         `json:"cluster,omitempty"`
+	Rules   []AdoptionRule `json:"rules"`
+	// Isolation is given to the adopted namespaces. Without it they are
+	// adopted with isolation disabled, so that the default policies don't
+	// cut off traffic their running workloads rely on.
+	Isolation *NetworkIsolation `json:"isolation,omitempty"`
+	// DryRun reports what would be adopted without changing anything.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// AdoptionRule maps the namespaces matching Selector, all when empty, to
+// Tenant, or to the tenant named by their TenantLabel label.
+type AdoptionRule struct {
+	Selector    map[string]string `json:"selector,omitempty"`
+	Tenant      string            `json:"tenant,omitempty"`
+	TenantLabel string            `json:"tenantLabel,omitempty"`
+}
+
+// DiscoveredNamespace is a namespace found in a cluster that naas doesn't
+// manage.
+type DiscoveredNamespace struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// ManagedBy is the tool managing the namespace, if any.
+	ManagedBy   string `json:"managedBy,omitempty"`
+	Terminating bool   `json:"terminating,omitempty"`
+}
+
+// AdoptionReport lists what an adoption did, or would do on a dry run.
+type AdoptionReport struct {
+	Cluster string             `json:"cluster,omitempty"`
+	DryRun  bool               `json:"dryRun"`
+	Adopted []AdoptedNamespace `json:"adopted"`
+	Skipped []SkippedNamespace `json:"skipped"`
+}
+
+type AdoptedNamespace struct {
+	Namespace string `json:"namespace"`
+	TenantID  string `json:"tenantId"`
+	// Rule is the index of the rule that matched.
+	Rule int `json:"rule"`
+}
+
+type SkippedNamespace struct {
+	Namespace string `json:"namespace"`
+	Reason    string `json:"reason"`
+}
+
+// Validate reports every problem of the adoption in a single error.
+func (a *Adoption) Validate() error {
+	var problems []string
+
+	if len(a.Rules) == 0 {
+		problems = append(problems, "rules: required")
+	}
+	for i, r := range a.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if (r.Tenant == "") == (r.TenantLabel == "") {
+			problems = append(problems, field+": exactly one of tenant or tenantLabel is required")
+		}
+		for _, k := range sortedKeys(r.Selector) {
+			if k == "" {
+				problems = append(problems, field+".selector: empty key")
+			}
+		}
+	}
+	if a.Isolation != nil {
+		if err := a.Isolation.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// TenantFor returns the tenant a namespace with the given labels goes to,
+// and the index of the rule that decided it. Rules naming a tenant label
+// the namespace doesn't have don't match.
+func (a *Adoption) TenantFor(labels map[string]string) (tenantID string, rule int, ok bool) {
+	for i, r := range a.Rules {
+		if !matches(r.Selector, labels) {
+			continue
+		}
+		if r.Tenant != "" {
+			return r.Tenant, i, true
+		}
+		if v := labels[r.TenantLabel]; v != "" {
+			return v, i, true
+		}
+	}
+	return "", 0, false
+}
+
+// SystemNamespace reports whether a namespace belongs to Kubernetes itself,
+// which is never adopted.
+func SystemNamespace(name string) bool {
+	return name == "default" || strings.HasPrefix(name, "kube-")
+}
+
+func matches(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestAdoption(t *testing.T) {
+	adoption := &domain.Adoption{Rules: []domain.AdoptionRule{
+		{Selector: map[string]string{"team": "payments"}, Tenant: "acme"},
+		{TenantLabel: "owner"},
+	}}
+	assert.NoError(t, adoption.Validate())
+
+	tenantID, rule, ok := adoption.TenantFor(map[string]string{"team": "payments", "owner": "other"})
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenantID)
+	assert.Equal(t, 0, rule)
+
+	tenantID, rule, ok = adoption.TenantFor(map[string]string{"owner": "globex"})
+	assert.True(t, ok)
+	assert.Equal(t, "globex", tenantID)
+	assert.Equal(t, 1, rule)
+
+	_, _, ok = adoption.TenantFor(map[string]string{"team": "billing"})
+	assert.False(t, ok)
+
+	err := (&domain.Adoption{}).Validate()
+	assert.ErrorContains(t, err, "rules: required")
+	err = (&domain.Adoption{Rules: []domain.AdoptionRule{{}, {Tenant: "acme", TenantLabel: "owner"}}}).Validate()
+	assert.ErrorContains(t, err, "rules[0]: exactly one of tenant or tenantLabel is required")
+	assert.ErrorContains(t, err, "rules[1]: exactly one of tenant or tenantLabel is required")
+
+	assert.True(t, domain.SystemNamespace("kube-system"))
+	assert.True(t, domain.SystemNamespace("default"))
+	assert.False(t, domain.SystemNamespace("payments"))
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxBulkOperations is the most operations a single bulk request may hold.
+const MaxBulkOperations = 500
+
+// BulkMode decides what happens to a bulk request when one of its
+// operations fails.
+type BulkMode string
+
+const (
+	// BulkAllOrNothing stops at the first failure and undoes the operations
+	// applied before it.
+	BulkAllOrNothing BulkMode = "allOrNothing"
+	// BulkBestEffort applies every operation it can; each one that fails is
+	// undone on its own.
+	BulkBestEffort BulkMode = "bestEffort"
+)
+
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+// Bulk is a batch of changes to tenants and namespaces, applied in order.
+type Bulk struct {
+	// Mode defaults to BulkAllOrNothing.
+	Mode       BulkMode        `json:"mode,omitempty"`
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkOperation changes either a tenant or a namespace of TenantID. Deletes
+// only need the tenant's ID or the namespace's name.
+type BulkOperation struct {
+	Action    BulkAction `json:"action"`
+	Tenant    *Tenant    `json:"tenant,omitempty"`
+	TenantID  string     `json:"tenantId,omitempty"`
+	Namespace *Namespace `json:"namespace,omitempty"`
+}
+
+type BulkStatus string
+
+const (
+	BulkSucceeded BulkStatus = "succeeded"
+	BulkFailed    BulkStatus = "failed"
+	// BulkRolledBack operations succeeded but were undone because a later
+	// one failed.
+	BulkRolledBack BulkStatus = "rolledBack"
+	// BulkSkipped operations weren't attempted because an earlier one
+	// failed.
+	BulkSkipped BulkStatus = "skipped"
+)
+
+// BulkReport gives the outcome of every operation of a bulk request, in
+// order.
+type BulkReport struct {
+	Mode BulkMode `json:"mode"`
+	// Committed is false when an all-or-nothing request was undone.
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
+
+type BulkResult struct {
+	Status BulkStatus `json:"status"`
+	// Err is why the operation failed.
+	Err       error      `json:"-"`
+	Error     string     `json:"error,omitempty"`
+	Tenant    *Tenant    `json:"tenant,omitempty"`
+	Namespace *Namespace `json:"namespace,omitempty"`
+}
+
+// Validate reports every problem of the bulk request in a single error.
+func (b *Bulk) Validate() error {
+	var problems []string
+
+	switch b.Mode {
+	case "", BulkAllOrNothing, BulkBestEffort:
+	default:
+		problems = append(problems, fmt.Sprintf("mode: must be %s or %s", BulkAllOrNothing, BulkBestEffort))
+	}
+	if len(b.Operations) == 0 {
+		problems = append(problems, "operations: required")
+	}
+	if len(b.Operations) > MaxBulkOperations {
+		problems = append(problems, fmt.Sprintf("operations: at most %d are allowed", MaxBulkOperations))
+	}
+	for i, op := range b.Operations {
+		field := fmt.Sprintf("operations[%d]", i)
+		switch op.Action {
+		case BulkCreate, BulkUpdate, BulkDelete:
+		default:
+			problems = append(problems, field+".action: must be create, update or delete")
+		}
+		switch {
+		case (op.Tenant == nil) == (op.Namespace == nil):
+			problems = append(problems, field+": exactly one of tenant or namespace is required")
+		case op.Tenant != nil && op.Tenant.ID == "":
+			problems = append(problems, field+".tenant.id: required")
+		case op.Namespace != nil && op.TenantID == "":
+			problems = append(problems, field+".tenantId: required")
+		case op.Namespace != nil && op.Namespace.Name == "":
+			problems = append(problems, field+".namespace.name: required")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestBulk_Validate(t *testing.T) {
+	valid := domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: domain.BulkCreate, Tenant: &domain.Tenant{ID: "acme"}},
+		{Action: domain.BulkDelete, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+	}}
+	assert.NoError(t, valid.Validate())
+
+	err := (&domain.Bulk{Mode: "sometimes"}).Validate()
+	assert.ErrorContains(t, err, "mode: must be allOrNothing or bestEffort")
+	assert.ErrorContains(t, err, "operations: required")
+
+	err = (&domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: "rename", Tenant: &domain.Tenant{ID: "acme"}},
+		{Action: domain.BulkCreate},
+		{Action: domain.BulkCreate, Tenant: &domain.Tenant{}},
+		{Action: domain.BulkCreate, Namespace: &domain.Namespace{Name: "team-a"}},
+		{Action: domain.BulkUpdate, TenantID: "acme", Namespace: &domain.Namespace{}},
+	}}).Validate()
+	assert.EqualError(t, err, "operations[0].action: must be create, update or delete; "+
+		"operations[1]: exactly one of tenant or namespace is required; "+
+		"operations[2].tenant.id: required; "+
+		"operations[3].tenantId: required; "+
+		"operations[4].namespace.name: required")
+
+	err = (&domain.Bulk{Operations: make([]domain.BulkOperation, domain.MaxBulkOperations+1)}).Validate()
+	assert.ErrorContains(t, err, "operations: at most 500 are allowed")
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Cluster is a Kubernetes cluster namespaces can be placed on.
+type Cluster struct {
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	// Kubeconfig is the path of the kubeconfig file naas reaches the
+	// cluster with, e.g. a mounted secret, and Context the context to use
+	// from it. The current context is used when empty.
+	Kubeconfig string `json:"kubeconfig"`
+	Context    string `json:"context,omitempty"`
+	// GitOps marks a cluster that Argo CD or Flux applies from the GitOps
+	// repository. naas then only exports the manifests of its namespaces
+	// and never connects to it, so Kubeconfig isn't needed.
+	GitOps bool `json:"gitops,omitempty"`
+	// Capacity is the most namespaces the cluster takes; zero is unlimited.
+	Capacity int               `json:"capacity,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// Allocated is the number of namespaces placed on the cluster. It is
+	// computed when the cluster is read and ignored on writes.
+	Allocated int `json:"allocated"`
+}
+
+// Validate reports every problem of the cluster in a single error.
+func (c *Cluster) Validate() error {
+	var problems []string
+
+	for _, msg := range validation.IsDNS1123Label(c.Name) {
+		problems = append(problems, fmt.Sprintf("name %q: %s", c.Name, msg))
+	}
+	if c.Kubeconfig == "" && !c.GitOps {
+		problems = append(problems, "kubeconfig: required")
+	}
+	if c.Capacity < 0 {
+		problems = append(problems, "capacity: must not be negative")
+	}
+	problems = append(problems, validateLabels("labels", c.Labels)...)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Full reports whether the cluster can't take another namespace.
+func (c *Cluster) Full() bool {
+	return c.Capacity > 0 && c.Allocated >= c.Capacity
+}
+
+// Placement constrains the clusters a namespace may be placed on.
+type Placement struct {
+	Region          string            `json:"region,omitempty"`
+	ClusterSelector map[string]string `json:"clusterSelector,omitempty"`
+}
+
+// Matches reports whether cluster c satisfies the placement. A nil
+// placement matches every cluster.
+func (p *Placement) Matches(c Cluster) bool {
+	if p == nil {
+		return true
+	}
+	if p.Region != "" && p.Region != c.Region {
+		return false
+	}
+	for k, v := range p.ClusterSelector {
+		if c.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Schedule picks the cluster for a new namespace among clusters, whose
+// Allocated counts must be set. tenantAllocated counts the namespaces the
+// tenant already has on each cluster. Clusters that don't match the
+// placement or are full are skipped; of the others, clusters hosting the
+// tenant are preferred, then the least allocated, then the first by name.
+func Schedule(clusters []Cluster, placement *Placement, tenantAllocated map[string]int) (*Cluster, bool) {
+	var candidates []Cluster
+	for _, c := range clusters {
+		if placement.Matches(c) && !c.Full() {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if affineA, affineB := tenantAllocated[a.Name] > 0, tenantAllocated[b.Name] > 0; affineA != affineB {
+			return affineA
+		}
+		if a.Allocated != b.Allocated {
+			return a.Allocated < b.Allocated
+		}
+		return a.Name < b.Name
+	})
+	return &candidates[0], true
+}
@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestCluster_Validate(t *testing.T) {
+	assert.NoError(t, (&domain.Cluster{Name: "east-1", Kubeconfig: "/etc/naas/east-1"}).Validate())
+	// GitOps clusters are never connected to
+	assert.NoError(t, (&domain.Cluster{Name: "edge", GitOps: true}).Validate())
+
+	err := (&domain.Cluster{Name: "East", Capacity: -1, Labels: map[string]string{"naas.io/x": "y"}}).Validate()
+	assert.ErrorContains(t, err, `name "East"`)
+	assert.ErrorContains(t, err, "kubeconfig: required")
+	assert.ErrorContains(t, err, "capacity: must not be negative")
+	assert.ErrorContains(t, err, "is reserved")
+}
+
+func TestSchedule(t *testing.T) {
+	clusters := []domain.Cluster{
+		{Name: "east-1", Region: "east", Allocated: 3, Labels: map[string]string{"gpu": "true"}},
+		{Name: "east-2", Region: "east", Allocated: 1},
+		{Name: "west-1", Region: "west", Allocated: 1, Capacity: 1},
+		{Name: "west-2", Region: "west", Allocated: 1},
+	}
+
+	schedule := func(placement *domain.Placement, tenantAllocated map[string]int) string {
+		cluster, ok := domain.Schedule(clusters, placement, tenantAllocated)
+		if !ok {
+			return ""
+		}
+		return cluster.Name
+	}
+
+	// Least allocated, then by name; full clusters are skipped
+	assert.Equal(t, "east-2", schedule(nil, nil))
+	assert.Equal(t, "west-2", schedule(&domain.Placement{Region: "west"}, nil))
+	assert.Equal(t, "east-1", schedule(&domain.Placement{ClusterSelector: map[string]string{"gpu": "true"}}, nil))
+	// Clusters hosting the tenant win over less allocated ones
+	assert.Equal(t, "east-1", schedule(nil, map[string]int{"east-1": 2}))
+	assert.Equal(t, "east-2", schedule(&domain.Placement{Region: "east"}, map[string]int{"west-2": 1}))
+
+	assert.Equal(t, "", schedule(&domain.Placement{Region: "north"}, nil))
+	assert.Equal(t, "", schedule(&domain.Placement{Region: "west", ClusterSelector: map[string]string{"gpu": "true"}}, nil))
+}
@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// Kinds of objects a difference can be about.
+const (
+	DriftNamespace     = "Namespace"
+	DriftNetworkPolicy = "NetworkPolicy"
+	DriftResourceQuota = "ResourceQuota"
+	DriftRole          = "Role"
+	DriftRoleBinding   = "RoleBinding"
+)
+
+// DriftChange tells how the live object differs from the desired one.
+type DriftChange string
+
+const (
+	// DriftMissing objects are desired but absent from the cluster.
+	DriftMissing DriftChange = "missing"
+	// DriftModified objects exist but differ from the desired ones.
+	DriftModified DriftChange = "modified"
+	// DriftUnexpected objects are managed by naas but not desired.
+	DriftUnexpected DriftChange = "unexpected"
+)
+
+// Difference is one way a namespace in the cluster departs from its
+// record.
+type Difference struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Change DriftChange `json:"change"`
+	// Detail says what was modified, e.g. which label.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Drift reports how a namespace in the cluster departs from its record. It
+// has no differences when the two agree.
+type Drift struct {
+	TenantID    string       `json:"tenantId"`
+	Namespace   string       `json:"namespace"`
+	Cluster     string       `json:"cluster,omitempty"`
+	Differences []Difference `json:"differences"`
+	DetectedAt  time.Time    `json:"detectedAt"`
+	// Remediated is set when the namespace was reconciled after the drift
+	// was detected.
+	Remediated bool `json:"remediated,omitempty"`
+}
+
+func (d *Drift) Drifted() bool {
+	return len(d.Differences) > 0
+}
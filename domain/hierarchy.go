@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Hierarchy indexes the namespaces of one tenant by name to navigate the
+// tree formed by their parent references.
+type Hierarchy map[string]Namespace
+
+func NewHierarchy(namespaces []Namespace) Hierarchy {
+	h := make(Hierarchy, len(namespaces))
+	for _, ns := range namespaces {
+		h[ns.Name] = ns
+	}
+	return h
+}
+
+// Ancestors returns the ancestors of the named namespace, nearest first.
+// The chain ends at a root, at a parent missing from the hierarchy, or
+// before a namespace would repeat.
+func (h Hierarchy) Ancestors(name string) []Namespace {
+	var ancestors []Namespace
+	seen := map[string]bool{name: true}
+	for parent := h[name].Parent; parent != "" && !seen[parent]; {
+		ns, ok := h[parent]
+		if !ok {
+			break
+		}
+		seen[parent] = true
+		ancestors = append(ancestors, ns)
+		parent = ns.Parent
+	}
+	return ancestors
+}
+
+// WouldCycle reports whether making parent the parent of the named
+// namespace would create a cycle, i.e. whether parent is the namespace
+// itself or one of its descendants.
+func (h Hierarchy) WouldCycle(name, parent string) bool {
+	if parent == name {
+		return true
+	}
+	for _, ancestor := range h.Ancestors(parent) {
+		if ancestor.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Children returns the direct children of the named namespace, sorted by
+// name.
+func (h Hierarchy) Children(name string) []Namespace {
+	var children []Namespace
+	for _, ns := range h {
+		if ns.Parent == name && ns.Name != name {
+			children = append(children, ns)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children
+}
+
+// Subtree returns the named namespace followed by its descendants, depth
+// first with siblings sorted by name.
+func (h Hierarchy) Subtree(name string) []Namespace {
+	root, ok := h[name]
+	if !ok {
+		return nil
+	}
+	subtree := []Namespace{root}
+	seen := map[string]bool{name: true}
+	var walk func(string)
+	walk = func(parent string) {
+		for _, child := range h.Children(parent) {
+			if seen[child.Name] {
+				continue
+			}
+			seen[child.Name] = true
+			subtree = append(subtree, child)
+			walk(child.Name)
+		}
+	}
+	walk(name)
+	return subtree
+}
+
+// EffectiveSpec returns the spec of the named namespace with the specs of
+// its ancestors merged underneath, the nearest ancestor taking precedence
+// over those above it and the namespace over all of them.
+func (h Hierarchy) EffectiveSpec(name string) NamespaceSpec {
+	ancestors := h.Ancestors(name)
+	var spec NamespaceSpec
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		spec = spec.Merge(ancestors[i].NamespaceSpec)
+	}
+	return spec.Merge(h[name].NamespaceSpec)
+}
+
+// QuotaUsage sums the quota hard limits of the effective specs of the
+// namespaces per resource. Limits that aren't valid quantities are skipped.
+func (h Hierarchy) QuotaUsage() map[string]resource.Quantity {
+	used := make(map[string]resource.Quantity)
+	for name := range h {
+		for resourceName, limit := range h.EffectiveSpec(name).Quota {
+			quantity, err := resource.ParseQuantity(limit)
+			if err != nil {
+				continue
+			}
+			sum := used[resourceName]
+			sum.Add(quantity)
+			used[resourceName] = sum
+		}
+	}
+	return used
+}
@@ -0,0 +1,56 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestHierarchy(t *testing.T) {
+	h := domain.NewHierarchy([]domain.Namespace{
+		{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+			Labels: map[string]string{"org": "acme", "tier": "bronze"},
+			Quota:  map[string]string{"pods": "100"},
+		}},
+		{Name: "team-b", Parent: "org"},
+		{Name: "team-a", Parent: "org", NamespaceSpec: domain.NamespaceSpec{
+			Labels: map[string]string{"tier": "silver"},
+			RoleBindings: []domain.RoleBinding{
+				{Name: "devs", Role: "edit", Subjects: []domain.Subject{{Kind: "Group", Name: "team-a"}}},
+			},
+		}},
+		{Name: "service", Parent: "team-a", NamespaceSpec: domain.NamespaceSpec{
+			Quota: map[string]string{"pods": "10"},
+		}},
+		{Name: "orphan", Parent: "missing"},
+	})
+
+	var names []string
+	for _, ns := range h.Subtree("org") {
+		names = append(names, ns.Name)
+	}
+	assert.Equal(t, []string{"org", "team-a", "service", "team-b"}, names)
+	assert.Nil(t, h.Subtree("missing"))
+
+	names = nil
+	for _, ns := range h.Ancestors("service") {
+		names = append(names, ns.Name)
+	}
+	assert.Equal(t, []string{"team-a", "org"}, names)
+	assert.Empty(t, h.Ancestors("orphan"))
+
+	assert.True(t, h.WouldCycle("org", "service"))
+	assert.True(t, h.WouldCycle("team-a", "team-a"))
+	assert.False(t, h.WouldCycle("team-b", "service"))
+
+	spec := h.EffectiveSpec("service")
+	assert.Equal(t, map[string]string{"org": "acme", "tier": "silver"}, spec.Labels)
+	assert.Equal(t, map[string]string{"pods": "10"}, spec.Quota)
+	assert.Len(t, spec.RoleBindings, 1)
+	assert.Equal(t, map[string]string{"pods": "100"}, h.EffectiveSpec("team-b").Quota)
+
+	// Inherited limits count for every namespace that inherits them
+	pods := h.QuotaUsage()["pods"]
+	assert.Equal(t, int64(310), pods.Value())
+}
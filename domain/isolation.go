@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// NetworkIsolation overrides the default network policies of a namespace.
+// By default a namespace only accepts traffic from the namespaces of its
+// own tenant and from the ingress controller.
+type NetworkIsolation struct {
+	// Disabled drops the default policies, opening the namespace to all
+	// traffic. The namespace's own policies still apply.
+	Disabled bool `json:"disabled,omitempty"`
+	// AllowTenants opens the namespace to the namespaces of other tenants.
+	AllowTenants []string `json:"allowTenants,omitempty"`
+	// DenyIngressController closes the namespace to the ingress controller,
+	// for namespaces that aren't exposed.
+	DenyIngressController bool `json:"denyIngressController,omitempty"`
+	// DenyEgress isolates egress too: pods may then only reach the
+	// namespaces of their tenant and the cluster DNS.
+	DenyEgress bool `json:"denyEgress,omitempty"`
+}
+
+// Validate reports every problem of the overrides in a single error.
+func (i *NetworkIsolation) Validate() error {
+	var problems []string
+
+	seen := make(map[string]bool)
+	for j, tenantID := range i.AllowTenants {
+		field := fmt.Sprintf("isolation.allowTenants[%d]", j)
+		if tenantID == "" {
+			problems = append(problems, field+": required")
+		}
+		// Tenants are matched by the tenant label of their namespaces.
+		for _, msg := range validation.IsValidLabelValue(tenantID) {
+			problems = append(problems, fmt.Sprintf("%s %q: %s", field, tenantID, msg))
+		}
+		if seen[tenantID] {
+			problems = append(problems, fmt.Sprintf("%s %q: duplicate", field, tenantID))
+		}
+		seen[tenantID] = true
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
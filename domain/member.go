@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemberRole is the access a member has to every namespace of a tenant.
+type MemberRole string
+
+const (
+	MemberAdmin     MemberRole = "admin"
+	MemberDeveloper MemberRole = "developer"
+	MemberViewer    MemberRole = "viewer"
+)
+
+// MemberRoles lists the roles from most to least privileged.
+var MemberRoles = []MemberRole{MemberAdmin, MemberDeveloper, MemberViewer}
+
+func (r MemberRole) Valid() bool {
+	for _, role := range MemberRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleName names the Role that grants r in the tenant's namespaces.
+func (r MemberRole) RoleName() string {
+	return ReservedPrefix + "-tenant-" + string(r)
+}
+
+// Member is a user with access to the namespaces of a tenant.
+type Member struct {
+	User string     `json:"user"`
+	Role MemberRole `json:"role"`
+}
+
+// ValidateMembers reports every problem of a member list in a single error.
+func ValidateMembers(members []Member) error {
+	var problems []string
+
+	seen := make(map[string]bool)
+	for i, m := range members {
+		field := fmt.Sprintf("members[%d]", i)
+		if m.User == "" {
+			problems = append(problems, field+".user: required")
+		}
+		if seen[m.User] {
+			problems = append(problems, fmt.Sprintf("%s.user %q: duplicate", field, m.User))
+		}
+		seen[m.User] = true
+		if !m.Role.Valid() {
+			problems = append(problems, fmt.Sprintf("%s.role %q: must be admin, developer or viewer", field, m.Role))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Where an effective binding comes from.
+const (
+	BindingSourceMembership = "membership"
+	BindingSourceSpec       = "spec"
+)
+
+// Binding is a role binding in effect in a namespace.
+type Binding struct {
+	Name string `json:"name"`
+	// RoleKind is Role for the roles naas generates from membership, and
+	// ClusterRole for the bindings of the namespace's spec.
+	RoleKind string    `json:"roleKind"`
+	Role     string    `json:"role"`
+	Subjects []Subject `json:"subjects"`
+	Source   string    `json:"source"`
+}
+
+// EffectiveBindings lists the role bindings granted in namespace ns: one
+// per member role in use in tenant, followed by those of the namespace's
+// spec. tenant may be nil when the tenant has no record.
+func EffectiveBindings(tenant *Tenant, ns *Namespace) []Binding {
+	var bindings []Binding
+	if tenant != nil {
+		for _, role := range MemberRoles {
+			var subjects []Subject
+			for _, m := range tenant.Members {
+				if m.Role == role {
+					subjects = append(subjects, Subject{Kind: "User", Name: m.User})
+				}
+			}
+			if len(subjects) == 0 {
+				continue
+			}
+			bindings = append(bindings, Binding{
+				Name:     role.RoleName(),
+				RoleKind: "Role",
+				Role:     role.RoleName(),
+				Subjects: subjects,
+				Source:   BindingSourceMembership,
+			})
+		}
+	}
+
+	for _, b := range ns.RoleBindings {
+		subjects := make([]Subject, len(b.Subjects))
+		for i, s := range b.Subjects {
+			if s.Kind == "ServiceAccount" && s.Namespace == "" {
+				s.Namespace = ns.Name
+			}
+			subjects[i] = s
+		}
+		bindings = append(bindings, Binding{
+			Name:     b.Name,
+			RoleKind: "ClusterRole",
+			Role:     b.Role,
+			Subjects: subjects,
+			Source:   BindingSourceSpec,
+		})
+	}
+	return bindings
+}
@@ -1,6 +1,127 @@
 package domain
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
 type Namespace struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// ResourceVersion changes with every write of the namespace. Updates
+	// that carry one only apply if the namespace hasn't changed since.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Parent names another namespace of the tenant whose spec this one
+	// inherits and may override.
+	Parent string `json:"parent,omitempty"`
+	// Template names the template the namespace was created from, and
+	// Parameters the values it was instantiated with.
+	Template   string            `json:"template,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	NamespaceSpec
+	// Cluster names the registered cluster the namespace is placed on, or is
+	// empty for the cluster naas runs with. It is chosen by Placement when
+	// not given.
+	Cluster   string     `json:"cluster,omitempty"`
+	Placement *Placement `json:"placement,omitempty"`
+	// Isolation overrides the default network policies; nil keeps them.
+	Isolation *NetworkIsolation `json:"isolation,omitempty"`
+	// ExpiresAt makes the namespace ephemeral: it is deleted once the time
+	// has passed unless its lease is renewed.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// DeletionTimestamp is set when the namespace is deleted. It can be
+	// restored until the retention period has passed.
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	// Finalizers name the cleanup steps that must complete before a deleted
+	// namespace is removed for good.
+	Finalizers []string `json:"finalizers,omitempty"`
+	// Transfers records the moves of the namespace between tenants, oldest
+	// first. naas sets it; it is ignored in requests.
+	Transfers []Transfer `json:"transfers,omitempty"`
+	// Conditions report the state of the namespace in its cluster. naas sets
+	// them; they are ignored in requests.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ConditionType names an aspect of a namespace's state.
+type ConditionType string
+
+// ConditionSynced is true when the namespace was last applied to its
+// cluster without error.
+const ConditionSynced ConditionType = "Synced"
+
+// Condition is an observation about a namespace.
+type Condition struct {
+	Type   ConditionType `json:"type"`
+	Status bool          `json:"status"`
+	// Message says why the condition is false.
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is when Status last changed.
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// Transfer is a move of a namespace from one tenant to another.
+type Transfer struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// ValidateNamespaceName checks that name can name a Kubernetes namespace.
+// Names also become directories of exported manifests, so this is what keeps
+// them inside the export.
+func ValidateNamespaceName(name string) error {
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		return fmt.Errorf("name %q: %s", name, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func (n *Namespace) Deleted() bool {
+	return n.DeletionTimestamp != nil
+}
+
+// FormerTenants returns the tenants the namespace was transferred from.
+func (n *Namespace) FormerTenants() []string {
+	var tenants []string
+	for _, t := range n.Transfers {
+		tenants = append(tenants, t.From)
+	}
+	return tenants
+}
+
+// Condition returns the condition of type t, or nil if it isn't set.
+func (n *Namespace) Condition(t ConditionType) *Condition {
+	for i := range n.Conditions {
+		if n.Conditions[i].Type == t {
+			return &n.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition records condition, replacing the one of the same type. The
+// transition time is kept while the status stays the same. It reports
+// whether the conditions changed.
+func (n *Namespace) SetCondition(condition Condition) bool {
+	conditions := make([]Condition, 0, len(n.Conditions)+1)
+	for _, c := range n.Conditions {
+		if c.Type != condition.Type {
+			conditions = append(conditions, c)
+			continue
+		}
+		if c.Status == condition.Status {
+			if c.Message == condition.Message {
+				return false
+			}
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+	// The conditions are copied rather than changed in place, so copies of
+	// the namespace keep theirs.
+	n.Conditions = append(conditions, condition)
+	return true
 }
@@ -0,0 +1,30 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestNamespace_SetCondition(t *testing.T) {
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+	ns := domain.Namespace{Name: "team-a"}
+	assert.Nil(t, ns.Condition(domain.ConditionSynced))
+
+	assert.True(t, ns.SetCondition(domain.Condition{Type: domain.ConditionSynced, Status: true, LastTransitionTime: first}))
+	assert.False(t, ns.SetCondition(domain.Condition{Type: domain.ConditionSynced, Status: true, LastTransitionTime: later}))
+	assert.Equal(t, first, ns.Condition(domain.ConditionSynced).LastTransitionTime)
+
+	copied := ns
+	assert.True(t, ns.SetCondition(domain.Condition{Type: domain.ConditionSynced, Message: "unreachable", LastTransitionTime: later}))
+	assert.Equal(t, []domain.Condition{{Type: domain.ConditionSynced, Message: "unreachable", LastTransitionTime: later}}, ns.Conditions)
+	assert.True(t, copied.Condition(domain.ConditionSynced).Status)
+
+	// A new message alone is no transition
+	assert.True(t, ns.SetCondition(domain.Condition{Type: domain.ConditionSynced, Message: "timeout", LastTransitionTime: later.Add(time.Hour)}))
+	assert.Equal(t, later, ns.Condition(domain.ConditionSynced).LastTransitionTime)
+	assert.Equal(t, "timeout", ns.Condition(domain.ConditionSynced).Message)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// PatchType is the media type of a patch document.
+type PatchType string
+
+const (
+	// MergePatch is a JSON merge patch (RFC 7386): the document is merged
+	// into the object and null values remove fields.
+	MergePatch PatchType = "application/merge-patch+json"
+	// JSONPatch is a JSON patch (RFC 6902): a list of operations applied in
+	// order, all of which must succeed.
+	JSONPatch PatchType = "application/json-patch+json"
+)
+
+// Patch is a partial change to a tenant or namespace.
+type Patch struct {
+	Type PatchType
+	Data []byte
+	// ResourceVersion, when set, is the version of the object the patch was
+	// written against. The patch then only applies if the object is still at
+	// it.
+	ResourceVersion string
+}
+
+// Apply returns the JSON document doc with the patch applied.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	switch p.Type {
+	case MergePatch:
+		return jsonpatch.MergePatch(doc, p.Data)
+	case JSONPatch:
+		patch, err := jsonpatch.DecodePatch(p.Data)
+		if err != nil {
+			return nil, err
+		}
+		return patch.Apply(doc)
+	default:
+		return nil, fmt.Errorf("unsupported patch type %q", p.Type)
+	}
+}
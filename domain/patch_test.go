@@ -0,0 +1,30 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func TestPatch_Apply(t *testing.T) {
+	doc := []byte(`{"name":"team-a","labels":{"tier":"gold","team":"payments"}}`)
+
+	result, err := domain.Patch{Type: domain.MergePatch, Data: []byte(`{"labels":{"tier":null,"env":"prod"}}`)}.Apply(doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"team-a","labels":{"team":"payments","env":"prod"}}`, string(result))
+
+	result, err = domain.Patch{Type: domain.JSONPatch, Data: []byte(`[
+		{"op":"test","path":"/labels/tier","value":"gold"},
+		{"op":"replace","path":"/labels/tier","value":"silver"}
+	]`)}.Apply(doc)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"team-a","labels":{"tier":"silver","team":"payments"}}`, string(result))
+
+	_, err = domain.Patch{Type: domain.JSONPatch, Data: []byte(`[{"op":"test","path":"/labels/tier","value":"bronze"}]`)}.Apply(doc)
+	assert.Error(t, err)
+	_, err = domain.Patch{Type: domain.JSONPatch, Data: []byte(`{"labels":{}}`)}.Apply(doc)
+	assert.Error(t, err)
+	_, err = domain.Patch{Type: "application/json", Data: []byte(`{}`)}.Apply(doc)
+	assert.EqualError(t, err, `unsupported patch type "application/json"`)
+}
@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ReservedPrefix is reserved for the labels, annotations and object names
+// naas manages itself.
+const ReservedPrefix = "naas"
+
+// NamespaceSpec is the configuration applied to a namespace in the cluster.
+// Templates carry one to stamp it onto the namespaces created from them.
+type NamespaceSpec struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Quota holds the hard limits of the namespace's resource quota, keyed
+	// by resource name, e.g. "requests.cpu": "4".
+	Quota           map[string]string `json:"quota,omitempty"`
+	NetworkPolicies []NetworkPolicy   `json:"networkPolicies,omitempty"`
+	RoleBindings    []RoleBinding     `json:"roleBindings,omitempty"`
+}
+
+// NetworkPolicy allows ingress to the pods matching PodSelector (all pods
+// when empty) from the given peers on the given ports.
+type NetworkPolicy struct {
+	Name        string            `json:"name"`
+	PodSelector map[string]string `json:"podSelector,omitempty"`
+	From        []PolicyPeer      `json:"from,omitempty"`
+	Ports       []PolicyPort      `json:"ports,omitempty"`
+}
+
+// PolicyPeer selects traffic sources: pods matching PodSelector in the
+// namespaces matching NamespaceSelector, or the addresses in CIDR.
+type PolicyPeer struct {
+	NamespaceSelector map[string]string `json:"namespaceSelector,omitempty"`
+	PodSelector       map[string]string `json:"podSelector,omitempty"`
+	CIDR              string            `json:"cidr,omitempty"`
+}
+
+type PolicyPort struct {
+	// Protocol is TCP, UDP or SCTP. It defaults to TCP.
+	Protocol string `json:"protocol,omitempty"`
+	Port     int32  `json:"port"`
+}
+
+// RoleBinding grants a cluster role to subjects within the namespace.
+type RoleBinding struct {
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	Subjects []Subject `json:"subjects"`
+}
+
+type Subject struct {
+	// Kind is User, Group or ServiceAccount.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Namespace of a ServiceAccount. It defaults to the bound namespace.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Validate reports every problem of the spec in a single error.
+func (s *NamespaceSpec) Validate() error {
+	var problems []string
+
+	problems = append(problems, validateLabels("labels", s.Labels)...)
+	problems = append(problems, validateAnnotations(s.Annotations)...)
+
+	problems = append(problems, validateQuota(s.Quota)...)
+
+	names := make(map[string]bool)
+	for i, p := range s.NetworkPolicies {
+		field := fmt.Sprintf("networkPolicies[%d]", i)
+		problems = append(problems, validateObjectName(field, p.Name, names)...)
+		problems = append(problems, validateLabels(field+".podSelector", p.PodSelector)...)
+		for j, peer := range p.From {
+			peerField := fmt.Sprintf("%s.from[%d]", field, j)
+			if peer.CIDR == "" && peer.NamespaceSelector == nil && peer.PodSelector == nil {
+				problems = append(problems, peerField+": one of namespaceSelector, podSelector or cidr is required")
+			}
+			if peer.CIDR != "" {
+				if _, _, err := net.ParseCIDR(peer.CIDR); err != nil {
+					problems = append(problems, fmt.Sprintf("%s.cidr: invalid CIDR %q", peerField, peer.CIDR))
+				}
+				if peer.NamespaceSelector != nil || peer.PodSelector != nil {
+					problems = append(problems, peerField+": cidr can't be combined with selectors")
+				}
+			}
+			problems = append(problems, validateLabels(peerField+".namespaceSelector", peer.NamespaceSelector)...)
+			problems = append(problems, validateLabels(peerField+".podSelector", peer.PodSelector)...)
+		}
+		for j, port := range p.Ports {
+			portField := fmt.Sprintf("%s.ports[%d]", field, j)
+			switch port.Protocol {
+			case "", "TCP", "UDP", "SCTP":
+			default:
+				problems = append(problems, fmt.Sprintf("%s.protocol: must be TCP, UDP or SCTP", portField))
+			}
+			if port.Port < 1 || port.Port > 65535 {
+				problems = append(problems, fmt.Sprintf("%s.port: must be between 1 and 65535", portField))
+			}
+		}
+	}
+
+	names = make(map[string]bool)
+	for i, b := range s.RoleBindings {
+		field := fmt.Sprintf("roleBindings[%d]", i)
+		problems = append(problems, validateObjectName(field, b.Name, names)...)
+		if b.Role == "" {
+			problems = append(problems, field+".role: required")
+		}
+		if len(b.Subjects) == 0 {
+			problems = append(problems, field+".subjects: required")
+		}
+		for j, subject := range b.Subjects {
+			subjectField := fmt.Sprintf("%s.subjects[%d]", field, j)
+			switch subject.Kind {
+			case "User", "Group", "ServiceAccount":
+			default:
+				problems = append(problems, subjectField+".kind: must be User, Group or ServiceAccount")
+			}
+			if subject.Name == "" {
+				problems = append(problems, subjectField+".name: required")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateQuota reports every problem of the hard limits of a quota in a
+// single error.
+func ValidateQuota(quota map[string]string) error {
+	if problems := validateQuota(quota); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validateQuota(quota map[string]string) []string {
+	var problems []string
+	for _, name := range sortedKeys(quota) {
+		quantity := quota[name]
+		if name == "" {
+			problems = append(problems, "quota: empty resource name")
+		} else if _, err := resource.ParseQuantity(quantity); err != nil {
+			problems = append(problems, fmt.Sprintf("quota %s: invalid quantity %q", name, quantity))
+		}
+	}
+	return problems
+}
+
+func validateLabels(field string, labels map[string]string) []string {
+	var problems []string
+	for _, k := range sortedKeys(labels) {
+		v := labels[k]
+		if reserved(k) {
+			problems = append(problems, fmt.Sprintf("%s: key %q is reserved", field, k))
+			continue
+		}
+		for _, msg := range validation.IsQualifiedName(k) {
+			problems = append(problems, fmt.Sprintf("%s: key %q: %s", field, k, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(v) {
+			problems = append(problems, fmt.Sprintf("%s: value %q: %s", field, v, msg))
+		}
+	}
+	return problems
+}
+
+func validateAnnotations(annotations map[string]string) []string {
+	var problems []string
+	for _, k := range sortedKeys(annotations) {
+		if reserved(k) {
+			problems = append(problems, fmt.Sprintf("annotations: key %q is reserved", k))
+			continue
+		}
+		for _, msg := range validation.IsQualifiedName(k) {
+			problems = append(problems, fmt.Sprintf("annotations: key %q: %s", k, msg))
+		}
+	}
+	return problems
+}
+
+func validateObjectName(field, name string, seen map[string]bool) []string {
+	var problems []string
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		problems = append(problems, fmt.Sprintf("%s.name %q: %s", field, name, msg))
+	}
+	if strings.HasPrefix(name, ReservedPrefix+"-") {
+		problems = append(problems, fmt.Sprintf("%s.name %q: the %s- prefix is reserved", field, name, ReservedPrefix))
+	}
+	if seen[name] {
+		problems = append(problems, fmt.Sprintf("%s.name %q: duplicate", field, name))
+	}
+	seen[name] = true
+	return problems
+}
+
+// reserved reports whether a label or annotation key belongs to naas.
+func reserved(key string) bool {
+	prefix, _, found := strings.Cut(key, "/")
+	return found && (prefix == ReservedPrefix+".io" || strings.HasSuffix(prefix, "."+ReservedPrefix+".io")) ||
+		key == "app.kubernetes.io/managed-by"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
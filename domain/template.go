@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Parameters every template can reference without declaring them.
+const (
+	ParamTenant    = "tenant"
+	ParamNamespace = "namespace"
+)
+
+// Template is a blueprint namespaces are created from. Its spec may
+// reference parameters as ${name} in any string, keys included. Templates
+// with a TenantID are private to that tenant; the others are global.
+type Template struct {
+	Name        string              `json:"name"`
+	TenantID    string              `json:"tenantId,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []TemplateParameter `json:"parameters,omitempty"`
+	Spec        NamespaceSpec       `json:"spec"`
+}
+
+type TemplateParameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Default is used when the parameter isn't given. Parameters without a
+	// default must be given when Required is set, and are empty otherwise.
+	Default  string `json:"default,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+var (
+	paramName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	paramReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+)
+
+// Validate checks the template's name, parameter declarations and that the
+// spec only references declared parameters. The spec itself is validated
+// once instantiated, when the parameter values are known.
+func (t *Template) Validate() error {
+	var problems []string
+
+	for _, msg := range validation.IsDNS1123Label(t.Name) {
+		problems = append(problems, fmt.Sprintf("name %q: %s", t.Name, msg))
+	}
+
+	declared := map[string]bool{ParamTenant: true, ParamNamespace: true}
+	for i, p := range t.Parameters {
+		switch {
+		case !paramName.MatchString(p.Name):
+			problems = append(problems, fmt.Sprintf("parameters[%d].name %q: must be a letter or underscore followed by letters, digits or underscores", i, p.Name))
+		case declared[p.Name]:
+			problems = append(problems, fmt.Sprintf("parameters[%d].name %q: duplicate or built-in", i, p.Name))
+		}
+		declared[p.Name] = true
+	}
+
+	spec, err := json.Marshal(t.Spec)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references(string(spec)) {
+		if !declared[ref] {
+			problems = append(problems, fmt.Sprintf("spec: undeclared parameter ${%s}", ref))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Instantiate substitutes the parameters into the template's spec and
+// validates the result. tenantID and namespace fill the built-in
+// parameters.
+func (t *Template) Instantiate(tenantID, namespace string, params map[string]string) (*NamespaceSpec, error) {
+	values := map[string]string{ParamTenant: tenantID, ParamNamespace: namespace}
+	known := map[string]bool{}
+	var problems []string
+	for _, p := range t.Parameters {
+		known[p.Name] = true
+		v, ok := params[p.Name]
+		switch {
+		case ok:
+			values[p.Name] = v
+		case p.Default != "":
+			values[p.Name] = p.Default
+		case p.Required:
+			problems = append(problems, fmt.Sprintf("parameter %s is required", p.Name))
+		default:
+			values[p.Name] = ""
+		}
+	}
+	for _, name := range sortedKeys(params) {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s", name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	raw, err := json.Marshal(t.Spec)
+	if err != nil {
+		return nil, err
+	}
+	// Values are substituted into the JSON encoding of the spec, escaped so
+	// that they can't change its structure.
+	substituted := paramReference.ReplaceAllStringFunc(string(raw), func(ref string) string {
+		escaped, _ := json.Marshal(values[ref[2:len(ref)-1]])
+		return string(escaped[1 : len(escaped)-1])
+	})
+
+	var spec NamespaceSpec
+	if err := json.Unmarshal([]byte(substituted), &spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Merge returns the spec with override applied on top: labels, annotations
+// and quota are merged key by key, and network policies and role bindings
+// replace those of the same name.
+func (s NamespaceSpec) Merge(override NamespaceSpec) NamespaceSpec {
+	return NamespaceSpec{
+		Labels:          mergeMaps(s.Labels, override.Labels),
+		Annotations:     mergeMaps(s.Annotations, override.Annotations),
+		Quota:           mergeMaps(s.Quota, override.Quota),
+		NetworkPolicies: mergeNamed(s.NetworkPolicies, override.NetworkPolicies, func(p NetworkPolicy) string { return p.Name }),
+		RoleBindings:    mergeNamed(s.RoleBindings, override.RoleBindings, func(b RoleBinding) string { return b.Name }),
+	}
+}
+
+func references(s string) []string {
+	seen := map[string]bool{}
+	var refs []string
+	for _, m := range paramReference.FindAllStringSubmatch(s, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			refs = append(refs, m[1])
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func mergeMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = v
+	}
+	return result
+}
+
+func mergeNamed[T any](base, override []T, name func(T) string) []T {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	replaced := make(map[string]bool, len(override))
+	for _, item := range override {
+		replaced[name(item)] = true
+	}
+	var result []T
+	for _, item := range base {
+		if !replaced[name(item)] {
+			result = append(result, item)
+		}
+	}
+	return append(result, override...)
+}
@@ -0,0 +1,98 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+)
+
+func teamTemplate() *domain.Template {
+	return &domain.Template{
+		Name: "team",
+		Parameters: []domain.TemplateParameter{
+			{Name: "team", Required: true},
+			{Name: "cpu", Default: "4"},
+		},
+		Spec: domain.NamespaceSpec{
+			Labels: map[string]string{"team": "${team}", "tenant": "${tenant}"},
+			Quota:  map[string]string{"requests.cpu": "${cpu}"},
+			RoleBindings: []domain.RoleBinding{{
+				Name:     "${team}-edit",
+				Role:     "edit",
+				Subjects: []domain.Subject{{Kind: "Group", Name: "${team}"}},
+			}},
+		},
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	assert.NoError(t, teamTemplate().Validate())
+
+	template := teamTemplate()
+	template.Name = "Team"
+	template.Parameters = append(template.Parameters, domain.TemplateParameter{Name: "namespace"}, domain.TemplateParameter{Name: "1st"})
+	template.Spec.Annotations = map[string]string{"owner": "${owner}"}
+
+	err := template.Validate()
+	assert.ErrorContains(t, err, `name "Team"`)
+	assert.ErrorContains(t, err, `parameters[2].name "namespace": duplicate or built-in`)
+	assert.ErrorContains(t, err, `parameters[3].name "1st"`)
+	assert.ErrorContains(t, err, "undeclared parameter ${owner}")
+}
+
+func TestTemplate_Instantiate(t *testing.T) {
+	spec, err := teamTemplate().Instantiate("acme", "team-a", map[string]string{"team": "payments"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments", "tenant": "acme"}, spec.Labels)
+	assert.Equal(t, map[string]string{"requests.cpu": "4"}, spec.Quota)
+	assert.Equal(t, "payments-edit", spec.RoleBindings[0].Name)
+	assert.Equal(t, "payments", spec.RoleBindings[0].Subjects[0].Name)
+
+	_, err = teamTemplate().Instantiate("acme", "team-a", map[string]string{"colour": "blue"})
+	assert.ErrorContains(t, err, "parameter team is required")
+	assert.ErrorContains(t, err, "unknown parameter colour")
+
+	// Values are validated once substituted, and can't break out of the
+	// string they are substituted into.
+	_, err = teamTemplate().Instantiate("acme", "team-a", map[string]string{"team": "payments", "cpu": "lots"})
+	assert.ErrorContains(t, err, `quota requests.cpu: invalid quantity "lots"`)
+	_, err = teamTemplate().Instantiate("acme", "team-a", map[string]string{"team": `x","role":"cluster-admin`})
+	assert.Error(t, err)
+}
+
+func TestNamespaceSpec_Validate(t *testing.T) {
+	spec := domain.NamespaceSpec{
+		Labels: map[string]string{"naas.io/tenant": "other", "app": "web"},
+		NetworkPolicies: []domain.NetworkPolicy{
+			{Name: "naas-allow", From: []domain.PolicyPeer{{}}},
+			{Name: "allow-lb", From: []domain.PolicyPeer{{CIDR: "10.0.0.0/33"}}, Ports: []domain.PolicyPort{{Protocol: "ICMP", Port: 0}}},
+		},
+		RoleBindings: []domain.RoleBinding{{Name: "view"}},
+	}
+
+	err := spec.Validate()
+	assert.ErrorContains(t, err, `labels: key "naas.io/tenant" is reserved`)
+	assert.ErrorContains(t, err, `networkPolicies[0].name "naas-allow": the naas- prefix is reserved`)
+	assert.ErrorContains(t, err, "networkPolicies[0].from[0]: one of namespaceSelector, podSelector or cidr is required")
+	assert.ErrorContains(t, err, `networkPolicies[1].from[0].cidr: invalid CIDR "10.0.0.0/33"`)
+	assert.ErrorContains(t, err, "networkPolicies[1].ports[0].protocol: must be TCP, UDP or SCTP")
+	assert.ErrorContains(t, err, "networkPolicies[1].ports[0].port: must be between 1 and 65535")
+	assert.ErrorContains(t, err, "roleBindings[0].role: required")
+	assert.ErrorContains(t, err, "roleBindings[0].subjects: required")
+}
+
+func TestNamespaceSpec_Merge(t *testing.T) {
+	base := domain.NamespaceSpec{
+		Labels:          map[string]string{"team": "a", "tier": "gold"},
+		NetworkPolicies: []domain.NetworkPolicy{{Name: "allow-web"}, {Name: "allow-db"}},
+	}
+	merged := base.Merge(domain.NamespaceSpec{
+		Labels:          map[string]string{"team": "b"},
+		NetworkPolicies: []domain.NetworkPolicy{{Name: "allow-db", Ports: []domain.PolicyPort{{Port: 5432}}}},
+	})
+
+	assert.Equal(t, map[string]string{"team": "b", "tier": "gold"}, merged.Labels)
+	assert.Equal(t, []domain.NetworkPolicy{{Name: "allow-web"}, {Name: "allow-db", Ports: []domain.PolicyPort{{Port: 5432}}}}, merged.NetworkPolicies)
+	assert.Nil(t, merged.Quota)
+}
@@ -1,6 +1,89 @@
 package domain
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+type TenantStatus string
+
+const (
+	TenantActive    TenantStatus = "Active"
+	TenantSuspended TenantStatus = "Suspended"
+	TenantArchived  TenantStatus = "Archived"
+)
+
 type Tenant struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// ResourceVersion changes with every write of the tenant. Updates
+	// that carry one only apply if the tenant hasn't changed since.
+	ResourceVersion string       `json:"resourceVersion,omitempty"`
+	Status          TenantStatus `json:"status,omitempty"`
+	StatusReason    string       `json:"statusReason,omitempty"`
+	// Members are granted access to every namespace of the tenant.
+	Members []Member `json:"members,omitempty"`
+	// Quota caps the sum of the quota hard limits of the tenant's
+	// namespaces, keyed by resource name like theirs. Resources it doesn't
+	// name are unlimited.
+	Quota map[string]string `json:"quota,omitempty"`
+	// DeletionTimestamp is set when the tenant is deleted. It can be
+	// restored until the retention period has passed.
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	// Finalizers name the cleanup steps that must complete before a deleted
+	// tenant is removed for good.
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+// ValidateTenantID checks that id can go into the tenant label of a
+// namespace and name the tenant's directory of exported manifests.
+func ValidateTenantID(id string) error {
+	if msgs := validation.IsDNS1123Label(id); len(msgs) > 0 {
+		return fmt.Errorf("id %q: %s", id, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func (t *Tenant) Deleted() bool {
+	return t.DeletionTimestamp != nil
+}
+
+// ExceedsQuota returns the resources, in order, whose usage in used is
+// over the tenant's quota and higher than in before. Usage already over
+// the quota, e.g. after it was lowered, may stay but not grow.
+func (t *Tenant) ExceedsQuota(used, before map[string]resource.Quantity) []string {
+	var exceeded []string
+	for _, name := range sortedKeys(t.Quota) {
+		limit, err := resource.ParseQuantity(t.Quota[name])
+		if err != nil {
+			continue
+		}
+		usage, previous := used[name], before[name]
+		if usage.Cmp(limit) > 0 && usage.Cmp(previous) > 0 {
+			exceeded = append(exceeded, name)
+		}
+	}
+	return exceeded
+}
+
+// CanTransitionTo reports whether a tenant may move from status s to next.
+// Suspension is reversible; archiving is final.
+func (s TenantStatus) CanTransitionTo(next TenantStatus) bool {
+	switch s {
+	case TenantActive:
+		return next == TenantSuspended || next == TenantArchived
+	case TenantSuspended:
+		return next == TenantActive || next == TenantArchived
+	}
+	return false
+}
+
+// Frozen reports whether workloads of a tenant with status s must be
+// stopped and isolated.
+func (s TenantStatus) Frozen() bool {
+	return s == TenantSuspended || s == TenantArchived
 }
@@ -0,0 +1,82 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"naas/domain"
+)
+
+func TestTenantStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to domain.TenantStatus
+		allowed  bool
+	}{
+		{domain.TenantActive, domain.TenantSuspended, true},
+		{domain.TenantActive, domain.TenantArchived, true},
+		{domain.TenantActive, domain.TenantActive, false},
+		{domain.TenantSuspended, domain.TenantActive, true},
+		{domain.TenantSuspended, domain.TenantArchived, true},
+		{domain.TenantArchived, domain.TenantActive, false},
+		{domain.TenantArchived, domain.TenantSuspended, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestTenant_ExceedsQuota(t *testing.T) {
+	tenant := domain.Tenant{Quota: map[string]string{"pods": "100", "requests.cpu": "4"}}
+	usage := func(pods, cpu string) map[string]resource.Quantity {
+		return map[string]resource.Quantity{"pods": resource.MustParse(pods), "requests.cpu": resource.MustParse(cpu)}
+	}
+	before := usage("120", "2")
+
+	// Usage over the quota may stay or shrink, but not grow
+	assert.Empty(t, tenant.ExceedsQuota(before, before))
+	assert.Empty(t, tenant.ExceedsQuota(usage("110", "4"), before))
+	assert.Equal(t, []string{"pods", "requests.cpu"}, tenant.ExceedsQuota(usage("130", "4500m"), before))
+	// Resources the quota doesn't name are unlimited
+	assert.Empty(t, (&domain.Tenant{}).ExceedsQuota(usage("130", "8"), before))
+}
+
+func TestValidateMembers(t *testing.T) {
+	assert.NoError(t, domain.ValidateMembers([]domain.Member{
+		{User: "alice", Role: domain.MemberAdmin},
+		{User: "bob", Role: domain.MemberViewer},
+	}))
+
+	err := domain.ValidateMembers([]domain.Member{
+		{User: "alice", Role: domain.MemberAdmin},
+		{User: "alice", Role: "owner"},
+		{Role: domain.MemberDeveloper},
+	})
+	assert.ErrorContains(t, err, `members[1].user "alice": duplicate`)
+	assert.ErrorContains(t, err, `members[1].role "owner"`)
+	assert.ErrorContains(t, err, "members[2].user: required")
+}
+
+func TestEffectiveBindings(t *testing.T) {
+	tenant := &domain.Tenant{ID: "acme", Members: []domain.Member{
+		{User: "carol", Role: domain.MemberViewer},
+		{User: "alice", Role: domain.MemberAdmin},
+		{User: "bob", Role: domain.MemberViewer},
+	}}
+	ns := &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		RoleBindings: []domain.RoleBinding{{Name: "ci", Role: "edit", Subjects: []domain.Subject{{Kind: "ServiceAccount", Name: "deployer"}}}},
+	}}
+
+	assert.Equal(t, []domain.Binding{
+		{Name: "naas-tenant-admin", RoleKind: "Role", Role: "naas-tenant-admin", Source: domain.BindingSourceMembership,
+			Subjects: []domain.Subject{{Kind: "User", Name: "alice"}}},
+		{Name: "naas-tenant-viewer", RoleKind: "Role", Role: "naas-tenant-viewer", Source: domain.BindingSourceMembership,
+			Subjects: []domain.Subject{{Kind: "User", Name: "carol"}, {Kind: "User", Name: "bob"}}},
+		{Name: "ci", RoleKind: "ClusterRole", Role: "edit", Source: domain.BindingSourceSpec,
+			Subjects: []domain.Subject{{Kind: "ServiceAccount", Name: "deployer", Namespace: "team-a"}}},
+	}, domain.EffectiveBindings(tenant, ns))
+
+	assert.Len(t, domain.EffectiveBindings(nil, ns), 1)
+	// The namespace's spec is left untouched
+	assert.Empty(t, ns.RoleBindings[0].Subjects[0].Namespace)
+}
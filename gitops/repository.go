@@ -0,0 +1,199 @@
+// gitops/repository.go
+
+// Package gitops commits rendered manifests to a local Git working tree,
+// for clusters that Argo CD or Flux apply from a repository.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"naas/config"
+	"naas/logging"
+	"naas/reconciler"
+)
+
+// Repository writes the manifests of each tenant to a directory named after
+// it, and commits every change on its own. Writes are queued and committed
+// by Run, so requests don't wait for git.
+type Repository struct {
+	dir         string
+	authorName  string
+	authorEmail string
+
+	// mtx guards the working tree.
+	mtx sync.Mutex
+
+	// queueMtx guards pending and order, the tenants with manifests waiting
+	// to be committed in the order they were first queued.
+	queueMtx sync.Mutex
+	pending  map[string]write
+	order    []string
+	// queued wakes Run when a write is queued.
+	queued chan struct{}
+}
+
+type write struct {
+	manifests []reconciler.Manifest
+	message   string
+}
+
+// Open checks that cfg.Repository is a Git working tree. It returns nil
+// when no repository is configured.
+func Open(ctx context.Context, cfg config.GitOps) (*Repository, error) {
+	if cfg.Repository == "" {
+		return nil, nil
+	}
+	r := &Repository{
+		dir:         cfg.Repository,
+		authorName:  cfg.AuthorName,
+		authorEmail: cfg.AuthorEmail,
+		pending:     make(map[string]write),
+		queued:      make(chan struct{}, 1),
+	}
+	if _, err := r.git(ctx, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("opening gitops repository: %w", err)
+	}
+	return r, nil
+}
+
+// WriteTenant queues the manifests of a tenant to replace its current ones,
+// committed with message. Manifests still queued for the tenant are
+// replaced, along with their message: only the latest state is committed.
+func (r *Repository) WriteTenant(_ context.Context, tenantID string, manifests []reconciler.Manifest, message string) error {
+	if tenantID == "" || strings.ContainsAny(tenantID, `/\`) || !filepath.IsLocal(tenantID) {
+		return fmt.Errorf("tenant %q can't be used as a directory name", tenantID)
+	}
+	for _, m := range manifests {
+		if !filepath.IsLocal(filepath.FromSlash(m.Path)) {
+			return fmt.Errorf("manifest path %q is outside the tenant directory", m.Path)
+		}
+	}
+
+	r.queueMtx.Lock()
+	if _, ok := r.pending[tenantID]; !ok {
+		r.order = append(r.order, tenantID)
+	}
+	r.pending[tenantID] = write{manifests: manifests, message: message}
+	r.queueMtx.Unlock()
+
+	select {
+	case r.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run commits queued manifests until ctx is cancelled. What is queued then
+// is left for a final Flush, once nothing else queues manifests.
+func (r *Repository) Run(ctx context.Context) {
+	for {
+		select {
+		case <-r.queued:
+		case <-ctx.Done():
+			return
+		}
+		// A commit under way finishes even when shutting down
+		if err := r.Flush(context.WithoutCancel(ctx)); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "committing manifests failed, will retry on resync", "error", err)
+		}
+	}
+}
+
+// Flush commits the queued manifests of every tenant, in the order they
+// were queued. Failed tenants are dropped from the queue; the next resync
+// writes them again.
+func (r *Repository) Flush(ctx context.Context) error {
+	var errs []error
+	for {
+		tenantID, w, ok := r.next()
+		if !ok {
+			return errors.Join(errs...)
+		}
+		if err := r.commit(ctx, tenantID, w.manifests, w.message); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenantID, err))
+		}
+	}
+}
+
+// next takes the first queued tenant off the queue.
+func (r *Repository) next() (string, write, bool) {
+	r.queueMtx.Lock()
+	defer r.queueMtx.Unlock()
+
+	if len(r.order) == 0 {
+		return "", write{}, false
+	}
+	tenantID := r.order[0]
+	r.order = r.order[1:]
+	w := r.pending[tenantID]
+	delete(r.pending, tenantID)
+	return tenantID, w, true
+}
+
+// commit replaces the manifests of a tenant and commits the change with
+// message. Nothing is committed when the manifests are unchanged, and a
+// tenant without manifests has its directory removed.
+func (r *Repository) commit(ctx context.Context, tenantID string, manifests []reconciler.Manifest, message string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	dir := filepath.Join(r.dir, tenantID)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		path := filepath.Join(dir, filepath.FromSlash(m.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, m.Data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.git(ctx, "add", "--all", "--", tenantID); err != nil {
+		return err
+	}
+	// diff exits with 1 when there are staged changes.
+	_, err := r.git(ctx, "diff", "--cached", "--quiet", "--", tenantID)
+	if err == nil {
+		return nil
+	}
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		return err
+	}
+
+	if _, err := r.git(ctx, "commit", "--quiet", "--message", message, "--", tenantID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "manifests committed", "tenant_id", tenantID, "message", message)
+	return nil
+}
+
+func (r *Repository) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+r.authorName, "GIT_AUTHOR_EMAIL="+r.authorEmail,
+		"GIT_COMMITTER_NAME="+r.authorName, "GIT_COMMITTER_EMAIL="+r.authorEmail,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,114 @@
+package gitops_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"naas/config"
+	"naas/gitops"
+	"naas/reconciler"
+)
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+
+	repo, err := gitops.Open(ctx, config.GitOps{Repository: dir, AuthorName: "naas", AuthorEmail: "naas@example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	manifests := []reconciler.Manifest{
+		{Path: "team-a/namespace-team-a.yaml", Data: []byte("kind: Namespace\n")},
+		{Path: "team-a/resourcequota-naas-quota.yaml", Data: []byte("kind: ResourceQuota\n")},
+	}
+	// Writes are only committed when flushed
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", manifests, "Update namespace team-a of tenant acme"))
+	assert.NoFileExists(t, filepath.Join(dir, "acme", "team-a", "namespace-team-a.yaml"))
+	assert.NoError(t, repo.Flush(ctx))
+	assert.Equal(t, "naas <naas@example.com> Update namespace team-a of tenant acme", git("log", "--format=%an <%ae> %s"))
+	data, err := os.ReadFile(filepath.Join(dir, "acme", "team-a", "namespace-team-a.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: Namespace\n", string(data))
+
+	// Unchanged manifests make no commit
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", manifests, "Resync tenant acme"))
+	assert.NoError(t, repo.Flush(ctx))
+	assert.Equal(t, "1", git("rev-list", "--count", "HEAD"))
+
+	// Other tenants' files are left alone
+	assert.NoError(t, repo.WriteTenant(ctx, "globex", manifests[:1], "Update namespace team-a of tenant globex"))
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", manifests[:1], "Update namespace team-a of tenant acme"))
+	assert.NoError(t, repo.Flush(ctx))
+	assert.Equal(t, "3", git("rev-list", "--count", "HEAD"))
+	assert.NoFileExists(t, filepath.Join(dir, "acme", "team-a", "resourcequota-naas-quota.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "globex", "team-a", "namespace-team-a.yaml"))
+
+	// Only the latest queued manifests of a tenant are committed
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", manifests, "Update namespace team-a of tenant acme"))
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", nil, "Remove namespace team-a of tenant acme"))
+	assert.NoError(t, repo.Flush(ctx))
+	assert.Equal(t, "4", git("rev-list", "--count", "HEAD"))
+	assert.Equal(t, "Remove namespace team-a of tenant acme", git("log", "-1", "--format=%s"))
+	assert.NoDirExists(t, filepath.Join(dir, "acme"))
+	assert.Equal(t, "", git("status", "--porcelain"))
+
+	assert.Error(t, repo.WriteTenant(ctx, "../etc", manifests, "escape"))
+	assert.Error(t, repo.WriteTenant(ctx, "", manifests, "empty"))
+	escape := []reconciler.Manifest{{Path: "../../../escape/namespace-escape.yaml", Data: []byte("kind: Namespace\n")}}
+	assert.Error(t, repo.WriteTenant(ctx, "acme", escape, "escape"))
+	assert.NoDirExists(t, filepath.Join(dir, "..", "..", "escape"))
+}
+
+func TestRepository_Run(t *testing.T) {
+	dir := t.TempDir()
+	out, err := exec.Command("git", "-C", dir, "init", "--quiet").CombinedOutput()
+	assert.NoError(t, err, string(out))
+	repo, err := gitops.Open(context.Background(), config.GitOps{Repository: dir, AuthorName: "naas", AuthorEmail: "naas@example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		repo.Run(ctx)
+		close(stopped)
+	}()
+
+	manifests := []reconciler.Manifest{{Path: "team-a/namespace-team-a.yaml", Data: []byte("kind: Namespace\n")}}
+	assert.NoError(t, repo.WriteTenant(ctx, "acme", manifests, "Update namespace team-a of tenant acme"))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "acme", "team-a", "namespace-team-a.yaml"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-stopped
+	// What is queued after Run stops is left for the final flush
+	assert.NoError(t, repo.WriteTenant(context.Background(), "globex", manifests, "Update namespace team-a of tenant globex"))
+	assert.NoDirExists(t, filepath.Join(dir, "globex"))
+	assert.NoError(t, repo.Flush(context.Background()))
+	assert.FileExists(t, filepath.Join(dir, "globex", "team-a", "namespace-team-a.yaml"))
+}
+
+func TestOpen(t *testing.T) {
+	repo, err := gitops.Open(context.Background(), config.GitOps{})
+	assert.NoError(t, err)
+	assert.Nil(t, repo)
+
+	_, err = gitops.Open(context.Background(), config.GitOps{Repository: t.TempDir()})
+	assert.ErrorContains(t, err, "opening gitops repository")
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "naas/domain"
+	. "naas/service"
+)
+
+type BulkHandler struct {
+	service *BulkService
+}
+
+func NewBulkHandler(service *BulkService) *BulkHandler {
+	return &BulkHandler{service: service}
+}
+
+// bulkResponse is a bulk report whose results carry the status code the
+// operation would have got as a single request.
+type bulkResponse struct {
+	Mode      BulkMode     `json:"mode"`
+	Committed bool         `json:"committed"`
+	Results   []bulkResult `json:"results"`
+}
+
+type bulkResult struct {
+	Code int `json:"code,omitempty"`
+	BulkResult
+}
+
+// ApplyBulk applies a batch of tenant and namespace operations. The
+// response lists the outcome of each one. An all-or-nothing batch that was
+// rolled back responds with the status of the operation that failed.
+func (h *BulkHandler) ApplyBulk(c *gin.Context) {
+	var bulk Bulk
+	if err := c.ShouldBindJSON(&bulk); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.service.Apply(c.Request.Context(), &bulk)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	status := http.StatusOK
+	resp := bulkResponse{Mode: report.Mode, Committed: report.Committed, Results: make([]bulkResult, len(report.Results))}
+	for i, result := range report.Results {
+		resp.Results[i] = bulkResult{BulkResult: result}
+		switch result.Status {
+		case BulkSucceeded:
+			resp.Results[i].Code = http.StatusOK
+		case BulkFailed:
+			resp.Results[i].Code = errorStatus(result.Err)
+			if !report.Committed {
+				status = resp.Results[i].Code
+				_ = c.Error(result.Err)
+			}
+		}
+	}
+
+	c.JSON(status, resp)
+}
@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/handlers"
+	"naas/repositories"
+	"naas/service"
+)
+
+func TestBulkHandler_ApplyBulk(t *testing.T) {
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewBulkHandler(service.NewBulkService(tenantService, namespaceService, nil))
+
+	router := gin.Default()
+	router.POST("/bulk", handler.ApplyBulk)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/bulk", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	type result struct {
+		Code   int    `json:"code"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	type response struct {
+		Committed bool     `json:"committed"`
+		Results   []result `json:"results"`
+	}
+
+	assert.Equal(t, http.StatusBadRequest, serve(`{"operations":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(`{"operations":[{"action":"create"}]}`).Code)
+
+	// A rolled back request responds with the status of the failed operation
+	rec := serve(`{"operations":[
+		{"action":"create","tenant":{"id":"acme"}},
+		{"action":"create","tenantId":"acme","namespace":{"name":"team-a","quota":{"pods":"many"}}},
+		{"action":"create","tenantId":"acme","namespace":{"name":"team-b"}}
+	]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp response
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.Committed)
+	assert.Equal(t, []result{
+		{Status: "rolledBack"},
+		{Code: http.StatusBadRequest, Status: "failed", Error: resp.Results[1].Error},
+		{Status: "skipped"},
+	}, resp.Results)
+	assert.Contains(t, resp.Results[1].Error, "invalid namespace spec")
+
+	rec = serve(`{"mode":"bestEffort","operations":[
+		{"action":"create","tenant":{"id":"acme"}},
+		{"action":"create","tenant":{"id":"acme"}},
+		{"action":"create","tenantId":"acme","namespace":{"name":"team-a"}},
+		{"action":"delete","tenantId":"acme","namespace":{"name":"missing"}}
+	]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	resp = response{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Committed)
+	if assert.Len(t, resp.Results, 4) {
+		assert.Equal(t, http.StatusOK, resp.Results[0].Code)
+		assert.Equal(t, http.StatusConflict, resp.Results[1].Code)
+		assert.Equal(t, http.StatusOK, resp.Results[2].Code)
+		assert.Equal(t, http.StatusNotFound, resp.Results[3].Code)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "naas/domain"
+	. "naas/service"
+)
+
+type ClusterHandler struct {
+	service *ClusterService
+}
+
+func NewClusterHandler(service *ClusterService) *ClusterHandler {
+	return &ClusterHandler{service: service}
+}
+
+func (h *ClusterHandler) CreateCluster(c *gin.Context) {
+	var cluster Cluster
+	if err := c.ShouldBindJSON(&cluster); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.CreateCluster(c.Request.Context(), &cluster); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cluster)
+}
+
+func (h *ClusterHandler) GetCluster(c *gin.Context) {
+	cluster, err := h.service.GetCluster(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cluster)
+}
+
+func (h *ClusterHandler) ListClusters(c *gin.Context) {
+	clusters, err := h.service.ListClusters(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clusters)
+}
+
+func (h *ClusterHandler) UpdateCluster(c *gin.Context) {
+	var cluster Cluster
+	if err := c.ShouldBindJSON(&cluster); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	cluster.Name = c.Param("name")
+
+	if err := h.service.UpdateCluster(c.Request.Context(), &cluster); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cluster)
+}
+
+func (h *ClusterHandler) DeleteCluster(c *gin.Context) {
+	if err := h.service.DeleteCluster(c.Request.Context(), c.Param("name")); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
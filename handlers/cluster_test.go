@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/handlers"
+	"naas/repositories"
+	"naas/service"
+)
+
+func TestClusterHandler(t *testing.T) {
+	clusterRepo := repositories.NewClusterRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterService := service.NewClusterService(clusterRepo, namespaceRepo, nil)
+	namespaceService := service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, clusterRepo, nil, nil, time.Hour)
+	handler := handlers.NewClusterHandler(clusterService)
+	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+
+	router := gin.Default()
+	router.GET("/clusters", handler.ListClusters)
+	router.POST("/clusters", handler.CreateCluster)
+	router.GET("/clusters/:name", handler.GetCluster)
+	router.PUT("/clusters/:name", handler.UpdateCluster)
+	router.DELETE("/clusters/:name", handler.DeleteCluster)
+	router.POST("/namespaces/:tenantId", namespaceHandler.CreateNamespace)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without registered clusters, namespaces can't ask for one
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-a","cluster":"east"}`).Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-a","placement":{"region":"us-east"}}`).Code)
+
+	cluster := `{"name":"east","region":"us-east","kubeconfig":"/etc/naas/east","capacity":1}`
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/clusters", cluster).Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/clusters", cluster).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/clusters", `{"name":"west"}`).Code)
+
+	rec := serve(http.MethodPost, "/namespaces/acme", `{"name":"team-a"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"cluster":"east"`)
+
+	rec = serve(http.MethodGet, "/clusters/east", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"allocated":1`)
+
+	// The only cluster is full
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b"}`).Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b","cluster":"east"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b","cluster":"west"}`).Code)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/clusters/east", `{"region":"us-east","kubeconfig":"/etc/naas/east","capacity":2}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/clusters/west", `{"kubeconfig":"/etc/naas/west"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b","cluster":"east"}`).Code)
+
+	rec = serve(http.MethodGet, "/clusters", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"allocated":2`)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/clusters/east", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/clusters/west", "").Code)
+}
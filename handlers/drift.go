@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "naas/service"
+)
+
+// DriftHandler reports how namespaces in the cluster depart from their
+// records.
+type DriftHandler struct {
+	scanner *DriftScanner
+}
+
+func NewDriftHandler(scanner *DriftScanner) *DriftHandler {
+	return &DriftHandler{scanner: scanner}
+}
+
+// ListDrift returns the drifted namespaces found by the last checks, of the
+// tenant in the :id path parameter or of all tenants.
+func (h *DriftHandler) ListDrift(c *gin.Context) {
+	drift, err := h.scanner.ListDrift(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, drift)
+}
+
+// GetDrift checks a namespace against the cluster now.
+func (h *DriftHandler) GetDrift(c *gin.Context) {
+	drift, err := h.scanner.CheckNamespace(c.Request.Context(), c.Param("tenantId"), c.Param("name"))
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, drift)
+}
+
+// RemediateDrift reconciles a namespace and returns its drift afterwards.
+func (h *DriftHandler) RemediateDrift(c *gin.Context) {
+	drift, err := h.scanner.RemediateNamespace(c.Request.Context(), c.Param("tenantId"), c.Param("name"))
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, drift)
+}
@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"naas/config"
+	"naas/domain"
+	"naas/handlers"
+	"naas/kube"
+	"naas/reconciler"
+	"naas/repositories"
+	"naas/service"
+)
+
+func TestDriftHandler(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	reconcile := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, reconcile, nil, time.Hour)
+	handler := handlers.NewDriftHandler(service.NewDriftScanner(namespaceRepo, reconcile, false))
+	disconnected := handlers.NewDriftHandler(service.NewDriftScanner(namespaceRepo, nil, false))
+
+	router := gin.Default()
+	router.GET("/drift", handler.ListDrift)
+	router.GET("/tenants/:id/drift", handler.ListDrift)
+	router.GET("/namespaces/:tenantId/:name/drift", handler.GetDrift)
+	router.POST("/namespaces/:tenantId/:name/drift/remediate", handler.RemediateDrift)
+	router.GET("/disconnected/:tenantId/:name/drift", disconnected.GetDrift)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+	assert.NoError(t, clientset.CoreV1().Namespaces().Delete(ctx, "team-a", metav1.DeleteOptions{}))
+
+	rec := serve(http.MethodGet, "/namespaces/acme/team-a/drift", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"differences":[{"kind":"Namespace","name":"team-a","change":"missing"}]`)
+
+	rec = serve(http.MethodGet, "/tenants/acme/drift", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"namespace":"team-a"`)
+	assert.JSONEq(t, `[]`, serve(http.MethodGet, "/tenants/other/drift", "").Body.String())
+
+	rec = serve(http.MethodPost, "/namespaces/acme/team-a/drift/remediate", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"differences":[]`)
+	assert.JSONEq(t, `[]`, serve(http.MethodGet, "/drift", "").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/namespaces/acme/missing/drift", "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodGet, "/disconnected/acme/team-a/drift", "").Code)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"naas/repositories"
+	. "naas/service"
+)
+
+// StatusClientClosedRequest is the non-standard status recorded when the
+// client went away before the request completed.
+const StatusClientClosedRequest = 499
+
+// respondError writes err as a JSON error body and records it on the
+// context so the request logger can report it. Context errors take
+// precedence over the given status.
+func respondError(c *gin.Context, status int, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		status = StatusClientClosedRequest
+	}
+
+	_ = c.Error(err)
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// errorStatus returns the status code for an error returned by the
+// services. Invalid requests are checked first: a request referring to a
+// missing object is invalid, while a missing object in the path is not
+// found. Handlers for which an error means something else check for it
+// first.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidSpec), errors.Is(err, ErrInvalidTenant), errors.Is(err, ErrInvalidMember), errors.Is(err, ErrInvalidExpiry),
+		errors.Is(err, ErrInvalidTemplate), errors.Is(err, ErrInvalidCluster), errors.Is(err, ErrInvalidAdoption),
+		errors.Is(err, ErrInvalidTransfer), errors.Is(err, ErrInvalidBulk), errors.Is(err, ErrInvalidQuota),
+		errors.Is(err, ErrParentNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, repositories.ErrTenantNotFound), errors.Is(err, repositories.ErrNamespaceNotFound),
+		errors.Is(err, repositories.ErrClusterNotFound), errors.Is(err, repositories.ErrTemplateNotFound),
+		errors.Is(err, ErrMemberNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidPatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repositories.ErrTenantExists), errors.Is(err, repositories.ErrNamespaceExists),
+		errors.Is(err, repositories.ErrClusterExists), errors.Is(err, repositories.ErrTemplateExists),
+		errors.Is(err, repositories.ErrTenantDeleted), errors.Is(err, repositories.ErrNamespaceDeleted),
+		errors.Is(err, repositories.ErrTenantNotDeleted), errors.Is(err, repositories.ErrNamespaceNotDeleted),
+		errors.Is(err, repositories.ErrFinalizersPending), errors.Is(err, repositories.ErrConflict),
+		errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrNotEphemeral), errors.Is(err, ErrHasChildren),
+		errors.Is(err, ErrNamespaceCycle), errors.Is(err, ErrNoCluster), errors.Is(err, ErrClusterInUse):
+		return http.StatusConflict
+	case errors.Is(err, ErrTenantSuspended), errors.Is(err, ErrTenantArchived), errors.Is(err, ErrQuotaExceeded):
+		return http.StatusForbidden
+	case errors.Is(err, ErrRetentionExpired):
+		return http.StatusGone
+	case errors.Is(err, ErrNotConnected):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// listOptions reads the ?includeDeleted query parameter. It responds with
+// 400 and returns false when the value isn't a boolean.
+func listOptions(c *gin.Context) ([]repositories.ListOption, bool) {
+	v := c.Query("includeDeleted")
+	if v == "" {
+		return nil, true
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Errorf("includeDeleted: %w", err))
+		return nil, false
+	}
+	return []repositories.ListOption{repositories.IncludeDeleted(include)}, true
+}
@@ -0,0 +1,64 @@
+// handlers/health.go
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"naas/health"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{registry: registry}
+}
+
+// Livez serves /livez and /healthz.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	h.report(c, "livez", h.registry.Liveness)
+}
+
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	h.report(c, "readyz", h.registry.Readiness)
+}
+
+// report writes the check results in the plain text format used by the
+// Kubernetes API server: a bare "ok" on success, or one line per check when
+// a check fails or ?verbose is set. Checks can be skipped with ?exclude=name.
+func (h *HealthHandler) report(c *gin.Context, endpoint string, checks func(context.Context, ...string) []health.Result) {
+	results := checks(c.Request.Context(), c.QueryArray("exclude")...)
+	healthy := health.Healthy(results)
+	_, verbose := c.GetQuery("verbose")
+
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	if healthy && !verbose {
+		c.String(status, "ok")
+		return
+	}
+
+	var b strings.Builder
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(&b, "[-]%s failed: %v\n", r.Name, r.Err)
+		} else {
+			fmt.Fprintf(&b, "[+]%s ok\n", r.Name)
+		}
+	}
+	if healthy {
+		fmt.Fprintf(&b, "%s check passed\n", endpoint)
+	} else {
+		fmt.Fprintf(&b, "%s check failed\n", endpoint)
+	}
+	c.String(status, b.String())
+}
@@ -0,0 +1,54 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/handlers"
+	"naas/health"
+)
+
+func TestHealthHandler(t *testing.T) {
+	registry := health.NewRegistry()
+	var storageErr error
+	registry.AddReadinessCheck("storage", func(context.Context) error { return storageErr })
+	handler := handlers.NewHealthHandler(registry)
+
+	router := gin.Default()
+	router.GET("/livez", handler.Livez)
+	router.GET("/readyz", handler.Readyz)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+
+	w = get("/readyz?verbose")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[+]ping ok\n[+]storage ok\nreadyz check passed\n", w.Body.String())
+
+	storageErr = errors.New("connection refused")
+
+	w = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "[+]ping ok\n[-]storage failed: connection refused\nreadyz check failed\n", w.Body.String())
+
+	w = get("/readyz?exclude=storage")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get("/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
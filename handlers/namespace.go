@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	. "naas/domain"
 	. "naas/service"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,17 +19,97 @@ func NewNamespaceHandler(service *NamespaceService) *NamespaceHandler {
 	return &NamespaceHandler{service: service}
 }
 
+// createNamespaceRequest is a namespace that may give its lifetime as a TTL
+// instead of an expiresAt time.
+type createNamespaceRequest struct {
+	Namespace
+	TTL string `json:"ttl"`
+}
+
+// namespace returns the requested namespace with its TTL turned into an
+// expiry time.
+func (r *createNamespaceRequest) namespace() (Namespace, error) {
+	namespace := r.Namespace
+	if r.TTL == "" {
+		return namespace, nil
+	}
+	if namespace.ExpiresAt != nil {
+		return namespace, errors.New("ttl and expiresAt are mutually exclusive")
+	}
+	ttl, err := time.ParseDuration(r.TTL)
+	if err != nil {
+		return namespace, fmt.Errorf("ttl: %w", err)
+	}
+	expiresAt := time.Now().Add(ttl)
+	namespace.ExpiresAt = &expiresAt
+	return namespace, nil
+}
+
+// cloneNamespaceRequest names the clone and holds the values that override
+// the cloned namespace's, along with the tenant to clone it into.
+type cloneNamespaceRequest struct {
+	createNamespaceRequest
+	Tenant string `json:"tenant"`
+}
+
+type parentRequest struct {
+	Parent string `json:"parent"`
+}
+
+// transferRequest names the tenant a namespace moves to, and optionally its
+// parent there.
+type transferRequest struct {
+	Tenant string `json:"tenant" binding:"required"`
+	Parent string `json:"parent"`
+}
+
+type renewRequest struct {
+	TTL string `json:"ttl" binding:"required"`
+}
+
 func (h *NamespaceHandler) CreateNamespace(c *gin.Context) {
 	tenantID := c.Param("tenantId")
 
-	var namespace Namespace
-	if err := c.ShouldBindJSON(&namespace); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req createNamespaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	namespace, err := req.namespace()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.CreateNamespace(c.Request.Context(), tenantID, &namespace); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, namespace)
+}
+
+// CloneNamespace creates a namespace like an existing one, optionally in
+// another tenant.
+func (h *NamespaceHandler) CloneNamespace(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	var req cloneNamespaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	overrides, err := req.namespace()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if err := h.service.CreateNamespace(tenantID, &namespace); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	namespace, err := h.service.CloneNamespace(c.Request.Context(), tenantID, name, req.Tenant, &overrides)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
 		return
 	}
 
@@ -36,9 +119,14 @@ func (h *NamespaceHandler) CreateNamespace(c *gin.Context) {
 func (h *NamespaceHandler) GetAllNamespaces(c *gin.Context) {
 	tenantID := c.Param("tenantId")
 
-	namespaces, err := h.service.GetAllNamespaces(tenantID)
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+
+	namespaces, err := h.service.GetAllNamespaces(c.Request.Context(), tenantID, opts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -49,11 +137,206 @@ func (h *NamespaceHandler) GetNamespace(c *gin.Context) {
 	tenantID := c.Param("tenantId")
 	name := c.Param("name")
 
-	namespace, err := h.service.GetNamespace(tenantID, name)
+	namespace, err := h.service.GetNamespace(c.Request.Context(), tenantID, name)
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	setETag(c, namespace.ResourceVersion)
+	c.JSON(http.StatusOK, namespace)
+}
+
+// PatchNamespace changes the spec, isolation and parent of a namespace with
+// a merge patch or JSON patch. An If-Match header makes it conditional on
+// the namespace's resource version.
+func (h *NamespaceHandler) PatchNamespace(c *gin.Context) {
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	namespace, err := h.service.PatchNamespace(c.Request.Context(), c.Param("tenantId"), c.Param("name"), patch)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	setETag(c, namespace.ResourceVersion)
+	c.JSON(http.StatusOK, namespace)
+}
+
+func (h *NamespaceHandler) DeleteNamespace(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	namespace, err := h.service.DeleteNamespace(c.Request.Context(), tenantID, name)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+func (h *NamespaceHandler) RestoreNamespace(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	namespace, err := h.service.RestoreNamespace(c.Request.Context(), tenantID, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		status := errorStatus(err)
+		if errors.Is(err, ErrParentNotFound) {
+			// The request is fine; the namespace's parent went away
+			status = http.StatusConflict
+		}
+		respondError(c, status, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, namespace)
 }
+
+func (h *NamespaceHandler) RenewNamespace(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	var req renewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Errorf("ttl: %w", err))
+		return
+	}
+
+	namespace, err := h.service.RenewNamespace(c.Request.Context(), tenantID, name, ttl)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+// GetSubtree lists a namespace and its descendants, depth first.
+func (h *NamespaceHandler) GetSubtree(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+
+	namespaces, err := h.service.GetSubtree(c.Request.Context(), tenantID, name, opts...)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespaces)
+}
+
+func (h *NamespaceHandler) SetParent(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	var req parentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	namespace, err := h.service.SetParent(c.Request.Context(), tenantID, name, req.Parent)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+// TransferNamespace moves a namespace to another tenant.
+func (h *NamespaceHandler) TransferNamespace(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	namespace, err := h.service.TransferNamespace(c.Request.Context(), tenantID, name, req.Tenant, req.Parent)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+func (h *NamespaceHandler) GetBindings(c *gin.Context) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	bindings, err := h.service.GetBindings(c.Request.Context(), tenantID, name)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bindings)
+}
+
+// SetIsolation replaces the namespace's network isolation overrides with the
+// request body.
+func (h *NamespaceHandler) SetIsolation(c *gin.Context) {
+	var isolation NetworkIsolation
+	if err := c.ShouldBindJSON(&isolation); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	h.setIsolation(c, &isolation)
+}
+
+// ResetIsolation drops the namespace's network isolation overrides, going
+// back to the default policies.
+func (h *NamespaceHandler) ResetIsolation(c *gin.Context) {
+	h.setIsolation(c, nil)
+}
+
+func (h *NamespaceHandler) setIsolation(c *gin.Context, isolation *NetworkIsolation) {
+	tenantID := c.Param("tenantId")
+	name := c.Param("name")
+
+	namespace, err := h.service.SetIsolation(c.Request.Context(), tenantID, name, isolation)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespace)
+}
+
+// AdoptNamespaces adopts the unmanaged namespaces of a cluster, or reports
+// what would be adopted when the request is a dry run.
+func (h *NamespaceHandler) AdoptNamespaces(c *gin.Context) {
+	var adoption Adoption
+	if err := c.ShouldBindJSON(&adoption); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := h.service.AdoptNamespaces(c.Request.Context(), &adoption)
+	if err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -2,6 +2,7 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -12,11 +13,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestNamespaceHandler_CreateNamespace(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 	handler := handlers.NewNamespaceHandler(service)
 
 	namespace := &domain.Namespace{
@@ -44,11 +46,17 @@ func TestNamespaceHandler_CreateNamespace(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, namespace.Name, result.Name)
+
+	req, err = http.NewRequest(http.MethodPost, "/namespaces/test-tenant", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
 }
 
 func TestNamespaceHandler_GetAllNamespaces(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 	handler := handlers.NewNamespaceHandler(service)
 
 	namespaces := []domain.Namespace{
@@ -56,8 +64,8 @@ func TestNamespaceHandler_GetAllNamespaces(t *testing.T) {
 		{Name: "test-namespace-2"},
 		{Name: "test-namespace-3"},
 	}
-	for _, ns := range namespaces {
-		err := repo.CreateNamespace("test-tenant", &ns)
+	for i := range namespaces {
+		err := repo.CreateNamespace(context.Background(), "test-tenant", &namespaces[i])
 		assert.NoError(t, err)
 	}
 
@@ -83,13 +91,13 @@ func TestNamespaceHandler_GetAllNamespaces(t *testing.T) {
 
 func TestNamespaceHandler_GetNamespace(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 	handler := handlers.NewNamespaceHandler(service)
 
 	namespace := &domain.Namespace{
 		Name: "test-namespace",
 	}
-	err := repo.CreateNamespace("test-tenant", namespace)
+	err := repo.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, "/namespaces/test-tenant/test-namespace", nil)
@@ -111,3 +119,320 @@ func TestNamespaceHandler_GetNamespace(t *testing.T) {
 
 	assert.Equal(t, namespace, &result)
 }
+
+func TestNamespaceHandler_DeleteNamespace(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	err := repo.CreateNamespace(context.Background(), "test-tenant", &domain.Namespace{Name: "test-namespace"})
+	assert.NoError(t, err)
+
+	router := gin.Default()
+	router.GET("/namespaces/all/:tenantId", handler.GetAllNamespaces)
+	router.DELETE("/namespaces/:tenantId/:name", handler.DeleteNamespace)
+	router.POST("/namespaces/:tenantId/:name/restore", handler.RestoreNamespace)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodDelete, "/namespaces/test-tenant/test-namespace")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"deletionTimestamp"`)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/namespaces/test-tenant/test-namespace").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/namespaces/test-tenant/non-existent-namespace").Code)
+
+	rec = serve(http.MethodGet, "/namespaces/all/test-tenant")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	rec = serve(http.MethodGet, "/namespaces/all/test-tenant?includeDeleted=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"test-namespace"`)
+
+	rec = serve(http.MethodPost, "/namespaces/test-tenant/test-namespace/restore")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/test-tenant/test-namespace/restore").Code)
+}
+
+func TestNamespaceHandler_EphemeralNamespace(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/namespaces/:tenantId", handler.CreateNamespace)
+	router.POST("/namespaces/:tenantId/:name/renew", handler.RenewNamespace)
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/namespaces/test-tenant", `{"name":"preview","ttl":"2h"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), *created.ExpiresAt, time.Minute)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/namespaces/test-tenant", `{"name":"other","ttl":"soon"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/namespaces/test-tenant", `{"name":"other","ttl":"-1h"}`).Code)
+	assert.Equal(t, http.StatusBadRequest,
+		serve("/namespaces/test-tenant", `{"name":"other","ttl":"1h","expiresAt":"2030-01-01T00:00:00Z"}`).Code)
+
+	rec = serve("/namespaces/test-tenant/preview/renew", `{"ttl":"48h"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var renewed domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &renewed))
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), *renewed.ExpiresAt, time.Minute)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/namespaces/test-tenant/preview/renew", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve("/namespaces/test-tenant/missing/renew", `{"ttl":"1h"}`).Code)
+}
+
+func TestNamespaceHandler_SetIsolation(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/namespaces/:tenantId", handler.CreateNamespace)
+	router.PUT("/namespaces/:tenantId/:name/isolation", handler.SetIsolation)
+	router.DELETE("/namespaces/:tenantId/:name/isolation", handler.ResetIsolation)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated,
+		serve(http.MethodPost, "/namespaces/test-tenant", `{"name":"team-a","isolation":{"denyEgress":true}}`).Code)
+	assert.Equal(t, http.StatusBadRequest,
+		serve(http.MethodPost, "/namespaces/test-tenant", `{"name":"team-b","isolation":{"allowTenants":[""]}}`).Code)
+
+	rec := serve(http.MethodPut, "/namespaces/test-tenant/team-a/isolation", `{"allowTenants":["partner"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var updated domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, &domain.NetworkIsolation{AllowTenants: []string{"partner"}}, updated.Isolation)
+
+	rec = serve(http.MethodDelete, "/namespaces/test-tenant/team-a/isolation", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "isolation")
+
+	assert.Equal(t, http.StatusBadRequest,
+		serve(http.MethodPut, "/namespaces/test-tenant/team-a/isolation", `{"allowTenants":["a","a"]}`).Code)
+	assert.Equal(t, http.StatusNotFound,
+		serve(http.MethodPut, "/namespaces/test-tenant/missing/isolation", `{}`).Code)
+}
+
+func TestNamespaceHandler_Hierarchy(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/namespaces/:tenantId", handler.CreateNamespace)
+	router.DELETE("/namespaces/:tenantId/:name", handler.DeleteNamespace)
+	router.GET("/namespaces/:tenantId/:name/subtree", handler.GetSubtree)
+	router.PUT("/namespaces/:tenantId/:name/parent", handler.SetParent)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"org"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-a","parent":"org"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme", `{"name":"lost","parent":"missing"}`).Code)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/namespaces/acme/team-b/parent", `{"parent":"team-a"}`).Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPut, "/namespaces/acme/org/parent", `{"parent":"team-b"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/namespaces/acme/org/parent", `{"parent":"missing"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/namespaces/acme/missing/parent", `{}`).Code)
+
+	rec := serve(http.MethodGet, "/namespaces/acme/org/subtree", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var subtree []domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &subtree))
+	if assert.Len(t, subtree, 3) {
+		assert.Equal(t, "team-b", subtree[2].Name)
+		assert.Equal(t, "team-a", subtree[2].Parent)
+	}
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/namespaces/acme/missing/subtree", "").Code)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/namespaces/acme/org", "").Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/namespaces/acme/team-a/parent", `{"parent":""}`).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/namespaces/acme/org", "").Code)
+}
+
+func TestNamespaceHandler_TransferNamespace(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	tenants := repositories.NewTenantRepository()
+	for _, id := range []string{"acme", "globex"} {
+		assert.NoError(t, tenants.CreateTenant(context.Background(), &domain.Tenant{ID: id, Status: domain.TenantActive}))
+	}
+	service := service.NewNamespaceService(repo, tenants, nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/namespaces/:tenantId", handler.CreateNamespace)
+	router.POST("/namespaces/:tenantId/:name/transfer", handler.TransferNamespace)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-a"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"team-b"}`).Code)
+	// Another tenant can't have a namespace of the same name on the cluster
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/globex", `{"name":"team-b"}`).Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/globex", `{"name":"org"}`).Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/team-a/transfer", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/team-a/transfer", `{"tenant":"acme"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/team-a/transfer", `{"tenant":"initech"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/team-a/transfer", `{"tenant":"globex","parent":"missing"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/namespaces/acme/missing/transfer", `{"tenant":"globex"}`).Code)
+
+	rec := serve(http.MethodPost, "/namespaces/acme/team-a/transfer", `{"tenant":"globex","parent":"org"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var namespace domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &namespace))
+	assert.Equal(t, "org", namespace.Parent)
+	assert.Equal(t, []string{"acme"}, namespace.FormerTenants())
+
+	_, err := repo.GetNamespace(context.Background(), "globex", "team-a")
+	assert.NoError(t, err)
+}
+
+func TestNamespaceHandler_CloneNamespace(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/namespaces/:tenantId", handler.CreateNamespace)
+	router.POST("/namespaces/:tenantId/:name/clone", handler.CloneNamespace)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/namespaces/acme", `{"name":"staging","quota":{"pods":"10"}}`).Code)
+
+	rec := serve(http.MethodPost, "/namespaces/acme/staging/clone", `{"name":"staging-2","tenant":"globex","ttl":"1h","labels":{"tier":"gold"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var namespace domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &namespace))
+	assert.Equal(t, "staging-2", namespace.Name)
+	assert.Equal(t, map[string]string{"pods": "10"}, namespace.Quota)
+	assert.Equal(t, map[string]string{"tier": "gold"}, namespace.Labels)
+	assert.NotNil(t, namespace.ExpiresAt)
+	_, err := repo.GetNamespace(context.Background(), "globex", "staging-2")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/namespaces/acme/staging/clone", `{"name":"staging"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/namespaces/acme/missing/clone", `{"name":"copy"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/staging/clone", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/staging/clone", `{"name":"copy","ttl":"soon"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme/staging/clone", `{"name":"copy","quota":{"pods":"many"}}`).Code)
+}
+
+func TestNamespaceHandler_PatchNamespace(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.GET("/namespaces/:tenantId/:name", handler.GetNamespace)
+	router.PATCH("/namespaces/:tenantId/:name", handler.PatchNamespace)
+
+	serve := func(method, path, contentType, ifMatch, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.NoError(t, service.CreateNamespace(context.Background(), "acme", &domain.Namespace{Name: "team-a"}))
+	rec := serve(http.MethodGet, "/namespaces/acme/team-a", "", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rec = serve(http.MethodPatch, "/namespaces/acme/team-a", "application/merge-patch+json", etag, `{"quota":{"pods":"10"}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var namespace domain.Namespace
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &namespace))
+	assert.Equal(t, map[string]string{"pods": "10"}, namespace.Quota)
+	assert.Equal(t, `"`+namespace.ResourceVersion+`"`, rec.Header().Get("ETag"))
+
+	rec = serve(http.MethodPatch, "/namespaces/acme/team-a", "application/json-patch+json", "", `[{"op":"add","path":"/labels","value":{"tier":"gold"}}]`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &namespace))
+	assert.Equal(t, map[string]string{"tier": "gold"}, namespace.Labels)
+	assert.Equal(t, map[string]string{"pods": "10"}, namespace.Quota)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPatch, "/namespaces/acme/team-a", "application/merge-patch+json", etag, `{"quota":null}`).Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve(http.MethodPatch, "/namespaces/acme/team-a", "application/json", "", `{"quota":null}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPatch, "/namespaces/acme/team-a", "application/merge-patch+json", "", `{"cluster":"eu"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPatch, "/namespaces/acme/team-a", "application/json-patch+json", "", `[{"op":"move"}]`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPatch, "/namespaces/acme/team-a", "application/merge-patch+json", "", `{"quota":{"pods":"many"}}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPatch, "/namespaces/acme/missing", "application/merge-patch+json", "", `{}`).Code)
+}
+
+func TestNamespaceHandler_AdoptNamespaces(t *testing.T) {
+	service := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewNamespaceHandler(service)
+
+	router := gin.Default()
+	router.POST("/adoptions", handler.AdoptNamespaces)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/adoptions", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, serve(`{"rules":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(`{"cluster":"east","rules":[{"tenant":"acme"}]}`).Code)
+	// Without a cluster connection there is nothing to adopt from
+	assert.Equal(t, http.StatusServiceUnavailable, serve(`{"rules":[{"tenant":"acme"}],"dryRun":true}`).Code)
+}
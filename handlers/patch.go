@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "naas/domain"
+)
+
+// readPatch reads a merge patch or JSON patch from the request body, with
+// the If-Match header as its precondition. It responds with 415 for other
+// content types and returns false.
+func readPatch(c *gin.Context) (Patch, bool) {
+	patch := Patch{Type: PatchType(c.ContentType())}
+	switch patch.Type {
+	case MergePatch, JSONPatch:
+	default:
+		respondError(c, http.StatusUnsupportedMediaType,
+			fmt.Errorf("content type must be %s or %s", MergePatch, JSONPatch))
+		return Patch{}, false
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return Patch{}, false
+	}
+	patch.Data = data
+
+	if match := c.GetHeader("If-Match"); match != "" && match != "*" {
+		patch.ResourceVersion = strings.Trim(strings.TrimPrefix(match, "W/"), `"`)
+	}
+	return patch, true
+}
+
+// setETag sets the ETag header to the resource version of the object in
+// the response, for clients to send back in If-Match.
+func setETag(c *gin.Context, resourceVersion string) {
+	if resourceVersion != "" {
+		c.Header("ETag", strconv.Quote(resourceVersion))
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	. "naas/domain"
+	. "naas/service"
+)
+
+// TemplateHandler serves both global templates and the templates of a
+// tenant. The scope comes from the :id path parameter, which is absent on
+// the global routes.
+type TemplateHandler struct {
+	service *TemplateService
+}
+
+func NewTemplateHandler(service *TemplateService) *TemplateHandler {
+	return &TemplateHandler{service: service}
+}
+
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	var template Template
+	if err := c.ShouldBindJSON(&template); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	template.TenantID = c.Param("id")
+
+	if err := h.service.CreateTemplate(c.Request.Context(), &template); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	template, err := h.service.GetTemplate(c.Request.Context(), c.Param("id"), c.Param("name"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	templates, err := h.service.ListTemplates(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	if err := h.service.DeleteTemplate(c.Request.Context(), c.Param("id"), c.Param("name")); err != nil {
+		respondError(c, errorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/handlers"
+	"naas/repositories"
+	"naas/service"
+)
+
+func TestTemplateHandler(t *testing.T) {
+	templateService := service.NewTemplateService(repositories.NewTemplateRepository())
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), templateService, nil, nil, nil, time.Hour)
+	handler := handlers.NewTemplateHandler(templateService)
+	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+
+	router := gin.Default()
+	router.GET("/templates", handler.ListTemplates)
+	router.POST("/templates", handler.CreateTemplate)
+	router.GET("/tenants/:id/templates/:name", handler.GetTemplate)
+	router.POST("/tenants/:id/templates", handler.CreateTemplate)
+	router.DELETE("/tenants/:id/templates/:name", handler.DeleteTemplate)
+	router.POST("/namespaces/:tenantId", namespaceHandler.CreateNamespace)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	template := `{"name":"team","parameters":[{"name":"team","required":true}],"spec":{"labels":{"team":"${team}"}}}`
+	rec := serve(http.MethodPost, "/tenants/acme/templates", template)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"tenantId":"acme"`)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/tenants/acme/templates", template).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/templates", `{"name":"Bad Name"}`).Code)
+
+	rec = serve(http.MethodGet, "/templates", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/tenants/acme/templates/team", "").Code)
+
+	rec = serve(http.MethodPost, "/namespaces/acme", `{"name":"payments","template":"team","parameters":{"team":"payments"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"labels":{"team":"payments"}`)
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme", `{"name":"other","template":"team"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/namespaces/acme", `{"name":"other","template":"missing"}`).Code)
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/tenants/acme/templates/team", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/tenants/acme/templates/team", "").Code)
+}
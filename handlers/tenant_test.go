@@ -1,24 +1,31 @@
 package handlers_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"io"
 	"naas/service"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"naas/config"
 	"naas/domain"
 	"naas/handlers"
+	"naas/middleware"
+	"naas/reconciler"
 	"naas/repositories"
 )
 
 func TestTenantHandler_CreateTenant(t *testing.T) {
 	repo := repositories.NewTenantRepository()
-	service := service.NewTenantService(repo)
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
 	handler := handlers.NewTenantHandler(service)
 
 	router := gin.Default()
@@ -44,7 +51,12 @@ func TestTenantHandler_CreateTenant(t *testing.T) {
 	response := &domain.Tenant{}
 	err = json.Unmarshal(w.Body.Bytes(), response)
 	assert.NoError(t, err)
-	assert.Equal(t, tenant, response)
+	// New tenants start out active, at their first version
+	expected := *tenant
+	expected.Status = domain.TenantActive
+	expected.ResourceVersion = response.ResourceVersion
+	assert.Equal(t, &expected, response)
+	assert.NotEmpty(t, response.ResourceVersion)
 
 	// Test creating the same tenant twice
 	w = httptest.NewRecorder()
@@ -57,11 +69,21 @@ func TestTenantHandler_CreateTenant(t *testing.T) {
 	assert.Equal(t, http.StatusConflict, w.Code)
 	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
 	assert.Contains(t, w.Body.String(), "tenant already exists")
+
+	// IDs end up in labels and paths, so they must be DNS labels
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "/tenants", bytes.NewBufferString(`{"id":"../Acme"}`))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid tenant")
 }
 
 func TestTenantHandler_GetTenant(t *testing.T) {
 	repo := repositories.NewTenantRepository()
-	service := service.NewTenantService(repo)
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
 	handler := handlers.NewTenantHandler(service)
 
 	router := gin.Default()
@@ -71,7 +93,7 @@ func TestTenantHandler_GetTenant(t *testing.T) {
 		ID:   "test-tenant",
 		Name: "Test Tenant",
 	}
-	err := repo.CreateTenant(tenant)
+	err := repo.CreateTenant(context.Background(), tenant)
 	assert.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodGet, "/tenants/test-tenant", nil)
@@ -102,7 +124,7 @@ func TestTenantHandler_GetTenant(t *testing.T) {
 
 func TestTenantHandler_ListTenants(t *testing.T) {
 	repo := repositories.NewTenantRepository()
-	service := service.NewTenantService(repo)
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
 	handler := handlers.NewTenantHandler(service)
 
 	// Define some mock tenants
@@ -110,8 +132,8 @@ func TestTenantHandler_ListTenants(t *testing.T) {
 	tenant2 := domain.Tenant{ID: "2", Name: "Tenant 2"}
 	tenant3 := domain.Tenant{ID: "3", Name: "Tenant 3"}
 	tenants := []domain.Tenant{tenant1, tenant2, tenant3}
-	for _, tenant := range tenants {
-		err := repo.CreateTenant(&tenant)
+	for i := range tenants {
+		err := repo.CreateTenant(context.Background(), &tenants[i])
 		assert.NoError(t, err)
 	}
 	// Mock the ListTenants method of the repository to return the mock tenants
@@ -136,3 +158,244 @@ func TestTenantHandler_ListTenants(t *testing.T) {
 	assert.ElementsMatch(t, expectedOutput, tenantsOut)
 
 }
+
+func TestTenantHandler_DeadlineExceeded(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+	handler := handlers.NewTenantHandler(service)
+
+	router := gin.Default()
+	router.Use(middleware.Deadline(time.Nanosecond))
+	router.GET("/tenants", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		handler.ListTenants(c)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/tenants", nil)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "context deadline exceeded")
+}
+
+func TestTenantHandler_SuspendTenant(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+	handler := handlers.NewTenantHandler(service)
+
+	router := gin.Default()
+	router.POST("/tenants/:id/suspend", handler.SuspendTenant)
+	router.POST("/tenants/:id/activate", handler.ActivateTenant)
+
+	err := service.CreateTenant(context.Background(), &domain.Tenant{ID: "test-tenant", Name: "Test Tenant"})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/tenants/test-tenant/suspend", bytes.NewBufferString(`{"reason":"unpaid"}`))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	response := &domain.Tenant{}
+	err = json.Unmarshal(w.Body.Bytes(), response)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TenantSuspended, response.Status)
+	assert.Equal(t, "unpaid", response.StatusReason)
+
+	// Suspending twice is a conflict
+	req, err = http.NewRequest(http.MethodPost, "/tenants/test-tenant/suspend", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Activating without a body
+	req, err = http.NewRequest(http.MethodPost, "/tenants/test-tenant/activate", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"Active"`)
+
+	req, err = http.NewRequest(http.MethodPost, "/tenants/non-existent-tenant/suspend", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTenantHandler_DeleteTenant(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+	handler := handlers.NewTenantHandler(service)
+
+	router := gin.Default()
+	router.GET("/tenants", handler.ListTenants)
+	router.DELETE("/tenants/:id", handler.DeleteTenant)
+	router.POST("/tenants/:id/restore", handler.RestoreTenant)
+
+	err := service.CreateTenant(context.Background(), &domain.Tenant{ID: "test-tenant", Name: "Test Tenant"})
+	assert.NoError(t, err)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodDelete, "/tenants/test-tenant")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deletionTimestamp"`)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/tenants/test-tenant").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/tenants/non-existent-tenant").Code)
+
+	w = serve(http.MethodGet, "/tenants")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = serve(http.MethodGet, "/tenants?includeDeleted=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"test-tenant"`)
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/tenants?includeDeleted=maybe").Code)
+
+	w = serve(http.MethodPost, "/tenants/test-tenant/restore")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"deletionTimestamp"`)
+
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/tenants/test-tenant/restore").Code)
+}
+
+func TestTenantHandler_Members(t *testing.T) {
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+	handler := handlers.NewTenantHandler(tenantService)
+	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+
+	router := gin.Default()
+	router.POST("/tenants", handler.CreateTenant)
+	router.GET("/tenants/:id/members", handler.ListMembers)
+	router.PUT("/tenants/:id/members/:user", handler.SetMember)
+	router.DELETE("/tenants/:id/members/:user", handler.RemoveMember)
+	router.GET("/namespaces/:tenantId/:name/bindings", namespaceHandler.GetBindings)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest,
+		serve(http.MethodPost, "/tenants", `{"id":"other","members":[{"user":"alice","role":"owner"}]}`).Code)
+	assert.Equal(t, http.StatusCreated,
+		serve(http.MethodPost, "/tenants", `{"id":"acme","members":[{"user":"alice","role":"admin"}]}`).Code)
+
+	w := serve(http.MethodPut, "/tenants/acme/members/bob", `{"role":"developer"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodGet, "/tenants/acme/members", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"user":"alice","role":"admin"},{"user":"bob","role":"developer"}]`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/tenants/acme/members/bob", `{"role":"owner"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, "/tenants/acme/members/bob", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/tenants/missing/members/bob", `{"role":"viewer"}`).Code)
+
+	assert.NoError(t, namespaceService.CreateNamespace(context.Background(), "acme", &domain.Namespace{Name: "team-a"}))
+	w = serve(http.MethodGet, "/namespaces/acme/team-a/bindings", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bindings []domain.Binding
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bindings))
+	assert.Len(t, bindings, 2)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/namespaces/acme/missing/bindings", "").Code)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/tenants/acme/members/alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/tenants/acme/members/alice", "").Code)
+	w = serve(http.MethodGet, "/namespaces/acme/team-a/bindings", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bindings))
+	assert.Len(t, bindings, 1)
+}
+
+func TestTenantHandler_PatchTenant(t *testing.T) {
+	tenantService := service.NewTenantService(repositories.NewTenantRepository(), repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+	handler := handlers.NewTenantHandler(tenantService)
+
+	router := gin.Default()
+	router.PATCH("/tenants/:id", handler.PatchTenant)
+
+	serve := func(contentType, ifMatch, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPatch, "/tenants/acme", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve("application/merge-patch+json", "", `{"name":"Acme"}`).Code)
+
+	tenant := &domain.Tenant{ID: "acme", Members: []domain.Member{{User: "alice", Role: domain.MemberAdmin}}}
+	assert.NoError(t, tenantService.CreateTenant(context.Background(), tenant))
+
+	w := serve("application/merge-patch+json; charset=utf-8", `"`+tenant.ResourceVersion+`"`, `{"name":"Acme"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var patched domain.Tenant
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &patched))
+	assert.Equal(t, "Acme", patched.Name)
+	assert.Equal(t, tenant.Members, patched.Members)
+	assert.Equal(t, `"`+patched.ResourceVersion+`"`, w.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusConflict, serve("application/merge-patch+json", `"`+tenant.ResourceVersion+`"`, `{"name":"Acme Corp"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("application/json-patch+json", "", `[{"op":"replace","path":"/members/0/role","value":"owner"}]`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("application/merge-patch+json", "", `{"status":"Suspended"}`).Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve("text/plain", "", `name=Acme`).Code)
+}
+
+func TestTenantHandler_GetManifests(t *testing.T) {
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, nil, nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+	handler := handlers.NewTenantHandler(tenantService)
+
+	router := gin.Default()
+	router.GET("/tenants/:id/manifests", handler.GetManifests)
+
+	assert.NoError(t, namespaceService.CreateNamespace(context.Background(), "acme", &domain.Namespace{Name: "team-a"}))
+
+	req, err := http.NewRequest(http.MethodGet, "/tenants/acme/manifests", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="acme.tar.gz"`, w.Header().Get("Content-Disposition"))
+
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	header, err := tar.NewReader(gz).Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "acme/local/team-a/namespace-team-a.yaml", header.Name)
+
+	req, err = http.NewRequest(http.MethodGet, "/tenants/other/manifests", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -3,10 +3,15 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	. "naas/domain"
+	"naas/reconciler"
 	. "naas/service"
 )
 
@@ -21,16 +26,12 @@ func NewTenantHandler(service *TenantService) *TenantHandler {
 func (h *TenantHandler) CreateTenant(c *gin.Context) {
 	var tenant Tenant
 	if err := c.ShouldBindJSON(&tenant); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if err := h.service.CreateTenant(&tenant); err != nil {
-		if err.Error() == "tenant already exists" {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+	if err := h.service.CreateTenant(c.Request.Context(), &tenant); err != nil {
+		respondError(c, tenantErrorStatus(err), err)
 
 		return
 	}
@@ -41,21 +42,190 @@ func (h *TenantHandler) CreateTenant(c *gin.Context) {
 func (h *TenantHandler) GetTenant(c *gin.Context) {
 	id := c.Param("id")
 
-	tenant, err := h.service.GetTenant(id)
+	tenant, err := h.service.GetTenant(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, http.StatusNotFound, err)
 		return
 	}
 
+	setETag(c, tenant.ResourceVersion)
 	c.JSON(http.StatusOK, tenant)
 }
 
+// PatchTenant changes the name and members of a tenant with a merge patch
+// or JSON patch. An If-Match header makes it conditional on the tenant's
+// resource version.
+func (h *TenantHandler) PatchTenant(c *gin.Context) {
+	patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	tenant, err := h.service.PatchTenant(c.Request.Context(), c.Param("id"), patch)
+	if err != nil {
+		respondError(c, tenantErrorStatus(err), err)
+		return
+	}
+
+	setETag(c, tenant.ResourceVersion)
+	c.JSON(http.StatusOK, tenant)
+}
+
+// GetManifests downloads the manifests of the tenant's namespaces as a
+// gzipped tarball, for GitOps tools to apply.
+func (h *TenantHandler) GetManifests(c *gin.Context) {
+	id := c.Param("id")
+
+	manifests, err := h.service.RenderManifests(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, tenantErrorStatus(err), err)
+		return
+	}
+
+	var archive bytes.Buffer
+	if err := reconciler.WriteTarball(&archive, id, manifests); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".tar.gz"))
+	c.Data(http.StatusOK, "application/gzip", archive.Bytes())
+}
+
 func (h *TenantHandler) ListTenants(c *gin.Context) {
-	tenants, err := h.service.ListTenants()
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+
+	tenants, err := h.service.ListTenants(c.Request.Context(), opts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, tenants)
 }
+
+type memberRequest struct {
+	Role MemberRole `json:"role" binding:"required"`
+}
+
+type transitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *TenantHandler) SuspendTenant(c *gin.Context) {
+	h.transition(c, TenantSuspended)
+}
+
+func (h *TenantHandler) ActivateTenant(c *gin.Context) {
+	h.transition(c, TenantActive)
+}
+
+func (h *TenantHandler) ArchiveTenant(c *gin.Context) {
+	h.transition(c, TenantArchived)
+}
+
+func (h *TenantHandler) transition(c *gin.Context, status TenantStatus) {
+	id := c.Param("id")
+
+	// The body, carrying an optional reason, may be omitted.
+	var req transitionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	tenant, err := h.service.TransitionTenant(c.Request.Context(), id, status, req.Reason)
+	if err != nil {
+		respondError(c, tenantErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) DeleteTenant(c *gin.Context) {
+	id := c.Param("id")
+
+	tenant, err := h.service.DeleteTenant(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, tenantErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) RestoreTenant(c *gin.Context) {
+	id := c.Param("id")
+
+	tenant, err := h.service.RestoreTenant(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, tenantErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) ListMembers(c *gin.Context) {
+	id := c.Param("id")
+
+	tenant, err := h.service.GetTenant(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+
+	members := tenant.Members
+	if members == nil {
+		members = []Member{}
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *TenantHandler) SetMember(c *gin.Context) {
+	id := c.Param("id")
+
+	var req memberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tenant, err := h.service.SetMember(c.Request.Context(), id, Member{User: c.Param("user"), Role: req.Role})
+	if err != nil {
+		h.respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+
+	tenant, err := h.service.RemoveMember(c.Request.Context(), id, c.Param("user"))
+	if err != nil {
+		h.respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) respondMemberError(c *gin.Context, err error) {
+	respondError(c, tenantErrorStatus(err), err)
+}
+
+// tenantErrorStatus is errorStatus for the tenant routes, where an archived
+// tenant conflicts with the change rather than forbidding it.
+func tenantErrorStatus(err error) int {
+	if errors.Is(err, ErrTenantArchived) {
+		return http.StatusConflict
+	}
+	return errorStatus(err)
+}
@@ -0,0 +1,36 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/health"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.AddLivenessCheck("deadlock", func(context.Context) error { return nil })
+	registry.AddReadinessCheck("storage", func(context.Context) error { return errors.New("unreachable") })
+
+	liveness := registry.Liveness(context.Background())
+	assert.Equal(t, []health.Result{{Name: "ping"}, {Name: "deadlock"}}, liveness)
+	assert.True(t, health.Healthy(liveness))
+
+	readiness := registry.Readiness(context.Background())
+	assert.Len(t, readiness, 3)
+	assert.False(t, health.Healthy(readiness))
+
+	readiness = registry.Readiness(context.Background(), "storage")
+	assert.Len(t, readiness, 2)
+	assert.True(t, health.Healthy(readiness))
+}
+
+func TestGate(t *testing.T) {
+	var gate health.Gate
+	assert.EqualError(t, gate.Check(context.Background()), "not started")
+
+	gate.Open()
+	assert.NoError(t, gate.Check(context.Background()))
+}
@@ -0,0 +1,362 @@
+// kube/client.go
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"naas/config"
+)
+
+const (
+	FieldManager = "naas"
+
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	ManagedBy      = "naas"
+	LabelTenant    = "naas.io/tenant"
+)
+
+// ManagedSelector selects the objects naas owns.
+var ManagedSelector = LabelManagedBy + "=" + ManagedBy
+
+// Client applies the objects naas manages to a cluster.
+type Client struct {
+	clientset kubernetes.Interface
+}
+
+func New(clientset kubernetes.Interface) *Client {
+	return &Client{clientset: clientset}
+}
+
+// NewFromConfig connects to the cluster selected by cfg. It returns nil
+// when cfg.Mode is none.
+func NewFromConfig(cfg config.Kubernetes) (*Client, error) {
+	var restConfig *rest.Config
+	var err error
+	switch cfg.Mode {
+	case config.KubernetesNone:
+		return nil, nil
+	case config.KubernetesInCluster:
+		restConfig, err = rest.InClusterConfig()
+	case config.KubernetesKubeconfig:
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = cfg.Kubeconfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	default:
+		return nil, fmt.Errorf("unknown kubernetes mode %q", cfg.Mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading kubernetes config: %w", err)
+	}
+	return NewFromRESTConfig(restConfig)
+}
+
+// NewFromRESTConfig builds a client whose requests carry the trace context
+// of the calling request.
+func NewFromRESTConfig(restConfig *rest.Config) (*Client, error) {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.UserAgent = "naas"
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt)
+	})
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return New(clientset), nil
+}
+
+func (c *Client) Clientset() kubernetes.Interface {
+	return c.clientset
+}
+
+// Ping checks that the API server is reachable and ready.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.clientset.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+}
+
+// ApplyNamespace creates the namespace or merges desired's labels and
+// annotations into the existing one. It refuses to touch namespaces that
+// naas doesn't manage or that belong to another tenant than formerOwners,
+// which the namespace is taken over from.
+func (c *Client) ApplyNamespace(ctx context.Context, desired *corev1.Namespace, formerOwners ...string) error {
+	namespaces := c.clientset.CoreV1().Namespaces()
+
+	existing, err := namespaces.Get(ctx, desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = namespaces.Create(ctx, desired, metav1.CreateOptions{FieldManager: FieldManager})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Labels[LabelManagedBy] != ManagedBy {
+		return fmt.Errorf("namespace %s exists in the cluster and is not managed by naas", desired.Name)
+	}
+	if owner := existing.Labels[LabelTenant]; owner != desired.Labels[LabelTenant] && !contains(formerOwners, owner) {
+		return fmt.Errorf("namespace %s belongs to tenant %s in the cluster", desired.Name, owner)
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels = merge(updated.Labels, desired.Labels)
+	updated.Annotations = merge(updated.Annotations, desired.Annotations)
+	_, err = namespaces.Update(ctx, updated, metav1.UpdateOptions{FieldManager: FieldManager})
+	return err
+}
+
+// ListNamespaces returns every namespace of the cluster.
+func (c *Client) ListNamespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	list, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// AdoptNamespace labels an existing namespace as managed by naas for
+// tenantID. It refuses namespaces that another tool manages or that belong
+// to another tenant; adopting the same namespace twice is a no-op.
+func (c *Client) AdoptNamespace(ctx context.Context, name, tenantID string) error {
+	namespaces := c.clientset.CoreV1().Namespaces()
+
+	existing, err := namespaces.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if manager, ok := existing.Labels[LabelManagedBy]; ok && manager != ManagedBy {
+		return fmt.Errorf("namespace %s is managed by %s", name, manager)
+	}
+	if owner, ok := existing.Labels[LabelTenant]; ok && owner != tenantID {
+		return fmt.Errorf("namespace %s belongs to tenant %s in the cluster", name, owner)
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels = merge(updated.Labels, map[string]string{LabelManagedBy: ManagedBy, LabelTenant: tenantID})
+	_, err = namespaces.Update(ctx, updated, metav1.UpdateOptions{FieldManager: FieldManager})
+	return err
+}
+
+// DeleteNamespace deletes a namespace naas manages for tenantID, along with
+// everything in it. Namespaces that are already gone are ignored, and so are
+// namespaces naas doesn't manage for tenantID: there is nothing of the
+// tenant's left to delete, and they are not naas's to remove.
+func (c *Client) DeleteNamespace(ctx context.Context, name, tenantID string) error {
+	namespaces := c.clientset.CoreV1().Namespaces()
+
+	existing, err := namespaces.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.Labels[LabelManagedBy] != ManagedBy || existing.Labels[LabelTenant] != tenantID {
+		return nil
+	}
+
+	err = namespaces.Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Live is a namespace and the objects naas manages in it, as found in the
+// cluster.
+type Live struct {
+	// Namespace is nil when the namespace doesn't exist.
+	Namespace       *corev1.Namespace
+	NetworkPolicies []networkingv1.NetworkPolicy
+	ResourceQuotas  []corev1.ResourceQuota
+	Roles           []rbacv1.Role
+	RoleBindings    []rbacv1.RoleBinding
+}
+
+// Observe reads the live state of a namespace. Objects that lost the
+// managed-by label aren't returned, as naas no longer recognizes them.
+func (c *Client) Observe(ctx context.Context, name string) (*Live, error) {
+	live := &Live{}
+
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return live, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	live.Namespace = ns
+
+	managed := metav1.ListOptions{LabelSelector: ManagedSelector}
+	policies, err := c.clientset.NetworkingV1().NetworkPolicies(name).List(ctx, managed)
+	if err != nil {
+		return nil, fmt.Errorf("network policies: %w", err)
+	}
+	live.NetworkPolicies = policies.Items
+	quotas, err := c.clientset.CoreV1().ResourceQuotas(name).List(ctx, managed)
+	if err != nil {
+		return nil, fmt.Errorf("resource quotas: %w", err)
+	}
+	live.ResourceQuotas = quotas.Items
+	roles, err := c.clientset.RbacV1().Roles(name).List(ctx, managed)
+	if err != nil {
+		return nil, fmt.Errorf("roles: %w", err)
+	}
+	live.Roles = roles.Items
+	bindings, err := c.clientset.RbacV1().RoleBindings(name).List(ctx, managed)
+	if err != nil {
+		return nil, fmt.Errorf("role bindings: %w", err)
+	}
+	live.RoleBindings = bindings.Items
+	return live, nil
+}
+
+func (c *Client) ApplyNetworkPolicies(ctx context.Context, namespace string, desired []*networkingv1.NetworkPolicy) error {
+	client := c.clientset.NetworkingV1().NetworkPolicies(namespace)
+
+	existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(existing.Items))
+	for _, item := range existing.Items {
+		names = append(names, item.Name)
+	}
+	return syncManaged[*networkingv1.NetworkPolicy](ctx, client, names, desired)
+}
+
+func (c *Client) ApplyResourceQuotas(ctx context.Context, namespace string, desired []*corev1.ResourceQuota) error {
+	client := c.clientset.CoreV1().ResourceQuotas(namespace)
+
+	existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(existing.Items))
+	for _, item := range existing.Items {
+		names = append(names, item.Name)
+	}
+	return syncManaged[*corev1.ResourceQuota](ctx, client, names, desired)
+}
+
+func (c *Client) ApplyRoles(ctx context.Context, namespace string, desired []*rbacv1.Role) error {
+	client := c.clientset.RbacV1().Roles(namespace)
+
+	existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(existing.Items))
+	for _, item := range existing.Items {
+		names = append(names, item.Name)
+	}
+	return syncManaged[*rbacv1.Role](ctx, client, names, desired)
+}
+
+// ApplyRoleBindings syncs the managed role bindings of a namespace. Since
+// the role a binding refers to can't be changed, bindings whose role
+// differs from the desired one are recreated.
+func (c *Client) ApplyRoleBindings(ctx context.Context, namespace string, desired []*rbacv1.RoleBinding) error {
+	client := c.clientset.RbacV1().RoleBindings(namespace)
+
+	existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ManagedSelector})
+	if err != nil {
+		return err
+	}
+	roles := make(map[string]rbacv1.RoleRef, len(desired))
+	for _, binding := range desired {
+		roles[binding.Name] = binding.RoleRef
+	}
+	names := make([]string, 0, len(existing.Items))
+	for _, item := range existing.Items {
+		if role, ok := roles[item.Name]; ok && role != item.RoleRef {
+			err := client.Delete(ctx, item.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("deleting %s: %w", item.Name, err)
+			}
+			continue
+		}
+		names = append(names, item.Name)
+	}
+	return syncManaged[*rbacv1.RoleBinding](ctx, client, names, desired)
+}
+
+// resource is the subset of the typed client-go clients used by syncManaged.
+type resource[T metav1.Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Create(ctx context.Context, obj T, opts metav1.CreateOptions) (T, error)
+	Update(ctx context.Context, obj T, opts metav1.UpdateOptions) (T, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+}
+
+// syncManaged makes the managed objects of one kind in a namespace match desired:
+// missing objects are created, existing ones replaced and the managed
+// objects named in existing but absent from desired are deleted.
+func syncManaged[T metav1.Object](ctx context.Context, client resource[T], existing []string, desired []T) error {
+	keep := make(map[string]bool, len(desired))
+	for _, obj := range desired {
+		keep[obj.GetName()] = true
+		if err := apply(ctx, client, obj); err != nil {
+			return fmt.Errorf("applying %s: %w", obj.GetName(), err)
+		}
+	}
+
+	for _, name := range existing {
+		if keep[name] {
+			continue
+		}
+		err := client.Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("deleting %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func apply[T metav1.Object](ctx context.Context, client resource[T], desired T) error {
+	existing, err := client.Get(ctx, desired.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, desired, metav1.CreateOptions{FieldManager: FieldManager})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, desired, metav1.UpdateOptions{FieldManager: FieldManager})
+	return err
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if dst == nil && len(src) > 0 {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
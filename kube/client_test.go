@@ -0,0 +1,164 @@
+package kube_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"naas/kube"
+)
+
+func managedNamespace(name, tenantID string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: name,
+		Labels: map[string]string{
+			kube.LabelManagedBy: kube.ManagedBy,
+			kube.LabelTenant:    tenantID,
+		},
+	}}
+}
+
+func TestClient_ApplyNamespace(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+	)
+	client := kube.New(clientset)
+
+	assert.NoError(t, client.ApplyNamespace(ctx, managedNamespace("team-a", "acme")))
+
+	// Labels set by others are kept
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	ns.Labels["owner"] = "someone"
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.ApplyNamespace(ctx, managedNamespace("team-a", "acme")))
+	ns, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "someone", ns.Labels["owner"])
+
+	err = client.ApplyNamespace(ctx, managedNamespace("team-a", "other"))
+	assert.EqualError(t, err, "namespace team-a belongs to tenant acme in the cluster")
+
+	err = client.ApplyNamespace(ctx, managedNamespace("unmanaged", "acme"))
+	assert.EqualError(t, err, "namespace unmanaged exists in the cluster and is not managed by naas")
+}
+
+func TestClient_DeleteNamespace(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset(
+		managedNamespace("team-a", "acme"),
+		managedNamespace("team-b", "other"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+	)
+	client := kube.New(clientset)
+
+	assert.NoError(t, client.DeleteNamespace(ctx, "team-a", "acme"))
+	_, err := clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// Namespaces that are gone, or aren't the tenant's, are left alone
+	assert.NoError(t, client.DeleteNamespace(ctx, "team-a", "acme"))
+	assert.NoError(t, client.DeleteNamespace(ctx, "team-b", "acme"))
+	assert.NoError(t, client.DeleteNamespace(ctx, "unmanaged", "acme"))
+	for _, name := range []string{"team-b", "unmanaged"} {
+		_, err = clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		assert.NoError(t, err)
+	}
+}
+
+func TestClient_ApplyNetworkPolicies(t *testing.T) {
+	ctx := context.Background()
+	labels := map[string]string{kube.LabelManagedBy: kube.ManagedBy}
+	clientset := fake.NewSimpleClientset(
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "team-a", Labels: labels}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "user-owned", Namespace: "team-a"}},
+	)
+	client := kube.New(clientset)
+
+	desired := []*networkingv1.NetworkPolicy{
+		{ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "team-a", Labels: labels}},
+	}
+	assert.NoError(t, client.ApplyNetworkPolicies(ctx, "team-a", desired))
+	// Applying again updates in place
+	assert.NoError(t, client.ApplyNetworkPolicies(ctx, "team-a", desired))
+
+	list, err := clientset.NetworkingV1().NetworkPolicies("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	assert.ElementsMatch(t, []string{"deny-all", "user-owned"}, names)
+}
+
+func TestClient_ApplyRoleBindings(t *testing.T) {
+	ctx := context.Background()
+	labels := map[string]string{kube.LabelManagedBy: kube.ManagedBy}
+	clientset := fake.NewSimpleClientset()
+	client := kube.New(clientset)
+
+	binding := func(role string) []*rbacv1.RoleBinding {
+		return []*rbacv1.RoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers", Namespace: "team-a", Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "devs"}},
+		}}
+	}
+	assert.NoError(t, client.ApplyRoleBindings(ctx, "team-a", binding("view")))
+	// The role of a binding can't be updated; it is recreated instead
+	assert.NoError(t, client.ApplyRoleBindings(ctx, "team-a", binding("edit")))
+
+	got, err := clientset.RbacV1().RoleBindings("team-a").Get(ctx, "developers", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "edit", got.RoleRef.Name)
+
+	assert.NoError(t, client.ApplyRoleBindings(ctx, "team-a", nil))
+	list, err := clientset.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestClient_SetWorkloadsSuspended(t *testing.T) {
+	ctx := context.Background()
+	three := int32(3)
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &three},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+		},
+	)
+	client := kube.New(clientset)
+
+	assert.NoError(t, client.SetWorkloadsSuspended(ctx, "team-a", true))
+	// Suspending twice keeps the original replica count
+	assert.NoError(t, client.SetWorkloadsSuspended(ctx, "team-a", true))
+
+	d, err := clientset.AppsV1().Deployments("team-a").Get(ctx, "web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *d.Spec.Replicas)
+	assert.Equal(t, "3", d.Annotations[kube.AnnotationSuspendedReplicas])
+
+	assert.NoError(t, client.SetWorkloadsSuspended(ctx, "team-a", false))
+
+	d, err = clientset.AppsV1().Deployments("team-a").Get(ctx, "web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), *d.Spec.Replicas)
+	assert.NotContains(t, d.Annotations, kube.AnnotationSuspendedReplicas)
+
+	s, err := clientset.AppsV1().StatefulSets("team-a").Get(ctx, "db", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *s.Spec.Replicas)
+}
@@ -0,0 +1,84 @@
+// kube/workloads.go
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationSuspendedReplicas records a workload's replica count while it is
+// scaled down, so it can be restored.
+const AnnotationSuspendedReplicas = "naas.io/suspended-replicas"
+
+// SetWorkloadsSuspended scales every Deployment and StatefulSet in namespace
+// to zero when suspended is true, and back to the recorded replica count
+// otherwise.
+func (c *Client) SetWorkloadsSuspended(ctx context.Context, namespace string, suspended bool) error {
+	deployments := c.clientset.AppsV1().Deployments(namespace)
+	list, err := deployments.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		d := &list.Items[i]
+		if scale(&d.ObjectMeta, &d.Spec.Replicas, suspended) {
+			if _, err := deployments.Update(ctx, d, metav1.UpdateOptions{FieldManager: FieldManager}); err != nil {
+				return fmt.Errorf("scaling deployment %s: %w", d.Name, err)
+			}
+		}
+	}
+
+	statefulSets := c.clientset.AppsV1().StatefulSets(namespace)
+	sets, err := statefulSets.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range sets.Items {
+		s := &sets.Items[i]
+		if scale(&s.ObjectMeta, &s.Spec.Replicas, suspended) {
+			if _, err := statefulSets.Update(ctx, s, metav1.UpdateOptions{FieldManager: FieldManager}); err != nil {
+				return fmt.Errorf("scaling statefulset %s: %w", s.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// scale updates replicas and the bookkeeping annotation in place and
+// reports whether anything changed.
+func scale(meta *metav1.ObjectMeta, replicas **int32, suspended bool) bool {
+	recorded, isSuspended := meta.Annotations[AnnotationSuspendedReplicas]
+
+	if suspended {
+		if isSuspended {
+			return false
+		}
+		current := int32(1)
+		if *replicas != nil {
+			current = **replicas
+		}
+		if meta.Annotations == nil {
+			meta.Annotations = make(map[string]string)
+		}
+		meta.Annotations[AnnotationSuspendedReplicas] = strconv.Itoa(int(current))
+		zero := int32(0)
+		*replicas = &zero
+		return true
+	}
+
+	if !isSuspended {
+		return false
+	}
+	restored, err := strconv.Atoi(recorded)
+	if err != nil {
+		restored = 1
+	}
+	count := int32(restored)
+	*replicas = &count
+	delete(meta.Annotations, AnnotationSuspendedReplicas)
+	return true
+}
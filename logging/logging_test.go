@@ -0,0 +1,39 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"naas/logging"
+)
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), logging.FromContext(context.Background()))
+
+	logger := logging.New(&bytes.Buffer{}, "info")
+	ctx := logging.WithLogger(context.Background(), logger)
+	assert.Equal(t, logger, logging.FromContext(ctx))
+}
+
+func TestNew_AddsTraceIDs(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, "info")
+
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, span := tracer.Start(context.Background(), "op")
+	defer span.End()
+
+	logger.DebugContext(ctx, "hidden")
+	logger.InfoContext(ctx, "visible")
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "visible", record["msg"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), record["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), record["span_id"])
+}
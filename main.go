@@ -3,50 +3,263 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log/slog"
+	"naas/config"
+	"naas/gitops"
 	"naas/handlers"
+	"naas/health"
+	"naas/kube"
+	"naas/logging"
+	"naas/metrics"
+	"naas/middleware"
+	"naas/reconciler"
 	"naas/repositories"
+	"naas/server"
 	"naas/service"
+	"naas/tracing"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.Level)
+	slog.SetDefault(logger)
+
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Start the signal context early so readiness can report shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logger.Error("setting up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize repositories
 	tenantRepo := repositories.NewTenantRepository()
 	namespaceRepo := repositories.NewNamespaceRepository()
+	templateRepo := repositories.NewTemplateRepository()
+	clusterRepo := repositories.NewClusterRepository()
+
+	// Initialize the cluster client. Without a cluster, namespaces that
+	// aren't placed on a registered cluster are only recorded.
+	kubeClient, err := kube.NewFromConfig(cfg.Kubernetes)
+	if err != nil {
+		logger.Error("connecting to kubernetes", "error", err)
+		os.Exit(1)
+	}
+	// Manifests are also committed to a Git repository when one is
+	// configured, for clusters managed through GitOps. Commits are made in
+	// the background.
+	gitRepo, err := gitops.Open(ctx, cfg.GitOps)
+	if err != nil {
+		logger.Error("opening gitops repository", "error", err)
+		os.Exit(1)
+	}
+	var sink reconciler.ManifestSink
+	if gitRepo != nil {
+		sink = gitRepo
+	}
+	rec := reconciler.New(tenantRepo, namespaceRepo, clusterRepo, kubeClient, reconciler.ConnectKubeconfig, sink, cfg.Network)
 
 	// Initialize services
-	tenantService := service.NewTenantService(tenantRepo)
-	namespaceService := service.NewNamespaceService(namespaceRepo)
+	retention := time.Duration(cfg.Deletion.Retention)
+	templateService := service.NewTemplateService(templateRepo)
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, rec)
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, finalizers, retention)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, templateService, clusterRepo, rec, finalizers, retention)
+	clusterService := service.NewClusterService(clusterRepo, namespaceRepo, rec)
+	bulkService := service.NewBulkService(tenantService, namespaceService, rec)
+	driftScanner := service.NewDriftScanner(namespaceRepo, rec, cfg.Drift.Remediate)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, retention)
+
+	// Initialize health checks
+	checks := health.NewRegistry()
+	startup := &health.Gate{}
+	checks.AddReadinessCheck("startup", startup.Check)
+	checks.AddReadinessCheck("storage", func(ctx context.Context) error {
+		_, err := tenantRepo.ListTenants(ctx)
+		return err
+	})
+	// Fails for the server's shutdown delay, while requests are still
+	// served, so traffic moves away before the listener closes.
+	checks.AddReadinessCheck("shutdown", func(context.Context) error {
+		if ctx.Err() != nil {
+			return errors.New("shutting down")
+		}
+		return nil
+	})
+
+	// Initialize metrics
+	m := metrics.New()
+	m.MustRegister(metrics.NewDomainCollector(tenantService, namespaceService, clusterService), metrics.NewDriftCollector(driftScanner))
+
+	m.MustRegister(rec.Collector())
+	if kubeClient != nil {
+		checks.AddReadinessCheck("kubernetes", kubeClient.Ping)
+	}
+	reaper := service.NewReaper(namespaceRepo, namespaceService, time.Duration(cfg.Expiry.WarningPeriod))
 
 	// Initialize handlers
+	healthHandler := handlers.NewHealthHandler(checks)
 	tenantHandler := handlers.NewTenantHandler(tenantService)
 	namespaceHandler := handlers.NewNamespaceHandler(namespaceService)
+	templateHandler := handlers.NewTemplateHandler(templateService)
+	clusterHandler := handlers.NewClusterHandler(clusterService)
+	driftHandler := handlers.NewDriftHandler(driftScanner)
+	bulkHandler := handlers.NewBulkHandler(bulkService)
 
 	// Initialize Gin router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(
+		otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+			return !isProbe(r.URL.Path)
+		})),
+		middleware.RequestLogger(logger, "/healthz", "/livez", "/readyz", "/metrics"),
+		middleware.Deadline(time.Duration(cfg.Server.RequestTimeout)),
+		gin.Recovery(),
+		m.Middleware(),
+	)
 
 	// Configure CORS middleware
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true // Allow all origins for development
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		for _, origin := range cfg.CORS.AllowedOrigins {
+			if origin == "*" {
+				corsConfig.AllowAllOrigins = true
+			}
+		}
+		if !corsConfig.AllowAllOrigins {
+			corsConfig.AllowOrigins = cfg.CORS.AllowedOrigins
+		}
+		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+		corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", middleware.IdempotencyKeyHeader}
+		corsConfig.ExposeHeaders = []string{"ETag"}
+		router.Use(cors.New(corsConfig))
+	}
 
-	// Apply CORS middleware to your Gin instance
-	router.Use(cors.New(config))
+	// Probes and metrics are served without authentication
+	router.GET("/healthz", healthHandler.Livez)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+
+	api := router.Group("/")
+	if len(cfg.Auth.Tokens) > 0 {
+		api.Use(middleware.BearerAuth(cfg.Auth.Tokens))
+	}
+	api.Use(middleware.DryRun())
+	if cfg.Idempotency.Window > 0 {
+		api.Use(middleware.Idempotency(time.Duration(cfg.Idempotency.Window), cfg.Idempotency.MaxKeys))
+	}
 
 	// Define routes
-	router.POST("/tenants", tenantHandler.CreateTenant)
-	router.GET("/tenants", tenantHandler.ListTenants)
-	router.GET("/tenants/:id", tenantHandler.GetTenant)
-	router.POST("/namespaces/:tenantId", namespaceHandler.CreateNamespace)
-	router.GET("/namespaces/all/:tenantId", namespaceHandler.GetAllNamespaces)
-	router.GET("/namespaces/:tenantId/:name", namespaceHandler.GetNamespace)
-
-	// Start server
-	err := router.Run(":8082")
-	if err != nil {
+	api.POST("/tenants", tenantHandler.CreateTenant)
+	api.GET("/tenants", tenantHandler.ListTenants)
+	api.GET("/tenants/:id", tenantHandler.GetTenant)
+	api.PATCH("/tenants/:id", tenantHandler.PatchTenant)
+	api.DELETE("/tenants/:id", tenantHandler.DeleteTenant)
+	api.POST("/tenants/:id/restore", tenantHandler.RestoreTenant)
+	api.POST("/tenants/:id/suspend", tenantHandler.SuspendTenant)
+	api.POST("/tenants/:id/activate", tenantHandler.ActivateTenant)
+	api.POST("/tenants/:id/archive", tenantHandler.ArchiveTenant)
+	api.GET("/tenants/:id/manifests", tenantHandler.GetManifests)
+	api.GET("/tenants/:id/members", tenantHandler.ListMembers)
+	api.PUT("/tenants/:id/members/:user", tenantHandler.SetMember)
+	api.DELETE("/tenants/:id/members/:user", tenantHandler.RemoveMember)
+	api.GET("/tenants/:id/drift", driftHandler.ListDrift)
+	api.GET("/tenants/:id/templates", templateHandler.ListTemplates)
+	api.POST("/tenants/:id/templates", templateHandler.CreateTemplate)
+	api.GET("/tenants/:id/templates/:name", templateHandler.GetTemplate)
+	api.DELETE("/tenants/:id/templates/:name", templateHandler.DeleteTemplate)
+	api.GET("/templates", templateHandler.ListTemplates)
+	api.POST("/templates", templateHandler.CreateTemplate)
+	api.GET("/templates/:name", templateHandler.GetTemplate)
+	api.DELETE("/templates/:name", templateHandler.DeleteTemplate)
+	api.GET("/drift", driftHandler.ListDrift)
+	api.GET("/clusters", clusterHandler.ListClusters)
+	api.POST("/clusters", clusterHandler.CreateCluster)
+	api.GET("/clusters/:name", clusterHandler.GetCluster)
+	api.PUT("/clusters/:name", clusterHandler.UpdateCluster)
+	api.DELETE("/clusters/:name", clusterHandler.DeleteCluster)
+	api.POST("/adoptions", namespaceHandler.AdoptNamespaces)
+	api.POST("/bulk", bulkHandler.ApplyBulk)
+	api.POST("/namespaces/:tenantId", namespaceHandler.CreateNamespace)
+	api.GET("/namespaces/all/:tenantId", namespaceHandler.GetAllNamespaces)
+	api.GET("/namespaces/:tenantId/:name", namespaceHandler.GetNamespace)
+	api.PATCH("/namespaces/:tenantId/:name", namespaceHandler.PatchNamespace)
+	api.DELETE("/namespaces/:tenantId/:name", namespaceHandler.DeleteNamespace)
+	api.POST("/namespaces/:tenantId/:name/restore", namespaceHandler.RestoreNamespace)
+	api.POST("/namespaces/:tenantId/:name/renew", namespaceHandler.RenewNamespace)
+	api.GET("/namespaces/:tenantId/:name/subtree", namespaceHandler.GetSubtree)
+	api.PUT("/namespaces/:tenantId/:name/parent", namespaceHandler.SetParent)
+	api.POST("/namespaces/:tenantId/:name/transfer", namespaceHandler.TransferNamespace)
+	api.POST("/namespaces/:tenantId/:name/clone", namespaceHandler.CloneNamespace)
+	api.GET("/namespaces/:tenantId/:name/bindings", namespaceHandler.GetBindings)
+	api.PUT("/namespaces/:tenantId/:name/isolation", namespaceHandler.SetIsolation)
+	api.DELETE("/namespaces/:tenantId/:name/isolation", namespaceHandler.ResetIsolation)
+	api.GET("/namespaces/:tenantId/:name/drift", driftHandler.GetDrift)
+	api.POST("/namespaces/:tenantId/:name/drift/remediate", driftHandler.RemediateDrift)
+
+	// Start server and stop gracefully on SIGTERM/SIGINT
+	srv := server.New(cfg.Server, router)
+	// Background work stops with the server, which waits for it before
+	// flushing traces.
+	srv.Go("reconciler", func(ctx context.Context) { rec.Run(ctx, time.Duration(cfg.Kubernetes.ResyncInterval)) })
+	srv.Go("drift scanner", func(ctx context.Context) { driftScanner.Run(ctx, time.Duration(cfg.Drift.ScanInterval)) })
+	srv.Go("purger", func(ctx context.Context) { purger.Run(ctx, time.Duration(cfg.Deletion.PurgeInterval)) })
+	srv.Go("reaper", func(ctx context.Context) { reaper.Run(ctx, time.Duration(cfg.Expiry.ReapInterval)) })
+	if gitRepo != nil {
+		// Manifests queued by the other workers are committed after they stop
+		srv.Go("gitops", gitRepo.Run)
+		srv.OnShutdown("gitops", gitRepo.Flush)
+	}
+	srv.OnShutdown("tracing", shutdownTracing)
+	startup.Open()
+	logger.Info("listening", "addr", cfg.Server.Addr)
+	if err := srv.Run(ctx); err != nil {
+		logger.Error("server stopped", "error", err)
 		os.Exit(1)
 	}
 }
+
+func isProbe(path string) bool {
+	switch path {
+	case "/healthz", "/livez", "/readyz", "/metrics":
+		return true
+	}
+	return false
+}
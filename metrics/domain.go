@@ -0,0 +1,145 @@
+// metrics/domain.go
+
+package metrics
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"naas/domain"
+	"naas/repositories"
+)
+
+type TenantLister interface {
+	ListTenants(ctx context.Context, opts ...repositories.ListOption) ([]domain.Tenant, error)
+}
+
+type NamespaceCounter interface {
+	CountNamespaces(ctx context.Context) (map[string]int, error)
+	QuotaUsage(ctx context.Context) (map[string]map[string]resource.Quantity, error)
+}
+
+type ClusterLister interface {
+	ListClusters(ctx context.Context) ([]domain.Cluster, error)
+}
+
+// DomainCollector reports tenant, namespace, quota and cluster gauges, read
+// from the services on every scrape.
+type DomainCollector struct {
+	tenants    TenantLister
+	namespaces NamespaceCounter
+	clusters   ClusterLister
+
+	tenantCount      *prometheus.Desc
+	namespaceCount   *prometheus.Desc
+	quotaUsed        *prometheus.Desc
+	quotaLimit       *prometheus.Desc
+	clusterAllocated *prometheus.Desc
+	clusterCapacity  *prometheus.Desc
+}
+
+func NewDomainCollector(tenants TenantLister, namespaces NamespaceCounter, clusters ClusterLister) *DomainCollector {
+	return &DomainCollector{
+		tenants:    tenants,
+		namespaces: namespaces,
+		clusters:   clusters,
+		tenantCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tenants"),
+			"Number of tenants.",
+			nil, nil,
+		),
+		namespaceCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "namespaces"),
+			"Number of namespaces per tenant.",
+			[]string{"tenant"}, nil,
+		),
+		quotaUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tenant_quota", "used"),
+			"Sum of the quota hard limits of the namespaces per tenant and resource.",
+			[]string{"tenant", "resource"}, nil,
+		),
+		quotaLimit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tenant_quota", "limit"),
+			"Quota of the tenants per resource. Resources without one are unlimited.",
+			[]string{"tenant", "resource"}, nil,
+		),
+		clusterAllocated: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "namespaces"),
+			"Number of namespaces placed on each cluster.",
+			[]string{"cluster"}, nil,
+		),
+		clusterCapacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "capacity"),
+			"Most namespaces each cluster takes. Unlimited clusters have none.",
+			[]string{"cluster"}, nil,
+		),
+	}
+}
+
+func (c *DomainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tenantCount
+	ch <- c.namespaceCount
+	ch <- c.quotaUsed
+	ch <- c.quotaLimit
+	ch <- c.clusterAllocated
+	ch <- c.clusterCapacity
+}
+
+func (c *DomainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	tenants, err := c.tenants.ListTenants(ctx)
+	if err != nil {
+		slog.Error("collecting tenant metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.tenantCount, err)
+		ch <- prometheus.NewInvalidMetric(c.quotaLimit, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.tenantCount, prometheus.GaugeValue, float64(len(tenants)))
+		for _, tenant := range tenants {
+			for name, limit := range tenant.Quota {
+				quantity, err := resource.ParseQuantity(limit)
+				if err != nil {
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(c.quotaLimit, prometheus.GaugeValue, quantity.AsApproximateFloat64(), tenant.ID, name)
+			}
+		}
+	}
+
+	counts, err := c.namespaces.CountNamespaces(ctx)
+	if err != nil {
+		slog.Error("collecting namespace metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.namespaceCount, err)
+	} else {
+		for tenantID, count := range counts {
+			ch <- prometheus.MustNewConstMetric(c.namespaceCount, prometheus.GaugeValue, float64(count), tenantID)
+		}
+	}
+
+	usage, err := c.namespaces.QuotaUsage(ctx)
+	if err != nil {
+		slog.Error("collecting quota metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.quotaUsed, err)
+	} else {
+		for tenantID, used := range usage {
+			for name, quantity := range used {
+				ch <- prometheus.MustNewConstMetric(c.quotaUsed, prometheus.GaugeValue, quantity.AsApproximateFloat64(), tenantID, name)
+			}
+		}
+	}
+
+	clusters, err := c.clusters.ListClusters(ctx)
+	if err != nil {
+		slog.Error("collecting cluster metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.clusterAllocated, err)
+		return
+	}
+	for _, cluster := range clusters {
+		ch <- prometheus.MustNewConstMetric(c.clusterAllocated, prometheus.GaugeValue, float64(cluster.Allocated), cluster.Name)
+		if cluster.Capacity > 0 {
+			ch <- prometheus.MustNewConstMetric(c.clusterCapacity, prometheus.GaugeValue, float64(cluster.Capacity), cluster.Name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// metrics/drift.go
+
+package metrics
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"naas/domain"
+)
+
+type DriftLister interface {
+	ListDrift(ctx context.Context, tenantID string) ([]domain.Drift, error)
+}
+
+// DriftCollector reports the namespaces found drifted from their records,
+// read from the drift scanner on every scrape. Remediated namespaces don't
+// count.
+type DriftCollector struct {
+	drift DriftLister
+
+	drifted *prometheus.Desc
+}
+
+func NewDriftCollector(drift DriftLister) *DriftCollector {
+	return &DriftCollector{
+		drift: drift,
+		drifted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "namespaces_drifted"),
+			"Number of namespaces that drifted from their records, per tenant.",
+			[]string{"tenant"}, nil,
+		),
+	}
+}
+
+func (c *DriftCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.drifted
+}
+
+func (c *DriftCollector) Collect(ch chan<- prometheus.Metric) {
+	drift, err := c.drift.ListDrift(context.Background(), "")
+	if err != nil {
+		slog.Error("collecting drift metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.drifted, err)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, d := range drift {
+		if !d.Remediated {
+			counts[d.TenantID]++
+		}
+	}
+	for tenantID, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.drifted, prometheus.GaugeValue, float64(count), tenantID)
+	}
+}
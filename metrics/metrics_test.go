@@ -0,0 +1,117 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+	"naas/metrics"
+	"naas/repositories"
+	"naas/service"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := metrics.New()
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/tenants/:id", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/tenants/a", "/tenants/b", "/unknown"} {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `naas_http_requests_total{code="404",method="GET",route="/tenants/:id"} 2`)
+	assert.Contains(t, body, `naas_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	assert.Contains(t, body, `naas_http_request_duration_seconds_count{code="404",method="GET",route="/tenants/:id"} 2`)
+}
+
+func TestDomainCollector(t *testing.T) {
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterRepo := repositories.NewClusterRepository()
+	for _, tenant := range []domain.Tenant{{ID: "a", Quota: map[string]string{"requests.cpu": "4"}}, {ID: "b"}} {
+		assert.NoError(t, tenantRepo.CreateTenant(context.Background(), &tenant))
+	}
+	for _, cluster := range []domain.Cluster{{Name: "east", Kubeconfig: "/east", Capacity: 10}, {Name: "west", Kubeconfig: "/west"}} {
+		assert.NoError(t, clusterRepo.CreateCluster(context.Background(), &cluster))
+	}
+	for _, name := range []string{"ns-1", "ns-2"} {
+		assert.NoError(t, namespaceRepo.CreateNamespace(context.Background(), "a", &domain.Namespace{Name: name, Cluster: "east", NamespaceSpec: domain.NamespaceSpec{
+			Quota: map[string]string{"requests.cpu": "1500m"},
+		}}))
+	}
+
+	collector := metrics.NewDomainCollector(
+		service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour),
+		service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour),
+		service.NewClusterService(clusterRepo, namespaceRepo, nil),
+	)
+
+	expected := `
+# HELP naas_cluster_capacity Most namespaces each cluster takes. Unlimited clusters have none.
+# TYPE naas_cluster_capacity gauge
+naas_cluster_capacity{cluster="east"} 10
+# HELP naas_cluster_namespaces Number of namespaces placed on each cluster.
+# TYPE naas_cluster_namespaces gauge
+naas_cluster_namespaces{cluster="east"} 2
+naas_cluster_namespaces{cluster="west"} 0
+# HELP naas_namespaces Number of namespaces per tenant.
+# TYPE naas_namespaces gauge
+naas_namespaces{tenant="a"} 2
+# HELP naas_tenant_quota_limit Quota of the tenants per resource. Resources without one are unlimited.
+# TYPE naas_tenant_quota_limit gauge
+naas_tenant_quota_limit{resource="requests.cpu",tenant="a"} 4
+# HELP naas_tenant_quota_used Sum of the quota hard limits of the namespaces per tenant and resource.
+# TYPE naas_tenant_quota_used gauge
+naas_tenant_quota_used{resource="requests.cpu",tenant="a"} 3
+# HELP naas_tenants Number of tenants.
+# TYPE naas_tenants gauge
+naas_tenants 2
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected))
+	assert.NoError(t, err)
+}
+
+type driftLister []domain.Drift
+
+func (l driftLister) ListDrift(context.Context, string) ([]domain.Drift, error) {
+	return l, nil
+}
+
+func TestDriftCollector(t *testing.T) {
+	collector := metrics.NewDriftCollector(driftLister{
+		{TenantID: "a", Namespace: "ns-1"},
+		{TenantID: "a", Namespace: "ns-2"},
+		{TenantID: "b", Namespace: "ns-3", Remediated: true},
+	})
+
+	expected := `
+# HELP naas_namespaces_drifted Number of namespaces that drifted from their records, per tenant.
+# TYPE naas_namespaces_drifted gauge
+naas_namespaces_drifted{tenant="a"} 2
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected))
+	assert.NoError(t, err)
+}
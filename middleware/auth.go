@@ -0,0 +1,31 @@
+// middleware/auth.go
+
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BearerAuth rejects requests that don't carry one of the given tokens in
+// their Authorization header.
+func BearerAuth(tokens []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if strings.HasPrefix(header, "Bearer ") {
+			token := strings.TrimPrefix(header, "Bearer ")
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Bearer realm="naas"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
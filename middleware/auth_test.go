@@ -0,0 +1,42 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/middleware"
+)
+
+func TestBearerAuth(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.BearerAuth([]string{"token-1", "token-2"}))
+	router.GET("/tenants", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic token-1", http.StatusUnauthorized},
+		{"Bearer token-1", http.StatusOK},
+		{"Bearer token-2", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "/tenants", nil)
+		assert.NoError(t, err)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.code, w.Code, tt.header)
+	}
+}
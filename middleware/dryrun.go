@@ -0,0 +1,40 @@
+// middleware/dryrun.go
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"naas/logging"
+	"naas/repositories"
+)
+
+// DryRunAll is the only value of the dryRun query parameter: every stage of
+// the request is run except storing the changes.
+const DryRunAll = "All"
+
+// DryRun handles mutating requests with ?dryRun=All as dry runs. They go
+// through the same validation and conflict checks as other requests and
+// respond with the objects as they would be stored, but the changes are
+// neither stored nor applied to the clusters. Each change is checked against
+// what is stored, so the operations of a bulk request don't see the ones
+// before them.
+func DryRun() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.GetQuery("dryRun")
+		if !ok || !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if v != DryRunAll {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "dryRun: must be " + DryRunAll})
+			return
+		}
+
+		ctx := repositories.DryRun(c.Request.Context())
+		ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("dry_run", true))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/middleware"
+	"naas/repositories"
+)
+
+func TestDryRun(t *testing.T) {
+	var dryRun bool
+	router := gin.New()
+	router.Use(middleware.DryRun())
+	handler := func(c *gin.Context) {
+		dryRun = repositories.IsDryRun(c.Request.Context())
+		c.Status(http.StatusOK)
+	}
+	router.GET("/tenants", handler)
+	router.POST("/tenants", handler)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/tenants?dryRun=All").Code)
+	assert.True(t, dryRun)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/tenants").Code)
+	assert.False(t, dryRun)
+
+	// Reads are never changed, so there is nothing to dry-run
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/tenants?dryRun=All").Code)
+	assert.False(t, dryRun)
+
+	dryRun = false
+	w := serve(http.MethodPost, "/tenants?dryRun=true")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"dryRun: must be All"}`, w.Body.String())
+	assert.False(t, dryRun)
+}
@@ -0,0 +1,236 @@
+// middleware/idempotency.go
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a retry.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize caps the request bodies read to fingerprint
+	// requests.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// Idempotency makes mutating requests that carry an Idempotency-Key header
+// safe to retry. The first response to a key is stored for window and
+// replayed to retries with the same key, method, path and body; a retry
+// that differs from the first request is rejected with 422, and one made
+// while the first is still being handled with 409. Keys are scoped to the
+// caller's Authorization header. Server errors and requests that timed out
+// aren't stored, so they can be retried for real. At most maxKeys keys are
+// kept: the oldest responses are forgotten early to make room, and requests
+// are rejected with 503 while maxKeys requests with keys are in progress.
+// Bodies of requests with keys are limited to 1 MiB.
+func Idempotency(window time.Duration, maxKeys int) gin.HandlerFunc {
+	store := &idempotencyStore{window: window, max: maxKeys, entries: make(map[string]*idempotencyEntry)}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is longer than 255 characters"})
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit)})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "reading request body: " + err.Error()})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		key = hash(c.GetHeader("Authorization"), key)
+		fingerprint := hash(c.Request.Method, c.Request.URL.RequestURI(), string(body))
+
+		entry, ok, err := store.begin(key, fingerprint, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			switch {
+			case entry.fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was used for a different request"})
+			case entry.pending:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is in progress"})
+			default:
+				entry.replay(c)
+			}
+			return
+		}
+
+		// The key is released unless the response is stored, including when
+		// a handler panics.
+		stored := false
+		defer func() {
+			if !stored {
+				store.forget(key)
+			}
+		}()
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		status := w.Status()
+		if status >= http.StatusInternalServerError || c.Request.Context().Err() != nil {
+			return
+		}
+		store.finish(key, status, w.Header().Clone(), w.body.Bytes())
+		stored = true
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func hash(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		// The length prefix keeps ("ab", "c") apart from ("a", "bc").
+		fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var errTooManyPending = errors.New("too many requests with an Idempotency-Key are in progress")
+
+type idempotencyStore struct {
+	window time.Duration
+	max    int
+
+	mtx     sync.Mutex
+	entries map[string]*idempotencyEntry
+	// stored lists the keys of the stored responses in the order they
+	// expire, which is the order they were stored in.
+	stored []storedKey
+}
+
+type storedKey struct {
+	key     string
+	expires time.Time
+}
+
+// idempotencyEntry is the response to the first request made with a key,
+// or a placeholder while that request is pending.
+type idempotencyEntry struct {
+	fingerprint string
+	pending     bool
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// begin claims key for a new request and returns true, or returns the
+// entry of an earlier request that holds it. Expired entries are dropped
+// along the way, and the oldest stored ones too while the store is full.
+// It fails with errTooManyPending if the store is full of pending requests.
+func (s *idempotencyStore) begin(key, fingerprint string, now time.Time) (idempotencyEntry, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for len(s.stored) > 0 && now.After(s.stored[0].expires) {
+		s.dropOldest()
+	}
+
+	if e, ok := s.entries[key]; ok {
+		return *e, false, nil
+	}
+	for len(s.entries) >= s.max && len(s.stored) > 0 {
+		s.dropOldest()
+	}
+	if len(s.entries) >= s.max {
+		return idempotencyEntry{}, false, errTooManyPending
+	}
+	s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, pending: true}
+	return idempotencyEntry{}, true, nil
+}
+
+// dropOldest forgets the stored response that expires first.
+func (s *idempotencyStore) dropOldest() {
+	delete(s.entries, s.stored[0].key)
+	s.stored[0] = storedKey{}
+	s.stored = s.stored[1:]
+}
+
+// finish stores the response to the request holding key for the window.
+// Its expiry is taken with the lock held, so that stored stays in order.
+func (s *idempotencyStore) finish(key string, status int, header http.Header, body []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e := s.entries[key]
+	e.pending = false
+	e.status = status
+	e.header = header
+	e.body = body
+	s.stored = append(s.stored, storedKey{key: key, expires: time.Now().Add(s.window)})
+}
+
+func (s *idempotencyStore) forget(key string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.entries, key)
+}
+
+func (e *idempotencyEntry) replay(c *gin.Context) {
+	for name, values := range e.header {
+		// The retry keeps its own request ID.
+		if name != http.CanonicalHeaderKey(RequestIDHeader) {
+			c.Writer.Header()[name] = values
+		}
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Status(e.status)
+	_, _ = c.Writer.Write(e.body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
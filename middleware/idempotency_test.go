@@ -0,0 +1,149 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/middleware"
+)
+
+func TestIdempotency(t *testing.T) {
+	created := 0
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.Idempotency(time.Hour, 100))
+	router.POST("/tenants", func(c *gin.Context) {
+		created++
+		c.JSON(http.StatusCreated, gin.H{"n": created})
+	})
+	router.POST("/broken", func(c *gin.Context) {
+		created++
+		c.Status(http.StatusInternalServerError)
+	})
+	router.POST("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	serve := func(path, key, token, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/tenants", "key-1", "", `{"id":"acme"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"n":1}`, w.Body.String())
+
+	// Retries get the first response
+	w = serve("/tenants", "key-1", "", `{"id":"acme"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"n":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 1, created)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("/tenants", "key-1", "", `{"id":"globex"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("/broken", "key-1", "", `{"id":"acme"}`).Code)
+
+	// Keys are scoped to the caller, and requests without one run every time
+	assert.Equal(t, `{"n":2}`, serve("/tenants", "key-1", "other", `{"id":"acme"}`).Body.String())
+	assert.Equal(t, `{"n":3}`, serve("/tenants", "", "", `{"id":"acme"}`).Body.String())
+	assert.Equal(t, `{"n":4}`, serve("/tenants", "", "", `{"id":"acme"}`).Body.String())
+
+	// Server errors aren't stored
+	serve("/broken", "key-2", "", "")
+	serve("/broken", "key-2", "", "")
+	assert.Equal(t, 6, created)
+	assert.Equal(t, http.StatusInternalServerError, serve("/panic", "key-3", "", "").Code)
+	assert.Equal(t, http.StatusInternalServerError, serve("/panic", "key-3", "", "").Code)
+
+	long := make([]byte, 256)
+	for i := range long {
+		long[i] = 'k'
+	}
+	assert.Equal(t, http.StatusBadRequest, serve("/tenants", string(long), "", "").Code)
+}
+
+func TestIdempotency_Window(t *testing.T) {
+	created := 0
+	router := gin.New()
+	router.Use(middleware.Idempotency(time.Millisecond, 100))
+	router.POST("/tenants", func(c *gin.Context) {
+		created++
+		c.String(http.StatusCreated, strconv.Itoa(created))
+	})
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodPost, "/tenants", nil)
+		assert.NoError(t, err)
+		req.Header.Set(middleware.IdempotencyKeyHeader, "key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, strconv.Itoa(i+1), w.Body.String())
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIdempotency_Limits(t *testing.T) {
+	created := 0
+	started, release := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.Use(middleware.Idempotency(time.Hour, 2))
+	router.POST("/tenants", func(c *gin.Context) {
+		created++
+		c.String(http.StatusCreated, strconv.Itoa(created))
+	})
+	router.POST("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	serve := func(path, key, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("/tenants", "key-1", strings.Repeat("x", 1<<20+1)).Code)
+	assert.Equal(t, 0, created)
+
+	// The oldest response is forgotten to make room for a new key
+	assert.Equal(t, "1", serve("/tenants", "key-1", "").Body.String())
+	assert.Equal(t, "2", serve("/tenants", "key-2", "").Body.String())
+	assert.Equal(t, "3", serve("/tenants", "key-3", "").Body.String())
+	assert.Equal(t, "3", serve("/tenants", "key-3", "").Body.String())
+	assert.Equal(t, "4", serve("/tenants", "key-1", "").Body.String())
+
+	// Pending requests are never forgotten
+	done := make(chan struct{})
+	for _, key := range []string{"slow-1", "slow-2"} {
+		go func(key string) {
+			serve("/slow", key, "")
+			done <- struct{}{}
+		}(key)
+	}
+	<-started
+	<-started
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/tenants", "key-5", "").Code)
+	close(release)
+	<-done
+	<-done
+	assert.Equal(t, "5", serve("/tenants", "key-5", "").Body.String())
+}
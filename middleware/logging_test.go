@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"naas/logging"
+	"naas/middleware"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, "debug")
+
+	router := gin.New()
+	router.Use(middleware.RequestLogger(logger, "/healthz"))
+	router.GET("/namespaces/:tenantId/:name", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handling")
+		_ = c.Error(errors.New("namespace not found"))
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/healthz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/namespaces/acme/dev", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Request-ID", "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-123", w.Header().Get("X-Request-ID"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var handlerLog, accessLog map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerLog))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &accessLog))
+
+	assert.Equal(t, "handling", handlerLog["msg"])
+	assert.Equal(t, "req-123", handlerLog["request_id"])
+	assert.Equal(t, "acme", handlerLog["tenant_id"])
+
+	assert.Equal(t, "WARN", accessLog["level"])
+	assert.Equal(t, "req-123", accessLog["request_id"])
+	assert.Equal(t, "acme", accessLog["tenant_id"])
+	assert.Equal(t, "/namespaces/:tenantId/:name", accessLog["route"])
+	assert.Equal(t, float64(http.StatusNotFound), accessLog["status"])
+	assert.Equal(t, "Error #01: namespace not found\n", accessLog["error"])
+	assert.Contains(t, accessLog, "latency")
+
+	out.Reset()
+	req, err = http.NewRequest(http.MethodGet, "/healthz", nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,132 @@
+// reconciler/drift.go
+
+package reconciler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"naas/domain"
+	"naas/kube"
+	"naas/tracing"
+)
+
+// DetectDrift compares a namespace with the cluster it is placed on. It
+// returns nil when naas has no connection to that cluster.
+func (r *Reconciler) DetectDrift(ctx context.Context, tenantID, name string) (_ *domain.Drift, err error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Reconciler.DetectDrift", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	ns, objects, err := r.desired(ctx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.clientFor(ctx, ns.Cluster)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	live, err := client.Observe(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Drift{
+		TenantID:    tenantID,
+		Namespace:   name,
+		Cluster:     ns.Cluster,
+		Differences: Diff(objects, live),
+		DetectedAt:  time.Now(),
+	}, nil
+}
+
+// Diff compares the desired objects of a namespace with the live ones. The
+// namespace drifts when one of its desired labels or annotations is missing
+// or has another value; others may be added by anyone. Managed objects
+// drift when they are missing, unexpected or differ in their spec.
+func Diff(desired *Objects, live *kube.Live) []domain.Difference {
+	name := desired.Namespace.Name
+	if live.Namespace == nil {
+		return []domain.Difference{{Kind: domain.DriftNamespace, Name: name, Change: domain.DriftMissing}}
+	}
+
+	diffs := []domain.Difference{}
+	for _, k := range sortedKeys(desired.Namespace.Labels) {
+		if v, ok := live.Namespace.Labels[k]; !ok || v != desired.Namespace.Labels[k] {
+			diffs = append(diffs, domain.Difference{Kind: domain.DriftNamespace, Name: name, Change: domain.DriftModified, Detail: "label " + k})
+		}
+	}
+	for _, k := range sortedKeys(desired.Namespace.Annotations) {
+		if v, ok := live.Namespace.Annotations[k]; !ok || v != desired.Namespace.Annotations[k] {
+			diffs = append(diffs, domain.Difference{Kind: domain.DriftNamespace, Name: name, Change: domain.DriftModified, Detail: "annotation " + k})
+		}
+	}
+
+	diffs = append(diffs, diffObjects(domain.DriftNetworkPolicy, desired.NetworkPolicies, live.NetworkPolicies,
+		func(d, l *networkingv1.NetworkPolicy) bool { return equality.Semantic.DeepEqual(d.Spec, l.Spec) })...)
+	diffs = append(diffs, diffObjects(domain.DriftResourceQuota, desired.ResourceQuotas, live.ResourceQuotas,
+		func(d, l *corev1.ResourceQuota) bool { return equality.Semantic.DeepEqual(d.Spec.Hard, l.Spec.Hard) })...)
+	diffs = append(diffs, diffObjects(domain.DriftRole, desired.Roles, live.Roles,
+		func(d, l *rbacv1.Role) bool { return equality.Semantic.DeepEqual(d.Rules, l.Rules) })...)
+	diffs = append(diffs, diffObjects(domain.DriftRoleBinding, desired.RoleBindings, live.RoleBindings,
+		func(d, l *rbacv1.RoleBinding) bool {
+			return d.RoleRef == l.RoleRef && equality.Semantic.DeepEqual(d.Subjects, l.Subjects)
+		})...)
+	return diffs
+}
+
+// diffObjects compares the desired and live objects of one kind by name.
+func diffObjects[T any, P interface {
+	*T
+	metav1.Object
+}](kind string, desired []P, live []T, same func(desired, live P) bool) []domain.Difference {
+	found := make(map[string]P, len(live))
+	for i := range live {
+		obj := P(&live[i])
+		found[obj.GetName()] = obj
+	}
+
+	var diffs []domain.Difference
+	for _, obj := range desired {
+		l, ok := found[obj.GetName()]
+		delete(found, obj.GetName())
+		switch {
+		case !ok:
+			diffs = append(diffs, domain.Difference{Kind: kind, Name: obj.GetName(), Change: domain.DriftMissing})
+		case !same(obj, l):
+			diffs = append(diffs, domain.Difference{Kind: kind, Name: obj.GetName(), Change: domain.DriftModified, Detail: "spec"})
+		}
+	}
+	unexpected := make([]string, 0, len(found))
+	for name := range found {
+		unexpected = append(unexpected, name)
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		diffs = append(diffs, domain.Difference{Kind: kind, Name: name, Change: domain.DriftUnexpected})
+	}
+	return diffs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
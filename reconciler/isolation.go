@@ -0,0 +1,118 @@
+// reconciler/isolation.go
+
+package reconciler
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"naas/config"
+	"naas/domain"
+	"naas/kube"
+)
+
+// Names of the default network policies.
+const (
+	DefaultDenyName  = "naas-default-deny"
+	AllowTenantName  = "naas-allow-tenant"
+	AllowIngressName = "naas-allow-ingress-controller"
+	AllowDNSName     = "naas-allow-dns"
+)
+
+const (
+	// labelNamespaceName is set by Kubernetes on every namespace.
+	labelNamespaceName = "kubernetes.io/metadata.name"
+	dnsPort            = 53
+)
+
+// isolationPolicies returns the default network policies of namespace ns:
+// ingress is denied except from the namespaces of the tenant, the tenants
+// the namespace allows and the ingress controller. Namespaces that isolate
+// their egress may only reach their tenant and the cluster DNS.
+func isolationPolicies(tenantID string, ns *domain.Namespace, network config.Network) []*networkingv1.NetworkPolicy {
+	isolation := domain.NetworkIsolation{}
+	if ns.Isolation != nil {
+		isolation = *ns.Isolation
+	}
+	if !network.Isolation || isolation.Disabled {
+		return nil
+	}
+
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if isolation.DenyEgress {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+	}
+	policies := []*networkingv1.NetworkPolicy{{
+		ObjectMeta: objectMeta(DefaultDenyName, ns.Name, tenantID),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: policyTypes,
+		},
+	}}
+
+	tenant := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{
+		MatchLabels: map[string]string{kube.LabelTenant: tenantID},
+	}}
+	from := []networkingv1.NetworkPolicyPeer{tenant}
+	for _, allowed := range isolation.AllowTenants {
+		from = append(from, networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{kube.LabelTenant: allowed},
+		}})
+	}
+	allowTenant := &networkingv1.NetworkPolicy{
+		ObjectMeta: objectMeta(AllowTenantName, ns.Name, tenantID),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: from}},
+		},
+	}
+	if isolation.DenyEgress {
+		allowTenant.Spec.PolicyTypes = policyTypes
+		allowTenant.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{To: []networkingv1.NetworkPolicyPeer{tenant}}}
+	}
+	policies = append(policies, allowTenant)
+
+	if network.IngressNamespace != "" && !isolation.DenyIngressController {
+		policies = append(policies, &networkingv1.NetworkPolicy{
+			ObjectMeta: objectMeta(AllowIngressName, ns.Name, tenantID),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{namespaceNamed(network.IngressNamespace)},
+				}},
+			},
+		})
+	}
+
+	// With egress open, DNS needs no policy of its own.
+	if isolation.DenyEgress {
+		port := intstr.FromInt32(dnsPort)
+		udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+		policies = append(policies, &networkingv1.NetworkPolicy{
+			ObjectMeta: objectMeta(AllowDNSName, ns.Name, tenantID),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{namespaceNamed(network.DNSNamespace)},
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &udp, Port: &port},
+						{Protocol: &tcp, Port: &port},
+					},
+				}},
+			},
+		})
+	}
+
+	return policies
+}
+
+// namespaceNamed selects a namespace by name.
+func namespaceNamed(name string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{
+		MatchLabels: map[string]string{labelNamespaceName: name},
+	}}
+}
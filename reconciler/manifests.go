@@ -0,0 +1,225 @@
+// reconciler/manifests.go
+
+package reconciler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"naas/domain"
+	"naas/repositories"
+	"naas/tracing"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest is one rendered object, at its path in a GitOps repository.
+// Rendered for a tenant, the path starts with the directory of the cluster
+// the object's namespace is placed on: clusters/<name> for registered
+// clusters and local for the cluster naas runs with.
+type Manifest struct {
+	Path string
+	Data []byte
+}
+
+// ManifestSink receives the manifests of a tenant whenever they may have
+// changed, e.g. to commit them to a repository that Argo CD or Flux
+// applies. The manifests replace all previous ones of the tenant. A sink
+// may store them later; an error means they were rejected.
+type ManifestSink interface {
+	WriteTenant(ctx context.Context, tenantID string, manifests []Manifest, message string) error
+}
+
+// Manifests encodes the objects as YAML, one file per object under a
+// directory named after the namespace. The output only depends on the
+// objects: keys are sorted and server-populated fields left out.
+func (o *Objects) Manifests() ([]Manifest, error) {
+	dir := o.Namespace.Name
+	var manifests []Manifest
+	add := func(kind, apiVersion, name string, obj interface{}) error {
+		data, err := encode(kind, apiVersion, obj)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		manifests = append(manifests, Manifest{
+			Path: path.Join(dir, strings.ToLower(kind)+"-"+name+".yaml"),
+			Data: data,
+		})
+		return nil
+	}
+
+	if err := add("Namespace", "v1", o.Namespace.Name, o.Namespace); err != nil {
+		return nil, err
+	}
+	for _, q := range o.ResourceQuotas {
+		if err := add("ResourceQuota", "v1", q.Name, q); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range o.NetworkPolicies {
+		if err := add("NetworkPolicy", networkingv1.SchemeGroupVersion.String(), p.Name, p); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range o.Roles {
+		if err := add("Role", rbacv1.SchemeGroupVersion.String(), r.Name, r); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range o.RoleBindings {
+		if err := add("RoleBinding", rbacv1.SchemeGroupVersion.String(), b.Name, b); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Path < manifests[j].Path })
+	return manifests, nil
+}
+
+// RenderTenant renders the manifests of every namespace of a tenant that
+// hasn't been purged. Deleted namespaces render frozen, as they are in the
+// cluster.
+func (r *Reconciler) RenderTenant(ctx context.Context, tenantID string) (_ []Manifest, err error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Reconciler.RenderTenant", trace.WithAttributes(attribute.String("naas.tenant.id", tenantID)))
+	defer tracing.End(span, &err)
+
+	return r.renderTenant(ctx, tenantID, "")
+}
+
+// renderTenant renders the namespaces of a tenant but exclude, which is
+// being purged, each under the directory of its cluster.
+func (r *Reconciler) renderTenant(ctx context.Context, tenantID, exclude string) ([]Manifest, error) {
+	namespaces, err := r.namespaces.GetAllNamespaces(ctx, tenantID, repositories.IncludeDeleted(true))
+	if errors.Is(err, repositories.ErrNoTenantNamespaces) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := r.tenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := domain.NewHierarchy(namespaces)
+	var manifests []Manifest
+	for _, ns := range namespaces {
+		if ns.Name == exclude {
+			continue
+		}
+		if ns.Parent != "" {
+			ns.NamespaceSpec = hierarchy.EffectiveSpec(ns.Name)
+		}
+		objects, err := Render(tenantID, tenant, &ns, r.network)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+		rendered, err := objects.Manifests()
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+		for _, m := range rendered {
+			m.Path = path.Join(clusterDir(ns.Cluster), m.Path)
+			manifests = append(manifests, m)
+		}
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Path < manifests[j].Path })
+	return manifests, nil
+}
+
+// clusterDir returns the directory of the manifests of a cluster's
+// namespaces. Registered clusters are kept apart from the local one, so no
+// cluster name can collide with it.
+func clusterDir(cluster string) string {
+	if cluster == "" {
+		return "local"
+	}
+	return path.Join("clusters", cluster)
+}
+
+// export hands the manifests of a tenant to the sink, if any.
+func (r *Reconciler) export(ctx context.Context, tenantID, exclude, message string) error {
+	if r.sink == nil {
+		return nil
+	}
+	manifests, err := r.renderTenant(ctx, tenantID, exclude)
+	if err != nil {
+		return err
+	}
+	if err := r.sink.WriteTenant(ctx, tenantID, manifests, message); err != nil {
+		return fmt.Errorf("exporting manifests: %w", err)
+	}
+	return nil
+}
+
+// WriteTarball writes the manifests as a gzipped tarball, under dir. The
+// archive is reproducible: entries are sorted and carry no timestamps or
+// owners. Paths that would escape dir are rejected.
+func WriteTarball(w io.Writer, dir string, manifests []Manifest) error {
+	sorted := append([]Manifest(nil), manifests...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, m := range sorted {
+		name := path.Join(dir, m.Path)
+		if !filepath.IsLocal(filepath.FromSlash(m.Path)) || !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("manifest path %q is outside the archive", name)
+		}
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(m.Data)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(m.Data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// encode renders obj as YAML with its type set, leaving out the fields the
+// API server populates.
+func encode(kind, apiVersion string, obj interface{}) ([]byte, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields["kind"] = kind
+	fields["apiVersion"] = apiVersion
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	return yaml.Marshal(fields)
+}
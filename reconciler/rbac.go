@@ -0,0 +1,82 @@
+// reconciler/rbac.go
+
+package reconciler
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	"naas/domain"
+)
+
+var (
+	readVerbs  = []string{"get", "list", "watch"}
+	writeVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete", "deletecollection"}
+)
+
+// workloads are the resources developers manage in a namespace.
+var workloads = []rbacv1.PolicyRule{
+	{APIGroups: []string{""}, Resources: []string{"pods", "services", "endpoints", "configmaps", "persistentvolumeclaims", "serviceaccounts"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments", "replicasets", "statefulsets", "daemonsets"}},
+	{APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}},
+	{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}},
+	{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"}},
+	{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}},
+}
+
+// observed are the resources every member can read but only naas writes,
+// along with read-only subresources.
+var observed = []rbacv1.PolicyRule{
+	{APIGroups: []string{""}, Resources: []string{"pods/log", "events", "resourcequotas"}, Verbs: readVerbs},
+	{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"networkpolicies"}, Verbs: readVerbs},
+}
+
+// memberRules are the permissions of each member role. Each role includes
+// the permissions of the roles below it.
+var memberRules = map[domain.MemberRole][]rbacv1.PolicyRule{
+	domain.MemberViewer: concat(withVerbs(workloads, readVerbs), observed),
+	domain.MemberDeveloper: concat(withVerbs(workloads, writeVerbs), observed, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: writeVerbs},
+		{APIGroups: []string{""}, Resources: []string{"pods/exec", "pods/portforward", "pods/attach"}, Verbs: []string{"get", "create"}},
+	}),
+	domain.MemberAdmin: concat(withVerbs(workloads, writeVerbs), observed, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: writeVerbs},
+		{APIGroups: []string{""}, Resources: []string{"pods/exec", "pods/portforward", "pods/attach"}, Verbs: []string{"get", "create"}},
+		{APIGroups: []string{rbacv1.GroupName}, Resources: []string{"roles", "rolebindings"}, Verbs: writeVerbs},
+	}),
+}
+
+// memberRoles returns the Roles of the member roles in use in a tenant.
+func memberRoles(tenantID, namespace string, members []domain.Member) []*rbacv1.Role {
+	used := make(map[domain.MemberRole]bool)
+	for _, m := range members {
+		used[m.Role] = true
+	}
+
+	var roles []*rbacv1.Role
+	for _, role := range domain.MemberRoles {
+		if !used[role] {
+			continue
+		}
+		roles = append(roles, &rbacv1.Role{
+			ObjectMeta: objectMeta(role.RoleName(), namespace, tenantID),
+			Rules:      memberRules[role],
+		})
+	}
+	return roles
+}
+
+func concat(parts ...[]rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	var result []rbacv1.PolicyRule
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
+
+func withVerbs(rules []rbacv1.PolicyRule, verbs []string) []rbacv1.PolicyRule {
+	result := make([]rbacv1.PolicyRule, len(rules))
+	for i, rule := range rules {
+		rule.Verbs = verbs
+		result[i] = rule
+	}
+	return result
+}
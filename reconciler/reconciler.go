@@ -0,0 +1,417 @@
+// reconciler/reconciler.go
+
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"naas/config"
+	"naas/domain"
+	"naas/kube"
+	"naas/logging"
+	"naas/repositories"
+	"naas/tracing"
+)
+
+var tracer = otel.Tracer("naas/reconciler")
+
+// Reconciler drives the clusters towards the state stored in the
+// repositories. Namespaces are applied to the registered cluster they are
+// placed on, or to the cluster naas runs with, unless the cluster is managed
+// through GitOps. A nil *Reconciler is valid
+// and does nothing, as does any Reconciler for a dry run.
+type Reconciler struct {
+	tenants    *repositories.TenantRepository
+	namespaces *repositories.NamespaceRepository
+	clusters   *repositories.ClusterRepository
+	// client reaches the cluster naas runs with. When nil, namespaces not
+	// placed on a registered cluster are only recorded.
+	client  *kube.Client
+	connect Connector
+	// sink, when set, also gets the manifests of every tenant. It is the
+	// only way to reach clusters managed through GitOps.
+	sink    ManifestSink
+	network config.Network
+
+	mtx     sync.Mutex
+	clients map[string]*kube.Client
+
+	errors *prometheus.CounterVec
+}
+
+// Connector connects to a registered cluster.
+type Connector func(cluster domain.Cluster) (*kube.Client, error)
+
+// ConnectKubeconfig connects to a cluster with its kubeconfig file.
+func ConnectKubeconfig(cluster domain.Cluster) (*kube.Client, error) {
+	return kube.NewFromConfig(config.Kubernetes{
+		Mode:       config.KubernetesKubeconfig,
+		Kubeconfig: cluster.Kubeconfig,
+		Context:    cluster.Context,
+	})
+}
+
+func New(tenants *repositories.TenantRepository, namespaces *repositories.NamespaceRepository, clusters *repositories.ClusterRepository, client *kube.Client, connect Connector, sink ManifestSink, network config.Network) *Reconciler {
+	return &Reconciler{
+		tenants:    tenants,
+		namespaces: namespaces,
+		clusters:   clusters,
+		client:     client,
+		connect:    connect,
+		sink:       sink,
+		network:    network,
+		clients:    make(map[string]*kube.Client),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "naas",
+			Name:      "reconcile_errors_total",
+			Help:      "Failed namespace reconciliations, by tenant.",
+		}, []string{"tenant"}),
+	}
+}
+
+func (r *Reconciler) Collector() prometheus.Collector {
+	return r.errors
+}
+
+// ReconcileNamespace applies the desired state of one namespace, and
+// exports the manifests of its tenant. Namespaces with a parent get the spec
+// they inherit merged into their own. The outcome is recorded in the
+// namespace's Synced condition.
+func (r *Reconciler) ReconcileNamespace(ctx context.Context, tenantID, name string) error {
+	if r == nil || repositories.IsDryRun(ctx) {
+		return nil
+	}
+
+	return r.reconcileNamespace(ctx, tenantID, name, true)
+}
+
+func (r *Reconciler) reconcileNamespace(ctx context.Context, tenantID, name string, export bool) (err error) {
+	ctx, span := tracer.Start(ctx, "Reconciler.ReconcileNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+	defer func() {
+		if err != nil {
+			r.errors.WithLabelValues(tenantID).Inc()
+			logging.FromContext(ctx).WarnContext(ctx, "reconcile failed, will retry on resync",
+				"tenant_id", tenantID, "namespace", name, "error", err)
+		}
+		r.recordSynced(ctx, tenantID, name, err)
+	}()
+
+	ns, objects, err := r.desired(ctx, tenantID, name)
+	if err != nil {
+		return err
+	}
+	if export {
+		if err := r.export(ctx, tenantID, "", fmt.Sprintf("Update namespace %s of tenant %s", name, tenantID)); err != nil {
+			return err
+		}
+	}
+	client, err := r.clientFor(ctx, ns.Cluster)
+	if err != nil || client == nil {
+		return err
+	}
+	return r.apply(ctx, client, objects)
+}
+
+// DeleteNamespace removes a namespace from the cluster it is placed on. It
+// is called when the namespace is purged, so unlike the other methods it
+// doesn't read the namespace from the repositories.
+func (r *Reconciler) DeleteNamespace(ctx context.Context, tenantID, cluster, name string) (err error) {
+	if r == nil || repositories.IsDryRun(ctx) {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Reconciler.DeleteNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if err := r.export(ctx, tenantID, name, fmt.Sprintf("Remove namespace %s of tenant %s", name, tenantID)); err != nil {
+		r.errors.WithLabelValues(tenantID).Inc()
+		return err
+	}
+	client, err := r.clientFor(ctx, cluster)
+	if err != nil {
+		r.errors.WithLabelValues(tenantID).Inc()
+		return err
+	}
+	if client == nil {
+		return nil
+	}
+	if err := client.DeleteNamespace(ctx, name, tenantID); err != nil {
+		r.errors.WithLabelValues(tenantID).Inc()
+		return err
+	}
+	logging.FromContext(ctx).InfoContext(ctx, "deleted namespace from cluster", "tenant_id", tenantID, "namespace", name)
+	return nil
+}
+
+// DiscoverNamespaces lists the namespaces of a cluster that naas doesn't
+// manage. ok is false when naas has no connection to the cluster.
+func (r *Reconciler) DiscoverNamespaces(ctx context.Context, cluster string) (_ []domain.DiscoveredNamespace, ok bool, err error) {
+	if r == nil {
+		return nil, false, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Reconciler.DiscoverNamespaces", trace.WithAttributes(attribute.String("naas.cluster.name", cluster)))
+	defer tracing.End(span, &err)
+
+	client, err := r.clientFor(ctx, cluster)
+	if err != nil || client == nil {
+		return nil, false, err
+	}
+	namespaces, err := client.ListNamespaces(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var discovered []domain.DiscoveredNamespace
+	for _, ns := range namespaces {
+		if ns.Labels[kube.LabelManagedBy] == kube.ManagedBy {
+			continue
+		}
+		discovered = append(discovered, domain.DiscoveredNamespace{
+			Name:        ns.Name,
+			Labels:      ns.Labels,
+			ManagedBy:   ns.Labels[kube.LabelManagedBy],
+			Terminating: ns.DeletionTimestamp != nil,
+		})
+	}
+	return discovered, true, nil
+}
+
+// AdoptNamespace marks an existing namespace of a cluster as managed by
+// naas for tenantID, so that it can be reconciled.
+func (r *Reconciler) AdoptNamespace(ctx context.Context, tenantID, cluster, name string) (err error) {
+	if r == nil || repositories.IsDryRun(ctx) {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "Reconciler.AdoptNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	client, err := r.clientFor(ctx, cluster)
+	if err != nil || client == nil {
+		return err
+	}
+	return client.AdoptNamespace(ctx, name, tenantID)
+}
+
+// ReconcileTenant applies the desired state of every namespace of a tenant.
+func (r *Reconciler) ReconcileTenant(ctx context.Context, tenantID string) error {
+	if r == nil || repositories.IsDryRun(ctx) {
+		return nil
+	}
+
+	all, err := r.namespaces.ListAllNamespaces(ctx, repositories.IncludeDeleted(true))
+	if err != nil {
+		return err
+	}
+
+	failed := r.reconcileTenant(ctx, tenantID, all[tenantID], "Update tenant "+tenantID)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d namespaces of tenant %s failed to reconcile", failed, len(all[tenantID]), tenantID)
+	}
+	return nil
+}
+
+// ReconcileAll applies the desired state of every namespace.
+func (r *Reconciler) ReconcileAll(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	all, err := r.namespaces.ListAllNamespaces(ctx, repositories.IncludeDeleted(true))
+	if err != nil {
+		return err
+	}
+
+	var failed, total int
+	for tenantID, namespaces := range all {
+		total += len(namespaces)
+		failed += r.reconcileTenant(ctx, tenantID, namespaces, "Resync tenant "+tenantID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d namespaces failed to reconcile", failed, total)
+	}
+	return nil
+}
+
+// Run reconciles everything every interval until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	if r == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A resync under way is finished rather than left half applied.
+			if err := r.ReconcileAll(context.WithoutCancel(ctx)); err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "resync incomplete", "error", err)
+			}
+		}
+	}
+}
+
+// reconcileTenant applies the given namespaces of a tenant and exports its
+// manifests once. It returns the number of namespaces that failed; a failed
+// export fails them all.
+func (r *Reconciler) reconcileTenant(ctx context.Context, tenantID string, namespaces []domain.Namespace, message string) int {
+	if err := r.export(ctx, tenantID, "", message); err != nil {
+		r.errors.WithLabelValues(tenantID).Inc()
+		logging.FromContext(ctx).WarnContext(ctx, "export failed, will retry on resync", "tenant_id", tenantID, "error", err)
+		for _, ns := range namespaces {
+			r.recordSynced(ctx, tenantID, ns.Name, err)
+		}
+		return len(namespaces)
+	}
+
+	var failed int
+	for _, ns := range namespaces {
+		if err := r.reconcileNamespace(ctx, tenantID, ns.Name, false); err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// recordSynced sets the Synced condition of a namespace from the outcome of
+// reconciling it, so that failures show on the namespace and not only in
+// the logs.
+func (r *Reconciler) recordSynced(ctx context.Context, tenantID, name string, err error) {
+	condition := domain.Condition{Type: domain.ConditionSynced, Status: err == nil, LastTransitionTime: time.Now()}
+	if err != nil {
+		condition.Message = err.Error()
+	}
+	// Recorded even when ctx was cancelled midway
+	ctx = context.WithoutCancel(ctx)
+	if err := r.namespaces.SetCondition(ctx, tenantID, name, condition); err != nil && !errors.Is(err, repositories.ErrNamespaceNotFound) {
+		logging.FromContext(ctx).WarnContext(ctx, "recording sync status failed", "tenant_id", tenantID, "namespace", name, "error", err)
+	}
+}
+
+// desired reads a namespace and renders the objects it should have in its
+// cluster.
+func (r *Reconciler) desired(ctx context.Context, tenantID, name string) (*domain.Namespace, *Objects, error) {
+	ns, err := r.namespaces.GetNamespace(ctx, tenantID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	tenant, err := r.tenant(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ns.Parent != "" {
+		namespaces, err := r.namespaces.GetAllNamespaces(ctx, tenantID, repositories.IncludeDeleted(true))
+		if err != nil {
+			return nil, nil, err
+		}
+		ns.NamespaceSpec = domain.NewHierarchy(namespaces).EffectiveSpec(name)
+	}
+	objects, err := Render(tenantID, tenant, ns, r.network)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ns, objects, nil
+}
+
+// tenant returns the tenant record, or nil for namespaces created for a
+// tenant that was never registered.
+func (r *Reconciler) tenant(ctx context.Context, tenantID string) (*domain.Tenant, error) {
+	tenant, err := r.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, repositories.ErrTenantNotFound) {
+		return nil, nil
+	}
+	return tenant, err
+}
+
+// ForgetCluster drops the connection to a registered cluster, so that the
+// next reconciliation connects with its current registration.
+func (r *Reconciler) ForgetCluster(name string) {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.clients, name)
+}
+
+// clientFor returns the client of a registered cluster, connecting on first
+// use, or the default client for the empty name. The default client may be
+// nil, as is the client of a cluster managed through GitOps: its namespaces
+// are only exported.
+func (r *Reconciler) clientFor(ctx context.Context, cluster string) (*kube.Client, error) {
+	if cluster == "" {
+		return r.client, nil
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if client, ok := r.clients[cluster]; ok {
+		return client, nil
+	}
+	registered, err := r.clusters.GetCluster(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+	}
+	if registered.GitOps {
+		if r.sink == nil {
+			return nil, fmt.Errorf("cluster %s is managed through GitOps, but no GitOps repository is configured", cluster)
+		}
+		r.clients[cluster] = nil
+		return nil, nil
+	}
+	client, err := r.connect(*registered)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+	}
+	r.clients[cluster] = client
+	return client, nil
+}
+
+func (r *Reconciler) apply(ctx context.Context, client *kube.Client, objects *Objects) error {
+	name := objects.Namespace.Name
+
+	if err := client.ApplyNamespace(ctx, objects.Namespace, objects.FormerTenants...); err != nil {
+		return err
+	}
+	if err := client.ApplyNetworkPolicies(ctx, name, objects.NetworkPolicies); err != nil {
+		return fmt.Errorf("network policies: %w", err)
+	}
+	if err := client.ApplyResourceQuotas(ctx, name, objects.ResourceQuotas); err != nil {
+		return fmt.Errorf("resource quotas: %w", err)
+	}
+	if err := client.ApplyRoles(ctx, name, objects.Roles); err != nil {
+		return fmt.Errorf("roles: %w", err)
+	}
+	if err := client.ApplyRoleBindings(ctx, name, objects.RoleBindings); err != nil {
+		return fmt.Errorf("role bindings: %w", err)
+	}
+	if err := client.SetWorkloadsSuspended(ctx, name, objects.Frozen); err != nil {
+		return fmt.Errorf("workloads: %w", err)
+	}
+	return nil
+}
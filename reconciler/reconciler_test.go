@@ -0,0 +1,840 @@
+package reconciler_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"naas/config"
+	"naas/domain"
+	"naas/kube"
+	"naas/reconciler"
+	"naas/repositories"
+	"naas/service"
+)
+
+func TestReconciler_TenantSuspension(t *testing.T) {
+	ctx := context.Background()
+	two := int32(2)
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &two},
+	})
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", ns.Labels[kube.LabelTenant])
+	assert.Equal(t, kube.ManagedBy, ns.Labels[kube.LabelManagedBy])
+
+	_, err = tenantService.TransitionTenant(ctx, "acme", domain.TenantSuspended, "")
+	assert.NoError(t, err)
+
+	policy, err := clientset.NetworkingV1().NetworkPolicies("team-a").Get(ctx, reconciler.SuspendedName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, policy.Spec.Ingress)
+	assert.Empty(t, policy.Spec.Egress)
+	quota, err := clientset.CoreV1().ResourceQuotas("team-a").Get(ctx, reconciler.SuspendedName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "0", quota.Spec.Hard.Pods().String())
+	d, err := clientset.AppsV1().Deployments("team-a").Get(ctx, "web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *d.Spec.Replicas)
+
+	_, err = tenantService.TransitionTenant(ctx, "acme", domain.TenantActive, "")
+	assert.NoError(t, err)
+
+	policies, err := clientset.NetworkingV1().NetworkPolicies("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, policies.Items)
+	quotas, err := clientset.CoreV1().ResourceQuotas("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, quotas.Items)
+	d, err = clientset.AppsV1().Deployments("team-a").Get(ctx, "web", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *d.Spec.Replicas)
+}
+
+func TestReconciler_Nil(t *testing.T) {
+	var rec *reconciler.Reconciler
+	assert.NoError(t, rec.ReconcileNamespace(context.Background(), "acme", "team-a"))
+	assert.NoError(t, rec.ReconcileAll(context.Background()))
+}
+
+func TestReconciler_DeleteAndPurge(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, rec)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, finalizers, time.Hour)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, time.Hour)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+	_, err := namespaceService.DeleteNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+
+	// Deleted namespaces are frozen until they are purged.
+	_, err = clientset.NetworkingV1().NetworkPolicies("team-a").Get(ctx, reconciler.SuspendedName, metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, purger.Purge(ctx, time.Now().Add(2*time.Hour)))
+
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRender_ExpiresAt(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	objects, err := reconciler.Render("acme", nil, &domain.Namespace{Name: "preview", ExpiresAt: &expiresAt}, config.Network{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2030-01-02T03:04:05Z", objects.Namespace.Annotations[reconciler.AnnotationExpiresAt])
+
+	objects, err = reconciler.Render("acme", nil, &domain.Namespace{Name: "team-a"}, config.Network{})
+	assert.NoError(t, err)
+	assert.Empty(t, objects.Namespace.Annotations)
+}
+
+func TestRender_Spec(t *testing.T) {
+	ns := &domain.Namespace{
+		Name: "team-a",
+		NamespaceSpec: domain.NamespaceSpec{
+			Labels: map[string]string{"team": "payments", kube.LabelTenant: "spoofed"},
+			Quota:  map[string]string{"pods": "10"},
+			NetworkPolicies: []domain.NetworkPolicy{{
+				Name:  "allow-web",
+				From:  []domain.PolicyPeer{{CIDR: "10.0.0.0/8"}},
+				Ports: []domain.PolicyPort{{Port: 8080}},
+			}},
+			RoleBindings: []domain.RoleBinding{{
+				Name:     "developers",
+				Role:     "edit",
+				Subjects: []domain.Subject{{Kind: "Group", Name: "devs"}, {Kind: "ServiceAccount", Name: "ci"}},
+			}},
+		},
+	}
+
+	objects, err := reconciler.Render("acme", nil, ns, config.Network{})
+	assert.NoError(t, err)
+	assert.Equal(t, "payments", objects.Namespace.Labels["team"])
+	assert.Equal(t, "acme", objects.Namespace.Labels[kube.LabelTenant])
+
+	if assert.Len(t, objects.ResourceQuotas, 1) {
+		assert.Equal(t, reconciler.QuotaName, objects.ResourceQuotas[0].Name)
+		assert.Equal(t, "10", objects.ResourceQuotas[0].Spec.Hard.Pods().String())
+	}
+	if assert.Len(t, objects.NetworkPolicies, 1) {
+		policy := objects.NetworkPolicies[0]
+		assert.Equal(t, "allow-web", policy.Name)
+		assert.Equal(t, "10.0.0.0/8", policy.Spec.Ingress[0].From[0].IPBlock.CIDR)
+		assert.Equal(t, int32(8080), policy.Spec.Ingress[0].Ports[0].Port.IntVal)
+	}
+	if assert.Len(t, objects.RoleBindings, 1) {
+		binding := objects.RoleBindings[0]
+		assert.Equal(t, "edit", binding.RoleRef.Name)
+		assert.Equal(t, "team-a", binding.Subjects[1].Namespace)
+	}
+
+	// Frozen namespaces keep their quota and bindings but not their policies
+	ns.DeletionTimestamp = &time.Time{}
+	objects, err = reconciler.Render("acme", nil, ns, config.Network{})
+	assert.NoError(t, err)
+	assert.Len(t, objects.NetworkPolicies, 1)
+	assert.Equal(t, reconciler.SuspendedName, objects.NetworkPolicies[0].Name)
+	assert.Len(t, objects.RoleBindings, 1)
+
+	// Quotas that don't parse fail the render rather than the process
+	ns.Quota = map[string]string{"pods": "lots"}
+	_, err = reconciler.Render("acme", nil, ns, config.Network{})
+	assert.ErrorContains(t, err, "quota pods")
+}
+
+func TestRender_Isolation(t *testing.T) {
+	network := config.Network{Isolation: true, IngressNamespace: "ingress-nginx", DNSNamespace: "kube-system"}
+	render := func(ns *domain.Namespace, network config.Network) *reconciler.Objects {
+		objects, err := reconciler.Render("acme", nil, ns, network)
+		assert.NoError(t, err)
+		return objects
+	}
+	names := func(objects *reconciler.Objects) []string {
+		var names []string
+		for _, policy := range objects.NetworkPolicies {
+			names = append(names, policy.Name)
+		}
+		return names
+	}
+
+	ns := &domain.Namespace{Name: "team-a"}
+	objects := render(ns, network)
+	assert.Equal(t, []string{reconciler.DefaultDenyName, reconciler.AllowTenantName, reconciler.AllowIngressName}, names(objects))
+	allowTenant := objects.NetworkPolicies[1]
+	assert.Equal(t, map[string]string{kube.LabelTenant: "acme"}, allowTenant.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels)
+	assert.Empty(t, allowTenant.Spec.Egress)
+
+	ns.Isolation = &domain.NetworkIsolation{AllowTenants: []string{"partner"}, DenyIngressController: true, DenyEgress: true}
+	objects = render(ns, network)
+	assert.Equal(t, []string{reconciler.DefaultDenyName, reconciler.AllowTenantName, reconciler.AllowDNSName}, names(objects))
+	assert.Len(t, objects.NetworkPolicies[0].Spec.PolicyTypes, 2)
+	allowTenant = objects.NetworkPolicies[1]
+	assert.Len(t, allowTenant.Spec.Ingress[0].From, 2)
+	// Egress goes to the tenant only, not to the allowed tenants
+	assert.Len(t, allowTenant.Spec.Egress[0].To, 1)
+	assert.Equal(t, map[string]string{"kubernetes.io/metadata.name": "kube-system"},
+		objects.NetworkPolicies[2].Spec.Egress[0].To[0].NamespaceSelector.MatchLabels)
+
+	ns.Isolation = &domain.NetworkIsolation{Disabled: true}
+	assert.Empty(t, render(ns, network).NetworkPolicies)
+	ns.Isolation = nil
+	assert.Empty(t, render(ns, config.Network{}).NetworkPolicies)
+
+	// Frozen namespaces only get the freeze
+	ns.DeletionTimestamp = &time.Time{}
+	assert.Equal(t, []string{reconciler.SuspendedName}, names(render(ns, network)))
+}
+
+func TestReconciler_Membership(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme", Members: []domain.Member{{User: "alice", Role: domain.MemberAdmin}}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+
+	binding, err := clientset.RbacV1().RoleBindings("team-a").Get(ctx, domain.MemberAdmin.RoleName(), metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Role", binding.RoleRef.Kind)
+	assert.Equal(t, "alice", binding.Subjects[0].Name)
+	_, err = clientset.RbacV1().Roles("team-a").Get(ctx, domain.MemberAdmin.RoleName(), metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Changing membership updates existing namespaces
+	_, err = tenantService.SetMember(ctx, "acme", domain.Member{User: "alice", Role: domain.MemberViewer})
+	assert.NoError(t, err)
+	_, err = tenantService.SetMember(ctx, "acme", domain.Member{User: "bob", Role: domain.MemberViewer})
+	assert.NoError(t, err)
+
+	roles, err := clientset.RbacV1().Roles("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, roles.Items, 1) {
+		assert.Equal(t, domain.MemberViewer.RoleName(), roles.Items[0].Name)
+	}
+	binding, err = clientset.RbacV1().RoleBindings("team-a").Get(ctx, domain.MemberViewer.RoleName(), metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, binding.Subjects, 2)
+
+	_, err = tenantService.RemoveMember(ctx, "acme", "alice")
+	assert.NoError(t, err)
+	_, err = tenantService.RemoveMember(ctx, "acme", "bob")
+	assert.NoError(t, err)
+
+	bindings, err := clientset.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, bindings.Items)
+	roles, err = clientset.RbacV1().Roles("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, roles.Items)
+}
+
+func TestReconciler_Hierarchy(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+		Labels: map[string]string{"org": "acme"},
+		Quota:  map[string]string{"pods": "100"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "platform", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "50"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Parent: "org"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "service", Parent: "team-a"}))
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "service", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", ns.Labels["org"])
+	quota, err := clientset.CoreV1().ResourceQuotas("service").Get(ctx, reconciler.QuotaName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "100", quota.Spec.Hard.Pods().String())
+
+	// Moving a namespace updates its descendants
+	_, err = namespaceService.SetParent(ctx, "acme", "team-a", "platform")
+	assert.NoError(t, err)
+	quota, err = clientset.CoreV1().ResourceQuotas("service").Get(ctx, reconciler.QuotaName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "50", quota.Spec.Hard.Pods().String())
+}
+
+func TestReconciler_Transfer(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme", Members: []domain.Member{{User: "alice", Role: domain.MemberAdmin}}}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "globex", Members: []domain.Member{{User: "bob", Role: domain.MemberViewer}}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Parent: "org"}))
+
+	// Namespaces with children can't be moved
+	_, err := namespaceService.TransferNamespace(ctx, "acme", "org", "globex", "")
+	assert.ErrorIs(t, err, service.ErrHasChildren)
+	_, err = namespaceService.TransferNamespace(ctx, "acme", "team-a", "acme", "")
+	assert.ErrorIs(t, err, service.ErrInvalidTransfer)
+
+	namespace, err := namespaceService.TransferNamespace(ctx, "acme", "team-a", "globex", "")
+	assert.NoError(t, err)
+	assert.Empty(t, namespace.Parent)
+	assert.Equal(t, "10", namespace.Quota["pods"])
+	if assert.Len(t, namespace.Transfers, 1) {
+		assert.Equal(t, "acme", namespace.Transfers[0].From)
+		assert.Equal(t, "globex", namespace.Transfers[0].To)
+	}
+
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = namespaceRepo.GetNamespace(ctx, "globex", "team-a")
+	assert.NoError(t, err)
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", ns.Labels[kube.LabelTenant])
+	quota, err := clientset.CoreV1().ResourceQuotas("team-a").Get(ctx, reconciler.QuotaName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", quota.Spec.Hard.Pods().String())
+	assert.Equal(t, "globex", quota.Labels[kube.LabelTenant])
+
+	// Access follows the new tenant's members
+	bindings, err := clientset.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, bindings.Items, 1) {
+		assert.Equal(t, domain.MemberViewer.RoleName(), bindings.Items[0].Name)
+		assert.Equal(t, "bob", bindings.Items[0].Subjects[0].Name)
+	}
+
+	// A namespace still labelled for its former tenant is taken over
+	ns.Labels[kube.LabelTenant] = "acme"
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, rec.ReconcileNamespace(ctx, "globex", "team-a"))
+	ns, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", ns.Labels[kube.LabelTenant])
+
+	// but transfers given by clients can't be forged to take over another
+	// tenant's namespace: the name is taken on the cluster, and the
+	// transfers are ignored anyway
+	forged := &domain.Namespace{Name: "team-a", Transfers: []domain.Transfer{{From: "globex", To: "acme", At: time.Now()}}}
+	err = namespaceService.CreateNamespace(ctx, "acme", forged)
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+	ns, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "globex", ns.Labels[kube.LabelTenant])
+
+	forged = &domain.Namespace{Name: "team-b", Transfers: []domain.Transfer{{From: "globex", To: "acme", At: time.Now()}}}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", forged))
+	stored, err := namespaceRepo.GetNamespace(ctx, "acme", "team-b")
+	assert.NoError(t, err)
+	assert.Empty(t, stored.Transfers)
+}
+
+func TestReconciler_Bulk(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+	bulkService := service.NewBulkService(tenantService, namespaceService, rec)
+
+	// Rolled back operations never reach the cluster
+	report, err := bulkService.Apply(ctx, &domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+	}})
+	assert.NoError(t, err)
+	assert.False(t, report.Committed)
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	report, err = bulkService.Apply(ctx, &domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "org"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a", Parent: "org"}},
+		{Action: domain.BulkUpdate, TenantID: "acme", Namespace: &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+			Quota: map[string]string{"pods": "10"},
+		}}},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, report.Committed)
+
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "org", metav1.GetOptions{})
+	assert.NoError(t, err)
+	quota, err := clientset.CoreV1().ResourceQuotas("team-a").Get(ctx, reconciler.QuotaName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", quota.Spec.Hard.Pods().String())
+}
+
+func TestReconciler_DryRun(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+
+	dryRun := repositories.DryRun(ctx)
+	assert.NoError(t, namespaceService.CreateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-b"}))
+	_, err := clientset.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// A dry-run update doesn't even repair the drift a real one would
+	assert.NoError(t, clientset.CoreV1().Namespaces().Delete(ctx, "team-a", metav1.DeleteOptions{}))
+	_, err = namespaceService.UpdateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-a"})
+	assert.NoError(t, err)
+	_, err = tenantService.UpdateTenant(dryRun, &domain.Tenant{ID: "acme", Name: "Acme"})
+	assert.NoError(t, err)
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestReconciler_Clusters(t *testing.T) {
+	ctx := context.Background()
+	local := fake.NewSimpleClientset()
+	east := fake.NewSimpleClientset()
+	connect := func(cluster domain.Cluster) (*kube.Client, error) {
+		if cluster.Name == "east" {
+			return kube.New(east), nil
+		}
+		return nil, errors.New("unreachable")
+	}
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterRepo := repositories.NewClusterRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, clusterRepo, kube.New(local), connect, nil, config.Network{})
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, rec)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, clusterRepo, rec, finalizers, time.Hour)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, time.Hour)
+
+	// Without registered clusters namespaces go to the local cluster
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+	_, err := local.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, clusterRepo.CreateCluster(ctx, &domain.Cluster{Name: "east", Region: "us-east", Kubeconfig: "/east"}))
+	assert.NoError(t, clusterRepo.CreateCluster(ctx, &domain.Cluster{Name: "west", Region: "us-west", Kubeconfig: "/west"}))
+
+	ns := &domain.Namespace{Name: "team-b", Placement: &domain.Placement{Region: "us-east"}}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", ns))
+	assert.Equal(t, "east", ns.Cluster)
+	if synced := ns.Condition(domain.ConditionSynced); assert.NotNil(t, synced) {
+		assert.True(t, synced.Status)
+	}
+	_, err = east.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = local.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// Failing to reach a cluster doesn't fail the write; it is retried on resync
+	ns = &domain.Namespace{Name: "team-c", Cluster: "west"}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", ns))
+	assert.Error(t, rec.ReconcileNamespace(ctx, "acme", "team-c"))
+	// but shows on the namespace
+	if synced := ns.Condition(domain.ConditionSynced); assert.NotNil(t, synced) {
+		assert.False(t, synced.Status)
+		assert.Contains(t, synced.Message, "unreachable")
+	}
+	stored, err := namespaceRepo.GetNamespace(ctx, "acme", "team-c")
+	assert.NoError(t, err)
+	assert.Equal(t, ns.ResourceVersion, stored.ResourceVersion)
+	assert.Equal(t, ns.Conditions, stored.Conditions)
+
+	// Purged namespaces are deleted from the cluster they were placed on
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "team-b")
+	assert.NoError(t, err)
+	assert.NoError(t, purger.Purge(ctx, time.Now().Add(2*time.Hour)))
+	_, err = east.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// Adopted namespaces need room on their cluster like new ones
+	assert.NoError(t, clusterRepo.UpdateCluster(ctx, &domain.Cluster{Name: "east", Region: "us-east", Kubeconfig: "/east", Capacity: 1}))
+	for _, name := range []string{"legacy-a", "legacy-b"} {
+		_, err = east.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Labels: map[string]string{"owner": "acme"},
+		}}, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	report, err := namespaceService.AdoptNamespaces(ctx, &domain.Adoption{Cluster: "east", Rules: []domain.AdoptionRule{{TenantLabel: "owner"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.AdoptedNamespace{{Namespace: "legacy-a", TenantID: "acme", Rule: 0}}, report.Adopted)
+	assert.Equal(t, []domain.SkippedNamespace{{Namespace: "legacy-b", Reason: "no cluster can take the namespace: cluster east is full"}}, report.Skipped)
+	legacy, err := east.CoreV1().Namespaces().Get(ctx, "legacy-b", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, legacy.Labels[kube.LabelTenant])
+
+	// A namespace naas no longer owns in the cluster is purged but left there
+	legacy, err = east.CoreV1().Namespaces().Get(ctx, "legacy-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	delete(legacy.Labels, kube.LabelManagedBy)
+	_, err = east.CoreV1().Namespaces().Update(ctx, legacy, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "legacy-a")
+	assert.NoError(t, err)
+	assert.NoError(t, purger.Purge(ctx, time.Now().Add(2*time.Hour)))
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "legacy-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = east.CoreV1().Namespaces().Get(ctx, "legacy-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestReconciler_Drift(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+	scanner := service.NewDriftScanner(namespaceRepo, rec, false)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		Labels: map[string]string{"team": "payments"},
+		Quota:  map[string]string{"pods": "10"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b"}))
+
+	drift, err := rec.DetectDrift(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.False(t, drift.Drifted())
+
+	// Edit the cluster behind naas's back
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	ns.Labels["team"] = "billing"
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, clientset.CoreV1().ResourceQuotas("team-a").Delete(ctx, reconciler.QuotaName, metav1.DeleteOptions{}))
+	_, err = clientset.NetworkingV1().NetworkPolicies("team-a").Create(ctx, &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-all", Labels: map[string]string{kube.LabelManagedBy: kube.ManagedBy}},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, clientset.CoreV1().Namespaces().Delete(ctx, "team-b", metav1.DeleteOptions{}))
+
+	assert.NoError(t, scanner.Scan(ctx))
+	drifted, err := scanner.ListDrift(ctx, "acme")
+	assert.NoError(t, err)
+	if assert.Len(t, drifted, 2) {
+		assert.Equal(t, []domain.Difference{
+			{Kind: domain.DriftNamespace, Name: "team-a", Change: domain.DriftModified, Detail: "label team"},
+			{Kind: domain.DriftNetworkPolicy, Name: "allow-all", Change: domain.DriftUnexpected},
+			{Kind: domain.DriftResourceQuota, Name: reconciler.QuotaName, Change: domain.DriftMissing},
+		}, drifted[0].Differences)
+		assert.Equal(t, []domain.Difference{
+			{Kind: domain.DriftNamespace, Name: "team-b", Change: domain.DriftMissing},
+		}, drifted[1].Differences)
+	}
+
+	drift, err = scanner.RemediateNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.False(t, drift.Drifted())
+
+	// Remediating scanners fix what they find
+	scanner = service.NewDriftScanner(namespaceRepo, rec, true)
+	assert.NoError(t, scanner.Scan(ctx))
+	drifted, err = scanner.ListDrift(ctx, "")
+	assert.NoError(t, err)
+	if assert.Len(t, drifted, 1) {
+		assert.Equal(t, "team-b", drifted[0].Namespace)
+		assert.True(t, drifted[0].Remediated)
+	}
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, scanner.Scan(ctx))
+	drifted, err = scanner.ListDrift(ctx, "")
+	assert.NoError(t, err)
+	assert.Empty(t, drifted)
+}
+
+func TestReconciler_Adoption(t *testing.T) {
+	ctx := context.Background()
+	labelled := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	clientset := fake.NewSimpleClientset(
+		labelled("kube-system", nil),
+		labelled("legacy-payments", map[string]string{"team": "payments"}),
+		labelled("legacy-billing", map[string]string{"owner": "globex"}),
+		labelled("legacy-frozen", map[string]string{"owner": "initech"}),
+		labelled("legacy-unowned", nil),
+		labelled("argo", map[string]string{kube.LabelManagedBy: "helm", "owner": "acme"}),
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "legacy-payments"}},
+	)
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, kube.New(clientset), nil, nil, config.Network{})
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, rec, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "initech"}))
+	_, err := tenantService.TransitionTenant(ctx, "initech", domain.TenantSuspended, "")
+	assert.NoError(t, err)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+
+	adoption := &domain.Adoption{
+		Rules: []domain.AdoptionRule{
+			{Selector: map[string]string{"team": "payments"}, Tenant: "acme"},
+			{TenantLabel: "owner"},
+		},
+		DryRun: true,
+	}
+	expected := &domain.AdoptionReport{
+		DryRun: true,
+		Adopted: []domain.AdoptedNamespace{
+			{Namespace: "legacy-billing", TenantID: "globex", Rule: 1},
+			{Namespace: "legacy-payments", TenantID: "acme", Rule: 0},
+		},
+		Skipped: []domain.SkippedNamespace{
+			{Namespace: "argo", Reason: "managed by helm"},
+			{Namespace: "kube-system", Reason: "system namespace"},
+			{Namespace: "legacy-frozen", Reason: "tenant initech: tenant is suspended"},
+			{Namespace: "legacy-unowned", Reason: "no rule matches"},
+		},
+	}
+
+	report, err := namespaceService.AdoptNamespaces(ctx, adoption)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, report)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "legacy-payments")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	// A dry run can also be asked for by the request
+	adoption.DryRun = false
+	report, err = namespaceService.AdoptNamespaces(repositories.DryRun(ctx), adoption)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, report)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "legacy-payments")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "legacy-payments", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, ns.Labels[kube.LabelTenant])
+
+	expected.DryRun = false
+	report, err = namespaceService.AdoptNamespaces(ctx, adoption)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, report)
+
+	adopted, err := namespaceRepo.GetNamespace(ctx, "acme", "legacy-payments")
+	assert.NoError(t, err)
+	// Running workloads aren't cut off by the default policies
+	assert.Equal(t, &domain.NetworkIsolation{Disabled: true}, adopted.Isolation)
+	ns, err = clientset.CoreV1().Namespaces().Get(ctx, "legacy-payments", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", ns.Labels[kube.LabelTenant])
+	assert.Equal(t, "payments", ns.Labels["team"])
+	// Adopted namespaces keep their contents
+	_, err = clientset.AppsV1().Deployments("legacy-payments").Get(ctx, "api", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Adopted namespaces are managed from now on
+	report, err = namespaceService.AdoptNamespaces(ctx, adoption)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Adopted)
+	assert.Len(t, report.Skipped, 4)
+
+	// Isolation is opt-in, and a namespace that can't be labelled isn't
+	// recorded either
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, labelled("legacy-ledger", map[string]string{"owner": "acme"}), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, labelled("legacy-locked", map[string]string{"owner": "acme"}), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	clientset.PrependReactor("update", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.UpdateAction).GetObject().(*corev1.Namespace).Name == "legacy-locked" {
+			return true, nil, errors.New("forbidden")
+		}
+		return false, nil, nil
+	})
+	adoption.Isolation = &domain.NetworkIsolation{DenyEgress: true}
+	report, err = namespaceService.AdoptNamespaces(ctx, adoption)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.AdoptedNamespace{{Namespace: "legacy-ledger", TenantID: "acme", Rule: 1}}, report.Adopted)
+	assert.Contains(t, report.Skipped, domain.SkippedNamespace{Namespace: "legacy-locked", Reason: "forbidden"})
+	adopted, err = namespaceRepo.GetNamespace(ctx, "acme", "legacy-ledger")
+	assert.NoError(t, err)
+	assert.Equal(t, adoption.Isolation, adopted.Isolation)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "legacy-locked")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	_, err = namespaceService.AdoptNamespaces(ctx, &domain.Adoption{})
+	assert.ErrorIs(t, err, service.ErrInvalidAdoption)
+	_, err = namespaceService.AdoptNamespaces(ctx, &domain.Adoption{Cluster: "east", Rules: adoption.Rules})
+	assert.ErrorIs(t, err, repositories.ErrClusterNotFound)
+}
+
+type recordingSink map[string][]reconciler.Manifest
+
+func (s recordingSink) WriteTenant(_ context.Context, tenantID string, manifests []reconciler.Manifest, _ string) error {
+	s[tenantID] = manifests
+	return nil
+}
+
+func paths(manifests []reconciler.Manifest) []string {
+	var result []string
+	for _, m := range manifests {
+		result = append(result, m.Path)
+	}
+	return result
+}
+
+func TestReconciler_Manifests(t *testing.T) {
+	ctx := context.Background()
+	sink := recordingSink{}
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	// Without a cluster, manifests are only exported
+	rec := reconciler.New(tenantRepo, namespaceRepo, nil, nil, nil, sink, config.Network{})
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, rec)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, rec, finalizers, time.Hour)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, time.Hour)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b"}))
+	assert.Equal(t, []string{
+		"local/team-a/namespace-team-a.yaml",
+		"local/team-a/resourcequota-naas-quota.yaml",
+		"local/team-b/namespace-team-b.yaml",
+	}, paths(sink["acme"]))
+
+	manifests, err := rec.RenderTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, sink["acme"], manifests)
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  labels:
+    app.kubernetes.io/managed-by: naas
+    naas.io/tenant: acme
+  name: team-b
+spec: {}
+`, string(manifests[2].Data))
+
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "team-b")
+	assert.NoError(t, err)
+	assert.Contains(t, paths(sink["acme"]), "local/team-b/networkpolicy-naas-suspended.yaml")
+
+	assert.NoError(t, purger.Purge(ctx, time.Now().Add(2*time.Hour)))
+	assert.Equal(t, []string{
+		"local/team-a/namespace-team-a.yaml",
+		"local/team-a/resourcequota-naas-quota.yaml",
+	}, paths(sink["acme"]))
+}
+
+func TestReconciler_GitOpsCluster(t *testing.T) {
+	ctx := context.Background()
+	sink := recordingSink{}
+	connect := func(cluster domain.Cluster) (*kube.Client, error) {
+		t.Errorf("connected to cluster %s", cluster.Name)
+		return nil, errors.New("unexpected")
+	}
+
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterRepo := repositories.NewClusterRepository()
+	assert.NoError(t, clusterRepo.CreateCluster(ctx, &domain.Cluster{Name: "edge", GitOps: true}))
+
+	// Namespaces on a GitOps cluster are only exported, under its directory
+	rec := reconciler.New(tenantRepo, namespaceRepo, clusterRepo, nil, connect, sink, config.Network{})
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, clusterRepo, rec, nil, time.Hour)
+	ns := &domain.Namespace{Name: "team-a", Cluster: "edge"}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", ns))
+	assert.Equal(t, []string{"clusters/edge/team-a/namespace-team-a.yaml"}, paths(sink["acme"]))
+	if synced := ns.Condition(domain.ConditionSynced); assert.NotNil(t, synced) {
+		assert.True(t, synced.Status)
+	}
+
+	// and can't be reconciled without a repository
+	rec = reconciler.New(tenantRepo, namespaceRepo, clusterRepo, nil, connect, nil, config.Network{})
+	assert.ErrorContains(t, rec.ReconcileNamespace(ctx, "acme", "team-a"), "no GitOps repository is configured")
+}
+
+func TestWriteTarball(t *testing.T) {
+	manifests := []reconciler.Manifest{
+		{Path: "team-b/namespace-team-b.yaml", Data: []byte("b\n")},
+		{Path: "team-a/namespace-team-a.yaml", Data: []byte("a\n")},
+	}
+
+	var first, second bytes.Buffer
+	assert.NoError(t, reconciler.WriteTarball(&first, "acme", manifests))
+	assert.NoError(t, reconciler.WriteTarball(&second, "acme", manifests))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	gz, err := gzip.NewReader(&first)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"acme/team-a/namespace-team-a.yaml", "acme/team-b/namespace-team-b.yaml"}, names)
+
+	escape := []reconciler.Manifest{{Path: "../../escape/namespace-escape.yaml", Data: []byte("x\n")}}
+	assert.Error(t, reconciler.WriteTarball(io.Discard, "acme", escape))
+	assert.Error(t, reconciler.WriteTarball(io.Discard, "..", manifests))
+}
@@ -0,0 +1,207 @@
+// reconciler/render.go
+
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"naas/config"
+	"naas/domain"
+	"naas/kube"
+)
+
+// SuspendedName names the objects that freeze a namespace of a suspended or
+// archived tenant, and deleted namespaces awaiting purge.
+const SuspendedName = "naas-suspended"
+
+// QuotaName names the resource quota holding a namespace's quota.
+const QuotaName = "naas-quota"
+
+// AnnotationExpiresAt shows the expiry of an ephemeral namespace in the
+// cluster, as an RFC 3339 timestamp.
+const AnnotationExpiresAt = "naas.io/expires-at"
+
+// Objects is the desired cluster state of one naas namespace.
+type Objects struct {
+	Namespace       *corev1.Namespace
+	NetworkPolicies []*networkingv1.NetworkPolicy
+	ResourceQuotas  []*corev1.ResourceQuota
+	Roles           []*rbacv1.Role
+	RoleBindings    []*rbacv1.RoleBinding
+	// Frozen tells the reconciler to scale the namespace's workloads down.
+	Frozen bool
+	// FormerTenants owned the namespace before it was transferred. The
+	// namespace is taken over from them in the cluster.
+	FormerTenants []string
+}
+
+// Render computes the objects for namespace ns owned by tenantID. tenant may
+// be nil when the tenant has no record. network configures the default
+// network policies. Quotas stored before they were validated may not parse;
+// they fail the render.
+func Render(tenantID string, tenant *domain.Tenant, ns *domain.Namespace, network config.Network) (*Objects, error) {
+	objects := &Objects{
+		Namespace: &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ns.Name,
+				// The managed labels win over the namespace's own.
+				Labels:      merge(ns.Labels, managedLabels(tenantID)),
+				Annotations: merge(ns.Annotations, nil),
+			},
+		},
+		FormerTenants: ns.FormerTenants(),
+	}
+
+	if ns.ExpiresAt != nil {
+		objects.Namespace.Annotations = merge(objects.Namespace.Annotations, map[string]string{
+			AnnotationExpiresAt: ns.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	if len(ns.Quota) > 0 {
+		hard := make(corev1.ResourceList, len(ns.Quota))
+		for name, quantity := range ns.Quota {
+			q, err := resource.ParseQuantity(quantity)
+			if err != nil {
+				return nil, fmt.Errorf("quota %s: %w", name, err)
+			}
+			hard[corev1.ResourceName(name)] = q
+		}
+		objects.ResourceQuotas = append(objects.ResourceQuotas, &corev1.ResourceQuota{
+			ObjectMeta: objectMeta(QuotaName, ns.Name, tenantID),
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		})
+	}
+
+	if tenant != nil {
+		objects.Roles = memberRoles(tenantID, ns.Name, tenant.Members)
+	}
+	for _, b := range domain.EffectiveBindings(tenant, ns) {
+		objects.RoleBindings = append(objects.RoleBindings, roleBinding(tenantID, ns.Name, b))
+	}
+
+	if ns.Deleted() || tenant != nil && (tenant.Status.Frozen() || tenant.Deleted()) {
+		// The namespace's own policies are left out: policies only ever
+		// allow traffic, so they would open holes in the freeze.
+		objects.Frozen = true
+		objects.NetworkPolicies = append(objects.NetworkPolicies, &networkingv1.NetworkPolicy{
+			ObjectMeta: objectMeta(SuspendedName, ns.Name, tenantID),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{
+					networkingv1.PolicyTypeIngress,
+					networkingv1.PolicyTypeEgress,
+				},
+			},
+		})
+		objects.ResourceQuotas = append(objects.ResourceQuotas, &corev1.ResourceQuota{
+			ObjectMeta: objectMeta(SuspendedName, ns.Name, tenantID),
+			Spec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("0")},
+			},
+		})
+		return objects, nil
+	}
+
+	objects.NetworkPolicies = append(objects.NetworkPolicies, isolationPolicies(tenantID, ns, network)...)
+	for _, p := range ns.NetworkPolicies {
+		objects.NetworkPolicies = append(objects.NetworkPolicies, networkPolicy(tenantID, ns.Name, p))
+	}
+
+	return objects, nil
+}
+
+func networkPolicy(tenantID, namespace string, p domain.NetworkPolicy) *networkingv1.NetworkPolicy {
+	rule := networkingv1.NetworkPolicyIngressRule{}
+	for _, peer := range p.From {
+		if peer.CIDR != "" {
+			rule.From = append(rule.From, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: peer.CIDR}})
+			continue
+		}
+		rule.From = append(rule.From, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: selector(peer.NamespaceSelector),
+			PodSelector:       selector(peer.PodSelector),
+		})
+	}
+	for _, port := range p.Ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != "" {
+			protocol = corev1.Protocol(port.Protocol)
+		}
+		number := intstr.FromInt32(port.Port)
+		rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &number})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: objectMeta(p.Name, namespace, tenantID),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{rule},
+		},
+	}
+}
+
+func roleBinding(tenantID, namespace string, b domain.Binding) *rbacv1.RoleBinding {
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: objectMeta(b.Name, namespace, tenantID),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     b.RoleKind,
+			Name:     b.Role,
+		},
+	}
+	for _, s := range b.Subjects {
+		subject := rbacv1.Subject{Kind: s.Kind, Name: s.Name, Namespace: s.Namespace}
+		if s.Kind != rbacv1.ServiceAccountKind {
+			subject.APIGroup = rbacv1.GroupName
+		}
+		binding.Subjects = append(binding.Subjects, subject)
+	}
+	return binding
+}
+
+// selector converts a label map into a selector; nil stays nil, while an
+// empty map selects everything.
+func selector(labels map[string]string) *metav1.LabelSelector {
+	if labels == nil {
+		return nil
+	}
+	return &metav1.LabelSelector{MatchLabels: labels}
+}
+
+func merge(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = v
+	}
+	return result
+}
+
+func managedLabels(tenantID string) map[string]string {
+	return map[string]string{
+		kube.LabelManagedBy: kube.ManagedBy,
+		kube.LabelTenant:    tenantID,
+	}
+}
+
+func objectMeta(name, namespace, tenantID string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    managedLabels(tenantID),
+	}
+}
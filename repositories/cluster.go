@@ -0,0 +1,130 @@
+// repositories/cluster.go
+
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	. "naas/domain"
+	"naas/logging"
+	"naas/tracing"
+)
+
+// ClusterRepository is the registry of clusters namespaces can be placed on.
+type ClusterRepository struct {
+	mtx      sync.RWMutex
+	clusters map[string]Cluster
+}
+
+func NewClusterRepository() *ClusterRepository {
+	return &ClusterRepository{clusters: make(map[string]Cluster)}
+}
+
+func (r *ClusterRepository) CreateCluster(ctx context.Context, cluster *Cluster) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterRepository.CreateCluster", spanAttributes(attribute.String("naas.cluster.name", cluster.Name)))
+	defer tracing.End(span, &err)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.clusters[cluster.Name]; ok {
+		return ErrClusterExists
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.clusters[cluster.Name] = *cluster
+	logging.FromContext(ctx).DebugContext(ctx, "stored cluster", "cluster", cluster.Name)
+	return nil
+}
+
+func (r *ClusterRepository) GetCluster(ctx context.Context, name string) (_ *Cluster, err error) {
+	_, span := tracer.Start(ctx, "ClusterRepository.GetCluster", spanAttributes(attribute.String("naas.cluster.name", name)))
+	defer tracing.End(span, &err)
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cluster, ok := r.clusters[name]; ok {
+		return &cluster, nil
+	}
+	return nil, ErrClusterNotFound
+}
+
+// ListClusters returns all clusters sorted by name.
+func (r *ClusterRepository) ListClusters(ctx context.Context) ([]Cluster, error) {
+	_, span := tracer.Start(ctx, "ClusterRepository.ListClusters", spanAttributes())
+	defer span.End()
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	clusters := make([]Cluster, 0, len(r.clusters))
+	for _, cluster := range r.clusters {
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters, nil
+}
+
+func (r *ClusterRepository) UpdateCluster(ctx context.Context, cluster *Cluster) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterRepository.UpdateCluster", spanAttributes(attribute.String("naas.cluster.name", cluster.Name)))
+	defer tracing.End(span, &err)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.clusters[cluster.Name]; !ok {
+		return ErrClusterNotFound
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.clusters[cluster.Name] = *cluster
+	logging.FromContext(ctx).DebugContext(ctx, "updated cluster", "cluster", cluster.Name)
+	return nil
+}
+
+func (r *ClusterRepository) DeleteCluster(ctx context.Context, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterRepository.DeleteCluster", spanAttributes(attribute.String("naas.cluster.name", name)))
+	defer tracing.End(span, &err)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.clusters[name]; !ok {
+		return ErrClusterNotFound
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	delete(r.clusters, name)
+	logging.FromContext(ctx).DebugContext(ctx, "deleted cluster", "cluster", name)
+	return nil
+}
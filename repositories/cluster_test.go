@@ -0,0 +1,39 @@
+// repositories/cluster_test.go
+
+package repositories_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+	"naas/repositories"
+)
+
+func TestClusterRepository(t *testing.T) {
+	repo := repositories.NewClusterRepository()
+	ctx := context.Background()
+
+	assert.NoError(t, repo.CreateCluster(ctx, &domain.Cluster{Name: "west", Kubeconfig: "/west"}))
+	assert.NoError(t, repo.CreateCluster(ctx, &domain.Cluster{Name: "east", Kubeconfig: "/east"}))
+	assert.ErrorIs(t, repo.CreateCluster(ctx, &domain.Cluster{Name: "east"}), repositories.ErrClusterExists)
+
+	clusters, err := repo.ListClusters(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, clusters, 2) {
+		assert.Equal(t, "east", clusters[0].Name)
+		assert.Equal(t, "west", clusters[1].Name)
+	}
+
+	assert.NoError(t, repo.UpdateCluster(ctx, &domain.Cluster{Name: "east", Kubeconfig: "/east", Capacity: 10}))
+	cluster, err := repo.GetCluster(ctx, "east")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, cluster.Capacity)
+	assert.ErrorIs(t, repo.UpdateCluster(ctx, &domain.Cluster{Name: "north"}), repositories.ErrClusterNotFound)
+
+	assert.NoError(t, repo.DeleteCluster(ctx, "east"))
+	assert.ErrorIs(t, repo.DeleteCluster(ctx, "east"), repositories.ErrClusterNotFound)
+	_, err = repo.GetCluster(ctx, "east")
+	assert.ErrorIs(t, err, repositories.ErrClusterNotFound)
+}
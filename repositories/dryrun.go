@@ -0,0 +1,17 @@
+package repositories
+
+import "context"
+
+type dryRunKey struct{}
+
+// DryRun returns a context with which changes are checked as usual, including
+// for conflicts with the stored records, but not stored.
+func DryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether changes made with ctx are only checked.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
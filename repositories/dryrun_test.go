@@ -0,0 +1,41 @@
+package repositories_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+	"naas/repositories"
+)
+
+func TestDryRun(t *testing.T) {
+	tenants := repositories.NewTenantRepository()
+	namespaces := repositories.NewNamespaceRepository()
+	ctx := context.Background()
+	dryRun := repositories.DryRun(ctx)
+
+	assert.False(t, repositories.IsDryRun(ctx))
+	assert.True(t, repositories.IsDryRun(dryRun))
+
+	assert.NoError(t, tenants.CreateTenant(dryRun, &domain.Tenant{ID: "acme"}))
+	_, err := tenants.GetTenant(ctx, "acme")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+	assert.NoError(t, namespaces.CreateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-a"}))
+	_, err = namespaces.GetAllNamespaces(ctx, "acme")
+	assert.ErrorIs(t, err, repositories.ErrNoTenantNamespaces)
+
+	// Dry runs are checked against what is stored
+	stored := &domain.Namespace{Name: "team-a"}
+	assert.NoError(t, namespaces.CreateNamespace(ctx, "acme", stored))
+	assert.ErrorIs(t, namespaces.CreateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-a"}), repositories.ErrNamespaceExists)
+	assert.ErrorIs(t, namespaces.UpdateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-a", ResourceVersion: "stale"}), repositories.ErrConflict)
+
+	deleted, err := namespaces.DeleteNamespace(dryRun, "acme", "team-a", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, deleted.Deleted())
+	namespace, err := namespaces.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, namespace)
+}
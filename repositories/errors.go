@@ -0,0 +1,27 @@
+package repositories
+
+import "errors"
+
+var (
+	ErrTenantExists       = errors.New("tenant already exists")
+	ErrTenantNotFound     = errors.New("tenant not found")
+	ErrNamespaceExists    = errors.New("namespace already exists")
+	ErrNamespaceNotFound  = errors.New("namespace not found")
+	ErrNoTenantNamespaces = errors.New("no namespaces found for tenant")
+
+	ErrTenantDeleted       = errors.New("tenant is deleted")
+	ErrTenantNotDeleted    = errors.New("tenant is not deleted")
+	ErrNamespaceDeleted    = errors.New("namespace is deleted")
+	ErrNamespaceNotDeleted = errors.New("namespace is not deleted")
+	ErrFinalizersPending   = errors.New("finalizers are pending")
+
+	// ErrConflict means a record was changed since the version an update
+	// was based on.
+	ErrConflict = errors.New("resource version conflict")
+
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrTemplateNotFound = errors.New("template not found")
+
+	ErrClusterExists   = errors.New("cluster already exists")
+	ErrClusterNotFound = errors.New("cluster not found")
+)
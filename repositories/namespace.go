@@ -3,9 +3,14 @@
 package repositories
 
 import (
-	"errors"
-	. "naas/domain"
+	"context"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	. "naas/domain"
+	"naas/logging"
+	"naas/tracing"
 )
 
 type NamespaceRepository struct {
@@ -17,40 +22,75 @@ func NewNamespaceRepository() *NamespaceRepository {
 	return &NamespaceRepository{namespaces: make(map[string]map[string]Namespace)}
 }
 
-func (r *NamespaceRepository) CreateNamespace(tenantID string, namespace *Namespace) error {
-	r.mtx.Lock()
-	defer r.mtx.Unlock()
+func (r *NamespaceRepository) CreateNamespace(ctx context.Context, tenantID string, namespace *Namespace) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.CreateNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", namespace.Name),
+	))
+	defer tracing.End(span, &err)
 
-	if _, ok := r.namespaces[tenantID]; !ok {
-		r.namespaces[tenantID] = make(map[string]Namespace)
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	if _, ok := r.namespaces[tenantID][namespace.Name]; ok {
-		return errors.New("namespace already exists")
+		return ErrNamespaceExists
+	}
+	if IsDryRun(ctx) {
+		return nil
 	}
 
+	if _, ok := r.namespaces[tenantID]; !ok {
+		r.namespaces[tenantID] = make(map[string]Namespace)
+	}
+	r.journal(ctx, tenantID, namespace.Name)
+	namespace.ResourceVersion = nextResourceVersion()
 	r.namespaces[tenantID][namespace.Name] = *namespace
+	logging.FromContext(ctx).DebugContext(ctx, "stored namespace", "tenant_id", tenantID, "namespace", namespace.Name)
 	return nil
 }
 
-func (r *NamespaceRepository) GetAllNamespaces(tenantID string) ([]Namespace, error) {
-	r.mtx.RLock()
-	defer r.mtx.RUnlock()
+// GetAllNamespaces returns the namespaces of a tenant. Deleted namespaces
+// are left out unless IncludeDeleted is given.
+func (r *NamespaceRepository) GetAllNamespaces(ctx context.Context, tenantID string, opts ...ListOption) (_ []Namespace, err error) {
+	_, span := tracer.Start(ctx, "NamespaceRepository.GetAllNamespaces", spanAttributes(attribute.String("naas.tenant.id", tenantID)))
+	defer tracing.End(span, &err)
 
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	o := newListOptions(opts)
 	if namespaces, ok := r.namespaces[tenantID]; ok {
 		result := make([]Namespace, 0, len(namespaces))
 		for _, ns := range namespaces {
+			if ns.Deleted() && !o.includeDeleted {
+				continue
+			}
 			result = append(result, ns)
 		}
 		return result, nil
 	}
 
-	return nil, errors.New("no namespaces found for tenant")
+	return nil, ErrNoTenantNamespaces
 }
 
-func (r *NamespaceRepository) GetNamespace(tenantID string, name string) (*Namespace, error) {
-	r.mtx.RLock()
-	defer r.mtx.RUnlock()
+func (r *NamespaceRepository) GetNamespace(ctx context.Context, tenantID string, name string) (_ *Namespace, err error) {
+	_, span := tracer.Start(ctx, "NamespaceRepository.GetNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	if namespaces, ok := r.namespaces[tenantID]; ok {
 		if namespace, ok := namespaces[name]; ok {
@@ -58,5 +98,319 @@ func (r *NamespaceRepository) GetNamespace(tenantID string, name string) (*Names
 		}
 	}
 
-	return nil, errors.New("namespace not found")
+	return nil, ErrNamespaceNotFound
+}
+
+func (r *NamespaceRepository) UpdateNamespace(ctx context.Context, tenantID string, namespace *Namespace) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.UpdateNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", namespace.Name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stored, ok := r.namespaces[tenantID][namespace.Name]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	if namespace.ResourceVersion != "" && namespace.ResourceVersion != stored.ResourceVersion {
+		return ErrConflict
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, tenantID, namespace.Name)
+	namespace.ResourceVersion = nextResourceVersion()
+	r.namespaces[tenantID][namespace.Name] = *namespace
+	logging.FromContext(ctx).DebugContext(ctx, "updated namespace", "tenant_id", tenantID, "namespace", namespace.Name)
+	return nil
+}
+
+// TransferNamespace moves a namespace from one tenant to another and stores
+// it as given, in a single step.
+func (r *NamespaceRepository) TransferNamespace(ctx context.Context, from, to string, namespace *Namespace) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.TransferNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", from),
+		attribute.String("naas.namespace.name", namespace.Name),
+		attribute.String("naas.transfer.to", to),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stored, ok := r.namespaces[from][namespace.Name]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	if namespace.ResourceVersion != "" && namespace.ResourceVersion != stored.ResourceVersion {
+		return ErrConflict
+	}
+	if _, ok := r.namespaces[to][namespace.Name]; ok {
+		return ErrNamespaceExists
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, from, namespace.Name)
+	r.journal(ctx, to, namespace.Name)
+	delete(r.namespaces[from], namespace.Name)
+	if len(r.namespaces[from]) == 0 {
+		delete(r.namespaces, from)
+	}
+	if _, ok := r.namespaces[to]; !ok {
+		r.namespaces[to] = make(map[string]Namespace)
+	}
+	namespace.ResourceVersion = nextResourceVersion()
+	r.namespaces[to][namespace.Name] = *namespace
+	logging.FromContext(ctx).DebugContext(ctx, "transferred namespace", "tenant_id", from, "namespace", namespace.Name, "to", to)
+	return nil
+}
+
+// SetCondition records a condition of a namespace. Conditions are status
+// rather than spec, so the resource version stays as it is.
+func (r *NamespaceRepository) SetCondition(ctx context.Context, tenantID, name string, condition Condition) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.SetCondition", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	namespace, ok := r.namespaces[tenantID][name]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	if IsDryRun(ctx) || !namespace.SetCondition(condition) {
+		return nil
+	}
+	r.journal(ctx, tenantID, name)
+	r.namespaces[tenantID][name] = namespace
+	logging.FromContext(ctx).DebugContext(ctx, "set namespace condition", "tenant_id", tenantID, "namespace", name,
+		"type", condition.Type, "status", condition.Status)
+	return nil
+}
+
+// CountNamespaces returns the number of namespaces that aren't deleted, by
+// tenant ID.
+func (r *NamespaceRepository) CountNamespaces(ctx context.Context) (map[string]int, error) {
+	_, span := tracer.Start(ctx, "NamespaceRepository.CountNamespaces", spanAttributes())
+	defer span.End()
+
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(r.namespaces))
+	for tenantID, namespaces := range r.namespaces {
+		var n int
+		for _, ns := range namespaces {
+			if !ns.Deleted() {
+				n++
+			}
+		}
+		counts[tenantID] = n
+	}
+	return counts, nil
+}
+
+// ListAllNamespaces returns every namespace keyed by tenant ID. Deleted
+// namespaces are left out unless IncludeDeleted is given.
+func (r *NamespaceRepository) ListAllNamespaces(ctx context.Context, opts ...ListOption) (map[string][]Namespace, error) {
+	_, span := tracer.Start(ctx, "NamespaceRepository.ListAllNamespaces", spanAttributes())
+	defer span.End()
+
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	o := newListOptions(opts)
+	result := make(map[string][]Namespace, len(r.namespaces))
+	for tenantID, namespaces := range r.namespaces {
+		for _, ns := range namespaces {
+			if ns.Deleted() && !o.includeDeleted {
+				continue
+			}
+			result[tenantID] = append(result[tenantID], ns)
+		}
+	}
+	return result, nil
+}
+
+// DeleteNamespace marks a namespace as deleted at the given time. The record
+// is kept until PurgeNamespace removes it.
+func (r *NamespaceRepository) DeleteNamespace(ctx context.Context, tenantID, name string, at time.Time) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.DeleteNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	namespace, ok := r.namespaces[tenantID][name]
+	if !ok {
+		return nil, ErrNamespaceNotFound
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+
+	at = at.UTC()
+	namespace.DeletionTimestamp = &at
+	if IsDryRun(ctx) {
+		return &namespace, nil
+	}
+	r.journal(ctx, tenantID, name)
+	namespace.ResourceVersion = nextResourceVersion()
+	r.namespaces[tenantID][name] = namespace
+	logging.FromContext(ctx).DebugContext(ctx, "marked namespace deleted", "tenant_id", tenantID, "namespace", name)
+	return &namespace, nil
+}
+
+// RestoreNamespace clears the deletion mark of a deleted namespace.
+func (r *NamespaceRepository) RestoreNamespace(ctx context.Context, tenantID, name string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.RestoreNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	namespace, ok := r.namespaces[tenantID][name]
+	if !ok {
+		return nil, ErrNamespaceNotFound
+	}
+	if !namespace.Deleted() {
+		return nil, ErrNamespaceNotDeleted
+	}
+
+	namespace.DeletionTimestamp = nil
+	if IsDryRun(ctx) {
+		return &namespace, nil
+	}
+	r.journal(ctx, tenantID, name)
+	namespace.ResourceVersion = nextResourceVersion()
+	r.namespaces[tenantID][name] = namespace
+	logging.FromContext(ctx).DebugContext(ctx, "restored namespace", "tenant_id", tenantID, "namespace", name)
+	return &namespace, nil
+}
+
+// PurgeNamespace removes a deleted namespace for good. Its finalizers must
+// have been removed first.
+func (r *NamespaceRepository) PurgeNamespace(ctx context.Context, tenantID, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.PurgeNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	namespace, ok := r.namespaces[tenantID][name]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	if !namespace.Deleted() {
+		return ErrNamespaceNotDeleted
+	}
+	if len(namespace.Finalizers) > 0 {
+		return ErrFinalizersPending
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, tenantID, name)
+	delete(r.namespaces[tenantID], name)
+	if len(r.namespaces[tenantID]) == 0 {
+		delete(r.namespaces, tenantID)
+	}
+	logging.FromContext(ctx).DebugContext(ctx, "purged namespace", "tenant_id", tenantID, "namespace", name)
+	return nil
+}
+
+// DiscardNamespace removes the record of a namespace whatever its state. It
+// undoes a create whose effect in the cluster failed after the record was
+// committed; namespaces that may exist because of naas are purged instead.
+func (r *NamespaceRepository) DiscardNamespace(ctx context.Context, tenantID, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceRepository.DiscardNamespace", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.namespaces[tenantID][name]; !ok {
+		return ErrNamespaceNotFound
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, tenantID, name)
+	delete(r.namespaces[tenantID], name)
+	if len(r.namespaces[tenantID]) == 0 {
+		delete(r.namespaces, tenantID)
+	}
+	logging.FromContext(ctx).DebugContext(ctx, "discarded namespace", "tenant_id", tenantID, "namespace", name)
+	return nil
+}
+
+// journal records the namespace of a tenant as it is in the transaction of
+// ctx, if that transaction holds the repository, so a rollback can put it
+// back. It must be called with the lock held, before the namespace is
+// changed.
+func (r *NamespaceRepository) journal(ctx context.Context, tenantID, name string) {
+	previous, existed := r.namespaces[tenantID][name]
+	journal(ctx, &r.mtx, func() {
+		if existed {
+			if _, ok := r.namespaces[tenantID]; !ok {
+				r.namespaces[tenantID] = make(map[string]Namespace)
+			}
+			r.namespaces[tenantID][name] = previous
+			return
+		}
+		delete(r.namespaces[tenantID], name)
+		if len(r.namespaces[tenantID]) == 0 {
+			delete(r.namespaces, tenantID)
+		}
+	})
 }
@@ -1,7 +1,9 @@
 package repositories_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"naas/domain"
@@ -15,10 +17,10 @@ func TestNamespaceRepository_CreateNamespace(t *testing.T) {
 		Name: "test-namespace",
 	}
 
-	err := repo.CreateNamespace("test-tenant", namespace)
+	err := repo.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.NoError(t, err)
 
-	err = repo.CreateNamespace("test-tenant", namespace)
+	err = repo.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.EqualError(t, err, "namespace already exists")
 }
 
@@ -30,16 +32,16 @@ func TestNamespaceRepository_GetAllNamespaces(t *testing.T) {
 		{Name: "test-namespace-2"},
 		{Name: "test-namespace-3"},
 	}
-	for _, ns := range namespaces {
-		err := repo.CreateNamespace("test-tenant", &ns)
+	for i := range namespaces {
+		err := repo.CreateNamespace(context.Background(), "test-tenant", &namespaces[i])
 		assert.NoError(t, err)
 	}
 
-	result, err := repo.GetAllNamespaces("test-tenant")
+	result, err := repo.GetAllNamespaces(context.Background(), "test-tenant")
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, namespaces, result)
 
-	result, err = repo.GetAllNamespaces("non-existent-tenant")
+	result, err = repo.GetAllNamespaces(context.Background(), "non-existent-tenant")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "no namespaces found for tenant")
 }
@@ -50,18 +52,162 @@ func TestNamespaceRepository_GetNamespace(t *testing.T) {
 	namespace := &domain.Namespace{
 		Name: "test-namespace",
 	}
-	err := repo.CreateNamespace("test-tenant", namespace)
+	err := repo.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.NoError(t, err)
 
-	result, err := repo.GetNamespace("test-tenant", "test-namespace")
+	result, err := repo.GetNamespace(context.Background(), "test-tenant", "test-namespace")
 	assert.NoError(t, err)
 	assert.Equal(t, namespace, result)
 
-	result, err = repo.GetNamespace("test-tenant", "non-existent-namespace")
+	result, err = repo.GetNamespace(context.Background(), "test-tenant", "non-existent-namespace")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "namespace not found")
 
-	result, err = repo.GetNamespace("non-existent-tenant", "test-namespace")
+	result, err = repo.GetNamespace(context.Background(), "non-existent-tenant", "test-namespace")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "namespace not found")
 }
+
+func TestNamespaceRepository_CountNamespaces(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+
+	assert.NoError(t, repo.CreateNamespace(context.Background(), "tenant-a", &domain.Namespace{Name: "ns-1"}))
+	assert.NoError(t, repo.CreateNamespace(context.Background(), "tenant-a", &domain.Namespace{Name: "ns-2"}))
+	assert.NoError(t, repo.CreateNamespace(context.Background(), "tenant-b", &domain.Namespace{Name: "ns-1"}))
+
+	counts, err := repo.CountNamespaces(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"tenant-a": 2, "tenant-b": 1}, counts)
+}
+
+func TestNamespaceRepository_ExpiredDeadline(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	err := repo.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "test-namespace"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = repo.GetAllNamespaces(ctx, "test-tenant")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNamespaceRepository_ListAllNamespaces(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+
+	ns1, ns2 := &domain.Namespace{Name: "ns-1"}, &domain.Namespace{Name: "ns-2"}
+	assert.NoError(t, repo.CreateNamespace(context.Background(), "tenant-a", ns1))
+	assert.NoError(t, repo.CreateNamespace(context.Background(), "tenant-b", ns2))
+
+	result, err := repo.ListAllNamespaces(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]domain.Namespace{
+		"tenant-a": {*ns1},
+		"tenant-b": {*ns2},
+	}, result)
+}
+
+func TestNamespaceRepository_SoftDelete(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	ctx := context.Background()
+
+	ns2 := &domain.Namespace{Name: "ns-2"}
+	assert.NoError(t, repo.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "ns-1"}))
+	assert.NoError(t, repo.CreateNamespace(ctx, "test-tenant", ns2))
+
+	err := repo.PurgeNamespace(ctx, "test-tenant", "ns-1")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotDeleted)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted, err := repo.DeleteNamespace(ctx, "test-tenant", "ns-1", at)
+	assert.NoError(t, err)
+	assert.Equal(t, at, *deleted.DeletionTimestamp)
+
+	_, err = repo.DeleteNamespace(ctx, "test-tenant", "ns-1", at)
+	assert.ErrorIs(t, err, repositories.ErrNamespaceDeleted)
+
+	result, err := repo.GetAllNamespaces(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Namespace{*ns2}, result)
+
+	result, err = repo.GetAllNamespaces(ctx, "test-tenant", repositories.IncludeDeleted(true))
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	counts, err := repo.CountNamespaces(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"test-tenant": 1}, counts)
+
+	restored, err := repo.RestoreNamespace(ctx, "test-tenant", "ns-1")
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletionTimestamp)
+
+	_, err = repo.RestoreNamespace(ctx, "test-tenant", "ns-1")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotDeleted)
+
+	for _, name := range []string{"ns-1", "ns-2"} {
+		_, err = repo.DeleteNamespace(ctx, "test-tenant", name, at)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.PurgeNamespace(ctx, "test-tenant", name))
+	}
+
+	_, err = repo.GetNamespace(ctx, "test-tenant", "ns-1")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = repo.GetAllNamespaces(ctx, "test-tenant", repositories.IncludeDeleted(true))
+	assert.ErrorIs(t, err, repositories.ErrNoTenantNamespaces)
+}
+
+func TestNamespaceRepository_PurgeNamespace_FinalizersPending(t *testing.T) {
+	repo := repositories.NewNamespaceRepository()
+	ctx := context.Background()
+
+	namespace := &domain.Namespace{Name: "test-namespace", Finalizers: []string{"example.com/cleanup"}}
+	assert.NoError(t, repo.CreateNamespace(ctx, "test-tenant", namespace))
+	_, err := repo.DeleteNamespace(ctx, "test-tenant", "test-namespace", time.Now())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, repo.PurgeNamespace(ctx, "test-tenant", "test-namespace"), repositories.ErrFinalizersPending)
+
+	stored, err := repo.GetNamespace(ctx, "test-tenant", "test-namespace")
+	assert.NoError(t, err)
+	stored.Finalizers = nil
+	assert.NoError(t, repo.UpdateNamespace(ctx, "test-tenant", stored))
+	assert.NoError(t, repo.PurgeNamespace(ctx, "test-tenant", "test-namespace"))
+
+	err = repo.UpdateNamespace(ctx, "test-tenant", stored)
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+}
+
+func TestNamespaceRepository_DiscardNamespace(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewNamespaceRepository()
+	assert.NoError(t, repo.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Finalizers: []string{"naas.io/cluster"}}))
+
+	assert.ErrorIs(t, repo.DiscardNamespace(ctx, "acme", "missing"), repositories.ErrNamespaceNotFound)
+	// Live namespaces with finalizers are discarded all the same
+	assert.NoError(t, repo.DiscardNamespace(ctx, "acme", "team-a"))
+	_, err := repo.GetNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+}
+
+func TestNamespaceRepository_TransferNamespace(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewNamespaceRepository()
+
+	assert.NoError(t, repo.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+	assert.NoError(t, repo.CreateNamespace(ctx, "globex", &domain.Namespace{Name: "team-b"}))
+
+	err := repo.TransferNamespace(ctx, "acme", "globex", &domain.Namespace{Name: "missing"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	assert.NoError(t, repo.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b"}))
+	err = repo.TransferNamespace(ctx, "acme", "globex", &domain.Namespace{Name: "team-b"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+
+	assert.NoError(t, repo.TransferNamespace(ctx, "acme", "globex", &domain.Namespace{Name: "team-a"}))
+	_, err = repo.GetNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	namespace, err := repo.GetNamespace(ctx, "globex", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", namespace.Name)
+}
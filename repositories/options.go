@@ -0,0 +1,24 @@
+package repositories
+
+// ListOption changes which records the list methods return.
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	includeDeleted bool
+}
+
+// IncludeDeleted makes lists contain soft-deleted records, which are hidden
+// by default.
+func IncludeDeleted(include bool) ListOption {
+	return func(o *listOptions) {
+		o.includeDeleted = include
+	}
+}
+
+func newListOptions(opts []ListOption) listOptions {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
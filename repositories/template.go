@@ -0,0 +1,122 @@
+// repositories/template.go
+
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	. "naas/domain"
+	"naas/logging"
+	"naas/tracing"
+)
+
+// TemplateRepository stores templates by scope: a tenant ID, or "" for
+// global templates.
+type TemplateRepository struct {
+	mtx       sync.RWMutex
+	templates map[string]map[string]Template
+}
+
+func NewTemplateRepository() *TemplateRepository {
+	return &TemplateRepository{templates: make(map[string]map[string]Template)}
+}
+
+func (r *TemplateRepository) CreateTemplate(ctx context.Context, template *Template) (err error) {
+	ctx, span := tracer.Start(ctx, "TemplateRepository.CreateTemplate", spanAttributes(
+		attribute.String("naas.tenant.id", template.TenantID),
+		attribute.String("naas.template.name", template.Name),
+	))
+	defer tracing.End(span, &err)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.templates[template.TenantID][template.Name]; ok {
+		return ErrTemplateExists
+	}
+	if IsDryRun(ctx) {
+		return nil
+	}
+
+	if _, ok := r.templates[template.TenantID]; !ok {
+		r.templates[template.TenantID] = make(map[string]Template)
+	}
+
+	r.templates[template.TenantID][template.Name] = *template
+	logging.FromContext(ctx).DebugContext(ctx, "stored template", "tenant_id", template.TenantID, "template", template.Name)
+	return nil
+}
+
+// GetTemplate returns a template of the given scope.
+func (r *TemplateRepository) GetTemplate(ctx context.Context, tenantID, name string) (_ *Template, err error) {
+	_, span := tracer.Start(ctx, "TemplateRepository.GetTemplate", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.template.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if template, ok := r.templates[tenantID][name]; ok {
+		return &template, nil
+	}
+	return nil, ErrTemplateNotFound
+}
+
+// ListTemplates returns the templates of the given scope sorted by name.
+func (r *TemplateRepository) ListTemplates(ctx context.Context, tenantID string) ([]Template, error) {
+	_, span := tracer.Start(ctx, "TemplateRepository.ListTemplates", spanAttributes(attribute.String("naas.tenant.id", tenantID)))
+	defer span.End()
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	templates := make([]Template, 0, len(r.templates[tenantID]))
+	for _, template := range r.templates[tenantID] {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (r *TemplateRepository) DeleteTemplate(ctx context.Context, tenantID, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "TemplateRepository.DeleteTemplate", spanAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.template.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.templates[tenantID][name]; !ok {
+		return ErrTemplateNotFound
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	delete(r.templates[tenantID], name)
+	logging.FromContext(ctx).DebugContext(ctx, "deleted template", "tenant_id", tenantID, "template", name)
+	return nil
+}
@@ -0,0 +1,43 @@
+// repositories/template_test.go
+
+package repositories_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+	"naas/repositories"
+)
+
+func TestTemplateRepository(t *testing.T) {
+	repo := repositories.NewTemplateRepository()
+	ctx := context.Background()
+
+	assert.NoError(t, repo.CreateTemplate(ctx, &domain.Template{Name: "web"}))
+	assert.NoError(t, repo.CreateTemplate(ctx, &domain.Template{Name: "batch"}))
+	// The same name may exist in another scope
+	assert.NoError(t, repo.CreateTemplate(ctx, &domain.Template{Name: "web", TenantID: "acme"}))
+
+	err := repo.CreateTemplate(ctx, &domain.Template{Name: "web"})
+	assert.ErrorIs(t, err, repositories.ErrTemplateExists)
+
+	template, err := repo.GetTemplate(ctx, "acme", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", template.TenantID)
+
+	_, err = repo.GetTemplate(ctx, "acme", "batch")
+	assert.ErrorIs(t, err, repositories.ErrTemplateNotFound)
+
+	templates, err := repo.ListTemplates(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Template{{Name: "batch"}, {Name: "web"}}, templates)
+
+	assert.NoError(t, repo.DeleteTemplate(ctx, "", "web"))
+	assert.ErrorIs(t, repo.DeleteTemplate(ctx, "", "web"), repositories.ErrTemplateNotFound)
+
+	templates, err = repo.ListTemplates(ctx, "other")
+	assert.NoError(t, err)
+	assert.Empty(t, templates)
+}
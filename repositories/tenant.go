@@ -1,10 +1,14 @@
 package repositories
 
 import (
-	"errors"
+	"context"
 	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"naas/domain"
+	"naas/logging"
+	"naas/tracing"
 )
 
 type TenantRepository struct {
@@ -16,33 +20,202 @@ func NewTenantRepository() *TenantRepository {
 	return &TenantRepository{tenants: make(map[string]domain.Tenant)}
 }
 
-func (r *TenantRepository) CreateTenant(tenant *domain.Tenant) error {
-	r.mtx.Lock()
-	defer r.mtx.Unlock()
+func (r *TenantRepository) CreateTenant(ctx context.Context, tenant *domain.Tenant) (err error) {
+	ctx, span := tracer.Start(ctx, "TenantRepository.CreateTenant", spanAttributes(attribute.String("naas.tenant.id", tenant.ID)))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	if _, ok := r.tenants[tenant.ID]; ok {
-		return errors.New("tenant already exists")
+		return ErrTenantExists
 	}
 
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, tenant.ID)
+	tenant.ResourceVersion = nextResourceVersion()
 	r.tenants[tenant.ID] = *tenant
+	logging.FromContext(ctx).DebugContext(ctx, "stored tenant", "tenant_id", tenant.ID)
 	return nil
 }
 
-func (r *TenantRepository) GetTenant(id string) (*domain.Tenant, error) {
-	r.mtx.RLock()
-	defer r.mtx.RUnlock()
+func (r *TenantRepository) GetTenant(ctx context.Context, id string) (_ *domain.Tenant, err error) {
+	_, span := tracer.Start(ctx, "TenantRepository.GetTenant", spanAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	if tenant, ok := r.tenants[id]; ok {
 		return &tenant, nil
 	}
 
-	return nil, errors.New("tenant not found")
+	return nil, ErrTenantNotFound
 }
 
-func (r *TenantRepository) ListTenants() ([]domain.Tenant, error) {
+// ListTenants returns all tenants. Deleted tenants are left out unless
+// IncludeDeleted is given.
+func (r *TenantRepository) ListTenants(ctx context.Context, opts ...ListOption) ([]domain.Tenant, error) {
+	_, span := tracer.Start(ctx, "TenantRepository.ListTenants", spanAttributes())
+	defer span.End()
+
+	defer rlock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	o := newListOptions(opts)
 	tenants := make([]domain.Tenant, 0, len(r.tenants))
 	for _, tenant := range r.tenants {
+		if tenant.Deleted() && !o.includeDeleted {
+			continue
+		}
 		tenants = append(tenants, tenant)
 	}
 	return tenants, nil
 }
+
+func (r *TenantRepository) UpdateTenant(ctx context.Context, tenant *domain.Tenant) (err error) {
+	ctx, span := tracer.Start(ctx, "TenantRepository.UpdateTenant", spanAttributes(attribute.String("naas.tenant.id", tenant.ID)))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stored, ok := r.tenants[tenant.ID]
+	if !ok {
+		return ErrTenantNotFound
+	}
+	if tenant.ResourceVersion != "" && tenant.ResourceVersion != stored.ResourceVersion {
+		return ErrConflict
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, tenant.ID)
+	tenant.ResourceVersion = nextResourceVersion()
+	r.tenants[tenant.ID] = *tenant
+	logging.FromContext(ctx).DebugContext(ctx, "updated tenant", "tenant_id", tenant.ID)
+	return nil
+}
+
+// DeleteTenant marks a tenant as deleted at the given time. The record is
+// kept until PurgeTenant removes it.
+func (r *TenantRepository) DeleteTenant(ctx context.Context, id string, at time.Time) (_ *domain.Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantRepository.DeleteTenant", spanAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	if tenant.Deleted() {
+		return nil, ErrTenantDeleted
+	}
+
+	at = at.UTC()
+	tenant.DeletionTimestamp = &at
+	if IsDryRun(ctx) {
+		return &tenant, nil
+	}
+	r.journal(ctx, id)
+	tenant.ResourceVersion = nextResourceVersion()
+	r.tenants[id] = tenant
+	logging.FromContext(ctx).DebugContext(ctx, "marked tenant deleted", "tenant_id", id)
+	return &tenant, nil
+}
+
+// RestoreTenant clears the deletion mark of a deleted tenant.
+func (r *TenantRepository) RestoreTenant(ctx context.Context, id string) (_ *domain.Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantRepository.RestoreTenant", spanAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	if !tenant.Deleted() {
+		return nil, ErrTenantNotDeleted
+	}
+
+	tenant.DeletionTimestamp = nil
+	if IsDryRun(ctx) {
+		return &tenant, nil
+	}
+	r.journal(ctx, id)
+	tenant.ResourceVersion = nextResourceVersion()
+	r.tenants[id] = tenant
+	logging.FromContext(ctx).DebugContext(ctx, "restored tenant", "tenant_id", id)
+	return &tenant, nil
+}
+
+// PurgeTenant removes a deleted tenant for good. Its finalizers must have
+// been removed first.
+func (r *TenantRepository) PurgeTenant(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "TenantRepository.PurgeTenant", spanAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	defer lock(ctx, &r.mtx)()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return ErrTenantNotFound
+	}
+	if !tenant.Deleted() {
+		return ErrTenantNotDeleted
+	}
+	if len(tenant.Finalizers) > 0 {
+		return ErrFinalizersPending
+	}
+
+	if IsDryRun(ctx) {
+		return nil
+	}
+	r.journal(ctx, id)
+	delete(r.tenants, id)
+	logging.FromContext(ctx).DebugContext(ctx, "purged tenant", "tenant_id", id)
+	return nil
+}
+
+// journal records the tenant as it is in the transaction of ctx, if that
+// transaction holds the repository, so a rollback can put it back. It must
+// be called with the lock held, before the tenant is changed.
+func (r *TenantRepository) journal(ctx context.Context, id string) {
+	previous, existed := r.tenants[id]
+	journal(ctx, &r.mtx, func() {
+		if existed {
+			r.tenants[id] = previous
+		} else {
+			delete(r.tenants, id)
+		}
+	})
+}
@@ -3,7 +3,9 @@
 package repositories_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"naas/domain"
@@ -18,10 +20,10 @@ func TestTenantRepository_CreateTenant(t *testing.T) {
 		Name: "Test Tenant",
 	}
 
-	err := repo.CreateTenant(tenant)
+	err := repo.CreateTenant(context.Background(), tenant)
 	assert.NoError(t, err)
 
-	err = repo.CreateTenant(tenant)
+	err = repo.CreateTenant(context.Background(), tenant)
 	assert.EqualError(t, err, "tenant already exists")
 }
 
@@ -32,14 +34,118 @@ func TestTenantRepository_GetTenant(t *testing.T) {
 		ID:   "test-tenant",
 		Name: "Test Tenant",
 	}
-	err := repo.CreateTenant(tenant)
+	err := repo.CreateTenant(context.Background(), tenant)
 	assert.NoError(t, err)
 
-	result, err := repo.GetTenant("test-tenant")
+	result, err := repo.GetTenant(context.Background(), "test-tenant")
 	assert.NoError(t, err)
 	assert.Equal(t, tenant, result)
 
-	result, err = repo.GetTenant("non-existent-tenant")
+	result, err = repo.GetTenant(context.Background(), "non-existent-tenant")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "tenant not found")
 }
+
+func TestTenantRepository_CancelledContext(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := repo.CreateTenant(ctx, &domain.Tenant{ID: "test-tenant"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = repo.GetTenant(context.Background(), "test-tenant")
+	assert.EqualError(t, err, "tenant not found")
+}
+
+func TestTenantRepository_UpdateTenant(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+
+	tenant := &domain.Tenant{ID: "test-tenant", Name: "Test Tenant", Status: domain.TenantActive}
+	err := repo.UpdateTenant(context.Background(), tenant)
+	assert.EqualError(t, err, "tenant not found")
+
+	assert.NoError(t, repo.CreateTenant(context.Background(), tenant))
+
+	tenant.Status = domain.TenantSuspended
+	assert.NoError(t, repo.UpdateTenant(context.Background(), tenant))
+
+	result, err := repo.GetTenant(context.Background(), "test-tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TenantSuspended, result.Status)
+}
+
+func TestTenantRepository_UpdateTenant_Conflict(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{ID: "test-tenant"}
+	assert.NoError(t, repo.CreateTenant(ctx, tenant))
+	assert.NotEmpty(t, tenant.ResourceVersion)
+
+	first, err := repo.GetTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+	second, err := repo.GetTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+
+	first.Name = "First"
+	assert.NoError(t, repo.UpdateTenant(ctx, first))
+	assert.NotEqual(t, tenant.ResourceVersion, first.ResourceVersion)
+
+	// The second writer read the tenant before the first one wrote it
+	second.Name = "Second"
+	assert.ErrorIs(t, repo.UpdateTenant(ctx, second), repositories.ErrConflict)
+
+	// Updates without a version always apply
+	assert.NoError(t, repo.UpdateTenant(ctx, &domain.Tenant{ID: "test-tenant", Name: "Third"}))
+	result, err := repo.GetTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, "Third", result.Name)
+}
+
+func TestTenantRepository_SoftDelete(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	ctx := context.Background()
+
+	tenantB := &domain.Tenant{ID: "tenant-b"}
+	assert.NoError(t, repo.CreateTenant(ctx, &domain.Tenant{ID: "tenant-a"}))
+	assert.NoError(t, repo.CreateTenant(ctx, tenantB))
+
+	_, err := repo.DeleteTenant(ctx, "missing", time.Now())
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted, err := repo.DeleteTenant(ctx, "tenant-a", at)
+	assert.NoError(t, err)
+	assert.Equal(t, at, *deleted.DeletionTimestamp)
+
+	_, err = repo.DeleteTenant(ctx, "tenant-a", at)
+	assert.ErrorIs(t, err, repositories.ErrTenantDeleted)
+
+	tenants, err := repo.ListTenants(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Tenant{*tenantB}, tenants)
+
+	tenants, err = repo.ListTenants(ctx, repositories.IncludeDeleted(true))
+	assert.NoError(t, err)
+	assert.Len(t, tenants, 2)
+
+	// Deleted tenants can still be read, e.g. to restore them.
+	result, err := repo.GetTenant(ctx, "tenant-a")
+	assert.NoError(t, err)
+	assert.True(t, result.Deleted())
+
+	restored, err := repo.RestoreTenant(ctx, "tenant-a")
+	assert.NoError(t, err)
+	assert.False(t, restored.Deleted())
+
+	assert.ErrorIs(t, repo.PurgeTenant(ctx, "tenant-a"), repositories.ErrTenantNotDeleted)
+
+	_, err = repo.DeleteTenant(ctx, "tenant-a", at)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.PurgeTenant(ctx, "tenant-a"))
+
+	_, err = repo.GetTenant(ctx, "tenant-a")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("naas/repositories")
+
+func spanAttributes(attrs ...attribute.KeyValue) trace.SpanStartOption {
+	return trace.WithAttributes(append(attrs, semconv.DBSystemKey.String("memory"))...)
+}
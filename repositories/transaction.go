@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+)
+
+type transactionKey struct{}
+
+// Transaction applies changes to tenants and namespaces as a unit. It holds
+// the write locks of its repositories from Begin until it is committed or
+// rolled back, so other requests neither see its changes before it is
+// committed nor change the records under it. Changes made with a context
+// carrying the transaction skip the locks it holds and are journaled, and
+// Rollback puts back the records they touched.
+type Transaction struct {
+	// parent is the transaction this one is nested in. Nested transactions
+	// share their parent's locks.
+	parent *Transaction
+	locks  []*sync.RWMutex
+
+	mtx  sync.Mutex
+	undo []func()
+	done bool
+}
+
+// Begin starts a transaction over the tenant and namespace repositories,
+// either of which may be nil, and returns a context carrying it. Begin
+// blocks until it holds their write locks. Begun with a context carrying an
+// open transaction, it starts a nested one: committing it hands its changes
+// to the outer transaction, and rolling it back only undoes its own.
+func Begin(ctx context.Context, tenants *TenantRepository, namespaces *NamespaceRepository) (context.Context, *Transaction) {
+	tx := &Transaction{}
+	if parent := openTransaction(ctx); parent != nil {
+		tx.parent = parent
+		return context.WithValue(ctx, transactionKey{}, tx), tx
+	}
+
+	// The locks are always taken in the same order, so transactions can't
+	// deadlock each other.
+	if tenants != nil {
+		tx.locks = append(tx.locks, &tenants.mtx)
+	}
+	if namespaces != nil {
+		tx.locks = append(tx.locks, &namespaces.mtx)
+	}
+	for _, l := range tx.locks {
+		l.Lock()
+	}
+	return context.WithValue(ctx, transactionKey{}, tx), tx
+}
+
+// Commit keeps the changes made in the transaction and releases its locks.
+// Changes made with its context afterwards are no longer part of it.
+func (t *Transaction) Commit() {
+	undo, ok := t.finish()
+	if ok && t.parent != nil {
+		t.parent.mtx.Lock()
+		t.parent.undo = append(t.parent.undo, undo...)
+		t.parent.mtx.Unlock()
+	}
+	t.release()
+}
+
+// Rollback undoes the changes made in the transaction, latest first, and
+// releases its locks. It is a no-op once the transaction is committed or
+// rolled back, so it can be deferred.
+func (t *Transaction) Rollback() {
+	undo, ok := t.finish()
+	if !ok {
+		return
+	}
+	// The locks are still held, so nothing else sees the records between
+	// the undo steps.
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+	t.release()
+}
+
+// finish marks the transaction done and returns its undo steps, or false if
+// it already was.
+func (t *Transaction) finish() ([]func(), bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.done {
+		return nil, false
+	}
+	undo := t.undo
+	t.undo = nil
+	t.done = true
+	return undo, true
+}
+
+func (t *Transaction) release() {
+	for i := len(t.locks) - 1; i >= 0; i-- {
+		t.locks[i].Unlock()
+	}
+	t.locks = nil
+}
+
+// holds reports whether the transaction, or the one it is nested in, holds
+// mtx.
+func (t *Transaction) holds(mtx *sync.RWMutex) bool {
+	for ; t != nil; t = t.parent {
+		for _, l := range t.locks {
+			if l == mtx {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *Transaction) isDone() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.done
+}
+
+// openTransaction returns the transaction of ctx, unless there is none or it
+// is done.
+func openTransaction(ctx context.Context) *Transaction {
+	t, _ := ctx.Value(transactionKey{}).(*Transaction)
+	if t == nil || t.isDone() {
+		return nil
+	}
+	return t
+}
+
+// lock takes the write lock of mtx, unless the transaction of ctx holds it,
+// and returns the function that releases it.
+func lock(ctx context.Context, mtx *sync.RWMutex) (unlock func()) {
+	if t := openTransaction(ctx); t != nil && t.holds(mtx) {
+		return func() {}
+	}
+	mtx.Lock()
+	return mtx.Unlock
+}
+
+// rlock is lock for reads.
+func rlock(ctx context.Context, mtx *sync.RWMutex) (unlock func()) {
+	if t := openTransaction(ctx); t != nil && t.holds(mtx) {
+		return func() {}
+	}
+	mtx.RLock()
+	return mtx.RUnlock
+}
+
+// journal records how to undo a change made with ctx to the records guarded
+// by mtx, if ctx carries an open transaction that holds mtx. The undo step
+// runs with mtx held.
+func journal(ctx context.Context, mtx *sync.RWMutex, undo func()) {
+	t := openTransaction(ctx)
+	if t == nil || !t.holds(mtx) {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.undo = append(t.undo, undo)
+}
@@ -0,0 +1,97 @@
+package repositories_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"naas/domain"
+	"naas/repositories"
+)
+
+func TestTransaction_Rollback(t *testing.T) {
+	ctx := context.Background()
+	tenants := repositories.NewTenantRepository()
+	namespaces := repositories.NewNamespaceRepository()
+
+	assert.NoError(t, tenants.CreateTenant(ctx, &domain.Tenant{ID: "acme", Name: "Acme"}))
+	assert.NoError(t, namespaces.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+
+	txCtx, tx := repositories.Begin(ctx, tenants, namespaces)
+	assert.NoError(t, tenants.CreateTenant(txCtx, &domain.Tenant{ID: "globex"}))
+	assert.NoError(t, tenants.UpdateTenant(txCtx, &domain.Tenant{ID: "acme", Name: "Acme Corp"}))
+	assert.NoError(t, tenants.UpdateTenant(txCtx, &domain.Tenant{ID: "acme", Name: "Acme Inc"}))
+	assert.NoError(t, namespaces.CreateNamespace(txCtx, "globex", &domain.Namespace{Name: "team-b"}))
+	_, err := namespaces.DeleteNamespace(txCtx, "acme", "team-a", time.Now())
+	assert.NoError(t, err)
+
+	// A nested transaction rolled back only undoes its own changes
+	nestedCtx, nested := repositories.Begin(txCtx, tenants, namespaces)
+	assert.NoError(t, namespaces.CreateNamespace(nestedCtx, "acme", &domain.Namespace{Name: "team-c"}))
+	nested.Rollback()
+	_, err = namespaces.GetNamespace(txCtx, "acme", "team-c")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = tenants.GetTenant(txCtx, "globex")
+	assert.NoError(t, err)
+
+	tx.Rollback()
+
+	_, err = tenants.GetTenant(ctx, "globex")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+	tenant, err := tenants.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme", tenant.Name)
+	_, err = namespaces.GetAllNamespaces(ctx, "globex")
+	assert.ErrorIs(t, err, repositories.ErrNoTenantNamespaces)
+	namespace, err := namespaces.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.False(t, namespace.Deleted())
+
+	// Nothing is journaled once the transaction is done
+	assert.NoError(t, tenants.CreateTenant(txCtx, &domain.Tenant{ID: "initech"}))
+	tx.Rollback()
+	_, err = tenants.GetTenant(ctx, "initech")
+	assert.NoError(t, err)
+}
+
+func TestTransaction_Commit(t *testing.T) {
+	ctx := context.Background()
+	tenants := repositories.NewTenantRepository()
+
+	txCtx, tx := repositories.Begin(ctx, tenants, nil)
+	// A nested transaction committed hands its changes to the outer one
+	nestedCtx, nested := repositories.Begin(txCtx, tenants, nil)
+	assert.NoError(t, tenants.CreateTenant(nestedCtx, &domain.Tenant{ID: "acme"}))
+	nested.Commit()
+	tx.Commit()
+	tx.Rollback()
+
+	_, err := tenants.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+}
+
+func TestTransaction_Isolation(t *testing.T) {
+	ctx := context.Background()
+	tenants := repositories.NewTenantRepository()
+
+	txCtx, tx := repositories.Begin(ctx, tenants, nil)
+	assert.NoError(t, tenants.CreateTenant(txCtx, &domain.Tenant{ID: "acme", Name: "Acme"}))
+
+	// Other changes wait for the transaction to finish
+	updated := make(chan error, 1)
+	go func() {
+		updated <- tenants.UpdateTenant(ctx, &domain.Tenant{ID: "acme", Name: "Acme Corp"})
+	}()
+	select {
+	case <-updated:
+		t.Fatal("update ran inside the transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tx.Commit()
+	assert.NoError(t, <-updated)
+	tenant, err := tenants.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Corp", tenant.Name)
+}
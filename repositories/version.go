@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// resourceVersion is shared by every repository, so a version is never
+// handed out twice, not even after a rollback puts back an older record.
+var resourceVersion atomic.Uint64
+
+func nextResourceVersion() string {
+	return strconv.FormatUint(resourceVersion.Add(1), 10)
+}
@@ -0,0 +1,180 @@
+// server/server.go
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"naas/config"
+)
+
+// Server runs the HTTP server and its background workers until its context
+// is cancelled. It then keeps serving for the shutdown delay, drains
+// in-flight requests, waits for the workers to stop and runs the registered
+// shutdown hooks.
+type Server struct {
+	http            *http.Server
+	cancelRequests  context.CancelFunc
+	tls             config.TLS
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+
+	mtx     sync.Mutex
+	hooks   []hook
+	workers []worker
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+func New(cfg config.Server, handler http.Handler) *Server {
+	// Request contexts derive from baseCtx so that requests still running
+	// when the drain deadline passes are cancelled rather than cut off.
+	baseCtx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		cancelRequests: cancel,
+		http: &http.Server{
+			BaseContext:       func(net.Listener) context.Context { return baseCtx },
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       time.Duration(cfg.ReadTimeout),
+			ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+			WriteTimeout:      time.Duration(cfg.WriteTimeout),
+			IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		},
+		tls:             cfg.TLS,
+		shutdownDelay:   time.Duration(cfg.ShutdownDelay),
+		shutdownTimeout: time.Duration(cfg.ShutdownTimeout),
+	}
+}
+
+// OnShutdown registers fn to run once the listener is closed and in-flight
+// requests are drained, e.g. to flush pending writes. Hooks run in
+// registration order and share the remaining shutdown deadline.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// Go registers run as a background worker. It is started by Serve with
+// Serve's context and must return once that context is done; Serve waits
+// for it, within the shutdown deadline, before running the shutdown hooks.
+func (s *Server) Go(name string, run func(ctx context.Context)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.workers = append(s.workers, worker{name: name, run: run})
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.mtx.Lock()
+	workers := s.workers
+	s.mtx.Unlock()
+	// Workers are stopped with the server, even when it fails on its own.
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	stopped := make([]chan struct{}, len(workers))
+	for i, w := range workers {
+		stopped[i] = make(chan struct{})
+		go func(w worker, stopped chan struct{}) {
+			defer close(stopped)
+			w.run(workerCtx)
+		}(w, stopped[i])
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.tls.Enabled() {
+			serveErr <- s.http.ServeTLS(ln, s.tls.CertFile, s.tls.KeyFile)
+		} else {
+			serveErr <- s.http.Serve(ln)
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		// Readiness checks watching ctx fail from now on. New requests are
+		// still served until the probes have seen it.
+		if s.shutdownDelay > 0 {
+			slog.Info("shutting down, waiting for readiness probes", "delay", s.shutdownDelay)
+			select {
+			case err = <-serveErr:
+			case <-time.After(s.shutdownDelay):
+			}
+		}
+		if err == nil {
+			slog.Info("shutting down, draining connections", "timeout", s.shutdownTimeout)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err == nil {
+		if shutdownErr := s.http.Shutdown(shutdownCtx); shutdownErr != nil {
+			slog.Warn("graceful shutdown incomplete, closing remaining connections", "error", shutdownErr)
+			s.cancelRequests()
+			s.http.Close()
+		}
+		err = <-serveErr
+	}
+	s.cancelRequests()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	var problems []string
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	stopWorkers()
+	for i, w := range workers {
+		select {
+		case <-stopped[i]:
+		case <-shutdownCtx.Done():
+			problems = append(problems, fmt.Sprintf("%s: %v", w.name, shutdownCtx.Err()))
+		}
+	}
+
+	s.mtx.Lock()
+	hooks := s.hooks
+	s.mtx.Unlock()
+	for _, h := range hooks {
+		if hookErr := h.fn(shutdownCtx); hookErr != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", h.name, hookErr))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"naas/config"
+	"naas/server"
+)
+
+func TestServer_DrainsInFlightRequestsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	cfg := config.Default().Server
+	cfg.ShutdownDelay = 0
+	srv := server.New(cfg, handler)
+
+	var hookRan bool
+	srv.OnShutdown("flush", func(ctx context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	res := <-responses
+	assert.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	assert.NoError(t, <-done)
+	assert.True(t, hookRan)
+}
+
+func TestServer_ServesDuringShutdownDelay(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.ShutdownDelay = config.Duration(200 * time.Millisecond)
+	srv := server.New(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	cancel()
+	stopping := time.Now()
+
+	// Requests arriving after the signal are served until the delay is over
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+	}
+	assert.NoError(t, <-done)
+	assert.GreaterOrEqual(t, time.Since(stopping), 200*time.Millisecond)
+}
+
+func TestServer_ReportsHookErrors(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.ShutdownDelay = 0
+	srv := server.New(cfg, http.NotFoundHandler())
+	srv.OnShutdown("storage", func(ctx context.Context) error {
+		return errors.New("flush failed")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = srv.Serve(ctx, ln)
+	assert.EqualError(t, err, "storage: flush failed")
+}
+
+func TestServer_WaitsForWorkers(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.ShutdownDelay = 0
+	cfg.ShutdownTimeout = config.Duration(100 * time.Millisecond)
+	srv := server.New(cfg, http.NotFoundHandler())
+
+	started := make(chan struct{})
+	var finished bool
+	srv.Go("resync", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		// Work under way finishes after the context is done
+		time.Sleep(20 * time.Millisecond)
+		finished = true
+	})
+	srv.Go("stuck", func(ctx context.Context) {
+		select {}
+	})
+	var finishedBeforeHook bool
+	srv.OnShutdown("flush", func(ctx context.Context) error {
+		finishedBeforeHook = finished
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	<-started
+	cancel()
+
+	assert.EqualError(t, <-done, "stuck: context deadline exceeded")
+	assert.True(t, finishedBeforeHook)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	. "naas/domain"
+	"naas/logging"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+// AdoptNamespaces brings the namespaces of a cluster that naas doesn't
+// manage into the tenants the adoption's rules map them to. Adopted
+// namespaces are recorded with an empty spec and labelled in place, so
+// their contents stay untouched; from then on they are reconciled like any
+// other namespace, with the isolation the adoption asks for. Namespaces that
+// can't be adopted are reported as skipped with the reason, and a dry run,
+// asked for by the adoption or the request, reports without adopting.
+func (s *NamespaceService) AdoptNamespaces(ctx context.Context, adoption *Adoption) (_ *AdoptionReport, err error) {
+	dryRun := adoption.DryRun || IsDryRun(ctx)
+	ctx, span := tracer.Start(ctx, "NamespaceService.AdoptNamespaces", trace.WithAttributes(
+		attribute.String("naas.cluster.name", adoption.Cluster),
+		attribute.Bool("naas.dry_run", dryRun),
+	))
+	defer tracing.End(span, &err)
+
+	if err := adoption.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAdoption, err)
+	}
+	if adoption.Cluster != "" {
+		if s.clusters == nil {
+			return nil, fmt.Errorf("%w: %w: %s", ErrInvalidAdoption, ErrClusterNotFound, adoption.Cluster)
+		}
+		if _, err := s.clusters.GetCluster(ctx, adoption.Cluster); err != nil {
+			if errors.Is(err, ErrClusterNotFound) {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidAdoption, err)
+			}
+			return nil, err
+		}
+	}
+
+	discovered, ok, err := s.reconciler.DiscoverNamespaces(ctx, adoption.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotConnected
+	}
+	sort.Slice(discovered, func(i, j int) bool { return discovered[i].Name < discovered[j].Name })
+
+	// Records of namespaces that lost their labels in the cluster would
+	// otherwise be adopted a second time.
+	all, err := s.repo.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return nil, err
+	}
+	recorded := make(map[string]string)
+	for tenantID, namespaces := range all {
+		for _, ns := range namespaces {
+			if ns.Cluster == adoption.Cluster {
+				recorded[ns.Name] = tenantID
+			}
+		}
+	}
+
+	logger := logging.FromContext(ctx)
+	report := &AdoptionReport{
+		Cluster: adoption.Cluster,
+		DryRun:  dryRun,
+		Adopted: []AdoptedNamespace{},
+		Skipped: []SkippedNamespace{},
+	}
+	skip := func(name, reason string) {
+		report.Skipped = append(report.Skipped, SkippedNamespace{Namespace: name, Reason: reason})
+	}
+	for _, ns := range discovered {
+		if SystemNamespace(ns.Name) {
+			skip(ns.Name, "system namespace")
+			continue
+		}
+		if err := ValidateNamespaceName(ns.Name); err != nil {
+			skip(ns.Name, err.Error())
+			continue
+		}
+		if ns.ManagedBy != "" {
+			skip(ns.Name, "managed by "+ns.ManagedBy)
+			continue
+		}
+		if ns.Terminating {
+			skip(ns.Name, "terminating")
+			continue
+		}
+		tenantID, rule, ok := adoption.TenantFor(ns.Labels)
+		if !ok {
+			skip(ns.Name, "no rule matches")
+			continue
+		}
+		if owner, ok := recorded[ns.Name]; ok {
+			skip(ns.Name, "already recorded for tenant "+owner)
+			continue
+		}
+		if err := s.checkWritable(ctx, tenantID); err != nil {
+			skip(ns.Name, fmt.Sprintf("tenant %s: %v", tenantID, err))
+			continue
+		}
+
+		if !dryRun {
+			if err := s.adopt(ctx, tenantID, adoption, ns.Name); err != nil {
+				skip(ns.Name, err.Error())
+				continue
+			}
+			logger.InfoContext(ctx, "namespace adopted", "tenant_id", tenantID, "namespace", ns.Name, "cluster", adoption.Cluster)
+		}
+		report.Adopted = append(report.Adopted, AdoptedNamespace{Namespace: ns.Name, TenantID: tenantID, Rule: rule})
+	}
+
+	return report, nil
+}
+
+// adopt records a namespace and then labels it in the cluster. The record
+// is discarded when labelling fails, so naas never manages a namespace it
+// doesn't own, nor labels one it has no record of. The record is only
+// created if the cluster has room for it, as a new namespace would be.
+func (s *NamespaceService) adopt(ctx context.Context, tenantID string, adoption *Adoption, name string) error {
+	isolation := adoption.Isolation
+	if isolation == nil {
+		isolation = &NetworkIsolation{Disabled: true}
+	}
+	namespace := &Namespace{
+		Name:       name,
+		Cluster:    adoption.Cluster,
+		Isolation:  isolation,
+		Finalizers: s.finalizers.namespaceNames(),
+	}
+
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+	if namespace.Cluster != "" {
+		if err := s.place(txCtx, tenantID, namespace); err != nil {
+			return err
+		}
+	}
+	if err := s.checkNameFree(txCtx, namespace, tenantID); err != nil {
+		return err
+	}
+	if err := s.repo.CreateNamespace(txCtx, tenantID, namespace); err != nil {
+		return err
+	}
+	tx.Commit()
+
+	// The cluster is called without holding the repositories' locks.
+	if err := s.reconciler.AdoptNamespace(ctx, tenantID, adoption.Cluster, name); err != nil {
+		if err := s.repo.DiscardNamespace(context.WithoutCancel(ctx), tenantID, name); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "discarding the record of a namespace that wasn't adopted", "tenant_id", tenantID, "namespace", name, "error", err)
+		}
+		return err
+	}
+
+	s.reconcile(ctx, tenantID, namespace)
+	return nil
+}
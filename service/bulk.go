@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+// BulkService applies batches of tenant and namespace changes in
+// repository transactions, going through the same checks as the single
+// changes do.
+type BulkService struct {
+	tenants    *TenantService
+	namespaces *NamespaceService
+	reconciler *reconciler.Reconciler
+}
+
+func NewBulkService(tenants *TenantService, namespaces *NamespaceService, reconciler *reconciler.Reconciler) *BulkService {
+	return &BulkService{tenants: tenants, namespaces: namespaces, reconciler: reconciler}
+}
+
+// Apply applies the operations of bulk in order. In all-or-nothing mode the
+// batch is a single transaction: no other change interleaves with it, and
+// the first failure undoes every operation before it and skips the rest. In
+// best-effort mode each operation is a transaction of its own and only the
+// failed ones are undone. The cluster is reconciled once the changes are
+// committed, so undone operations never reach it. The report gives the
+// outcome of every operation; Apply only fails when the request itself is
+// invalid.
+func (s *BulkService) Apply(ctx context.Context, bulk *Bulk) (_ *BulkReport, err error) {
+	ctx, span := tracer.Start(ctx, "BulkService.Apply", trace.WithAttributes(
+		attribute.String("naas.bulk.mode", string(bulk.Mode)),
+		attribute.Int("naas.bulk.operations", len(bulk.Operations)),
+	))
+	defer tracing.End(span, &err)
+
+	if err := bulk.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBulk, err)
+	}
+	mode := bulk.Mode
+	if mode == "" {
+		mode = BulkAllOrNothing
+	}
+
+	// Copies of the services that leave reconciling to Apply.
+	tenants, namespaces := *s.tenants, *s.namespaces
+	tenants.reconciler, namespaces.reconciler = nil, nil
+
+	report := &BulkReport{Mode: mode, Committed: true, Results: make([]BulkResult, len(bulk.Operations))}
+	batchCtx, batch := ctx, (*Transaction)(nil)
+	if mode == BulkAllOrNothing {
+		batchCtx, batch = Begin(ctx, s.tenants.repo, s.namespaces.repo)
+		defer batch.Rollback()
+	}
+	for i, op := range bulk.Operations {
+		// Nested in the batch in all-or-nothing mode
+		opCtx, tx := Begin(batchCtx, s.tenants.repo, s.namespaces.repo)
+		result := applyOperation(opCtx, &tenants, &namespaces, op)
+		report.Results[i] = result
+		if result.Status == BulkSucceeded {
+			tx.Commit()
+			continue
+		}
+
+		tx.Rollback()
+		if mode == BulkAllOrNothing {
+			batch.Rollback()
+			report.Committed = false
+			for j := range report.Results[:i] {
+				report.Results[j] = BulkResult{Status: BulkRolledBack}
+			}
+			for j := i + 1; j < len(report.Results); j++ {
+				report.Results[j].Status = BulkSkipped
+			}
+			break
+		}
+	}
+	if !report.Committed {
+		logging.FromContext(ctx).InfoContext(ctx, "bulk request rolled back", "operations", len(bulk.Operations))
+		return report, nil
+	}
+	if batch != nil {
+		batch.Commit()
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "bulk request applied", "mode", mode, "operations", len(bulk.Operations))
+
+	s.reconcile(ctx, bulk.Operations, report.Results)
+	return report, nil
+}
+
+func applyOperation(ctx context.Context, tenants *TenantService, namespaces *NamespaceService, op BulkOperation) BulkResult {
+	var (
+		result BulkResult
+		err    error
+	)
+	switch {
+	case op.Tenant != nil && op.Action == BulkCreate:
+		tenant := *op.Tenant
+		err = tenants.CreateTenant(ctx, &tenant)
+		result.Tenant = &tenant
+	case op.Tenant != nil && op.Action == BulkUpdate:
+		result.Tenant, err = tenants.UpdateTenant(ctx, op.Tenant)
+	case op.Tenant != nil && op.Action == BulkDelete:
+		result.Tenant, err = tenants.DeleteTenant(ctx, op.Tenant.ID)
+	case op.Action == BulkCreate:
+		namespace := *op.Namespace
+		err = namespaces.CreateNamespace(ctx, op.TenantID, &namespace)
+		result.Namespace = &namespace
+	case op.Action == BulkUpdate:
+		result.Namespace, err = namespaces.UpdateNamespace(ctx, op.TenantID, op.Namespace)
+	case op.Action == BulkDelete:
+		result.Namespace, err = namespaces.DeleteNamespace(ctx, op.TenantID, op.Namespace.Name)
+	}
+	if err != nil {
+		return BulkResult{Status: BulkFailed, Err: err, Error: err.Error()}
+	}
+	result.Status = BulkSucceeded
+	return result
+}
+
+// reconcile applies the committed operations to the cluster, each tenant
+// and namespace once.
+func (s *BulkService) reconcile(ctx context.Context, operations []BulkOperation, results []BulkResult) {
+	reconciled := make(map[string]bool)
+	for i, op := range operations {
+		if results[i].Status != BulkSucceeded || op.Tenant == nil || reconciled[op.Tenant.ID] {
+			continue
+		}
+		reconciled[op.Tenant.ID] = true
+		_ = s.reconciler.ReconcileTenant(ctx, op.Tenant.ID)
+	}
+
+	subtrees := make(map[[2]string]bool)
+	for i, op := range operations {
+		if results[i].Status != BulkSucceeded || op.Namespace == nil || reconciled[op.TenantID] {
+			continue
+		}
+		key := [2]string{op.TenantID, op.Namespace.Name}
+		if subtrees[key] {
+			continue
+		}
+		subtrees[key] = true
+		// Updates change the spec the namespace's descendants inherit.
+		s.namespaces.reconcileSubtree(ctx, op.TenantID, op.Namespace.Name)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+type ClusterService struct {
+	repo       *ClusterRepository
+	namespaces *NamespaceRepository
+	reconciler *reconciler.Reconciler
+}
+
+func NewClusterService(repo *ClusterRepository, namespaces *NamespaceRepository, reconciler *reconciler.Reconciler) *ClusterService {
+	return &ClusterService{repo: repo, namespaces: namespaces, reconciler: reconciler}
+}
+
+func (s *ClusterService) CreateCluster(ctx context.Context, cluster *Cluster) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterService.CreateCluster", trace.WithAttributes(attribute.String("naas.cluster.name", cluster.Name)))
+	defer tracing.End(span, &err)
+
+	if err := cluster.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCluster, err)
+	}
+	cluster.Allocated = 0
+	if err := s.repo.CreateCluster(ctx, cluster); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "cluster registered", "cluster", cluster.Name, "region", cluster.Region)
+	return nil
+}
+
+func (s *ClusterService) GetCluster(ctx context.Context, name string) (_ *Cluster, err error) {
+	ctx, span := tracer.Start(ctx, "ClusterService.GetCluster", trace.WithAttributes(attribute.String("naas.cluster.name", name)))
+	defer tracing.End(span, &err)
+
+	cluster, err := s.repo.GetCluster(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	allocated, _, err := allocation(ctx, s.namespaces, "")
+	if err != nil {
+		return nil, err
+	}
+	cluster.Allocated = allocated[name]
+	return cluster, nil
+}
+
+func (s *ClusterService) ListClusters(ctx context.Context) (_ []Cluster, err error) {
+	ctx, span := tracer.Start(ctx, "ClusterService.ListClusters")
+	defer tracing.End(span, &err)
+
+	return listClusters(ctx, s.repo, s.namespaces)
+}
+
+// UpdateCluster replaces the registration of a cluster. Namespaces already
+// placed on it stay there even if it no longer has room for them.
+func (s *ClusterService) UpdateCluster(ctx context.Context, cluster *Cluster) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterService.UpdateCluster", trace.WithAttributes(attribute.String("naas.cluster.name", cluster.Name)))
+	defer tracing.End(span, &err)
+
+	if err := cluster.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCluster, err)
+	}
+	cluster.Allocated = 0
+	if err := s.repo.UpdateCluster(ctx, cluster); err != nil {
+		return err
+	}
+	s.reconciler.ForgetCluster(cluster.Name)
+
+	logging.FromContext(ctx).InfoContext(ctx, "cluster updated", "cluster", cluster.Name)
+	return nil
+}
+
+// DeleteCluster removes a cluster from the registry. Clusters with
+// namespaces placed on them, deleted ones included, can't be removed.
+func (s *ClusterService) DeleteCluster(ctx context.Context, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "ClusterService.DeleteCluster", trace.WithAttributes(attribute.String("naas.cluster.name", name)))
+	defer tracing.End(span, &err)
+
+	// Holding the namespaces' write lock keeps namespaces from being placed
+	// on the cluster while it is removed.
+	txCtx, tx := Begin(ctx, nil, s.namespaces)
+	defer tx.Rollback()
+
+	if _, err := s.repo.GetCluster(txCtx, name); err != nil {
+		return err
+	}
+	allocated, _, err := allocation(txCtx, s.namespaces, "")
+	if err != nil {
+		return err
+	}
+	if n := allocated[name]; n > 0 {
+		return fmt.Errorf("%w: %d namespaces", ErrClusterInUse, n)
+	}
+	if err := s.repo.DeleteCluster(txCtx, name); err != nil {
+		return err
+	}
+	tx.Commit()
+	s.reconciler.ForgetCluster(name)
+
+	logging.FromContext(ctx).InfoContext(ctx, "cluster deregistered", "cluster", name)
+	return nil
+}
+
+// listClusters returns the registered clusters with their allocation. A nil
+// repository has no clusters.
+func listClusters(ctx context.Context, repo *ClusterRepository, namespaces *NamespaceRepository) ([]Cluster, error) {
+	if repo == nil {
+		return nil, nil
+	}
+	clusters, err := repo.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocated, _, err := allocation(ctx, namespaces, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusters {
+		clusters[i].Allocated = allocated[clusters[i].Name]
+	}
+	return clusters, nil
+}
+
+// allocation counts the namespaces placed on each cluster, overall and for
+// tenantID. Deleted namespaces count until they are purged, since they still
+// exist in their cluster.
+func allocation(ctx context.Context, namespaces *NamespaceRepository, tenantID string) (all, tenant map[string]int, err error) {
+	byTenant, err := namespaces.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return nil, nil, err
+	}
+	all = make(map[string]int)
+	tenant = make(map[string]int)
+	for id, list := range byTenant {
+		for _, ns := range list {
+			if ns.Cluster == "" {
+				continue
+			}
+			all[ns.Cluster]++
+			if id == tenantID {
+				tenant[ns.Cluster]++
+			}
+		}
+	}
+	return all, tenant, nil
+}
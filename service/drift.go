@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+// DriftScanner compares namespaces with the cluster they are placed on, to
+// notice edits made behind naas's back, and keeps the drift it finds until
+// a later check finds the namespace clean. With remediate set, drifted
+// namespaces are reconciled right away instead of on the next resync.
+type DriftScanner struct {
+	namespaces *NamespaceRepository
+	reconciler *reconciler.Reconciler
+	remediate  bool
+
+	mtx sync.RWMutex
+	// drifted holds the last drift found in each namespace, by
+	// tenant/namespace.
+	drifted map[string]Drift
+}
+
+func NewDriftScanner(namespaces *NamespaceRepository, reconciler *reconciler.Reconciler, remediate bool) *DriftScanner {
+	return &DriftScanner{
+		namespaces: namespaces,
+		reconciler: reconciler,
+		remediate:  remediate,
+		drifted:    make(map[string]Drift),
+	}
+}
+
+// Scan checks every namespace. Namespaces that can't be checked keep their
+// last report.
+func (s *DriftScanner) Scan(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "DriftScanner.Scan")
+	defer tracing.End(span, &err)
+
+	all, err := s.namespaces.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for tenantID, namespaces := range all {
+		for _, ns := range namespaces {
+			seen[driftKey(tenantID, ns.Name)] = true
+			if _, err := s.check(ctx, tenantID, ns.Name, s.remediate); err != nil && !errors.Is(err, ErrNotConnected) {
+				errs = append(errs, fmt.Errorf("namespace %s/%s: %w", tenantID, ns.Name, err))
+			}
+		}
+	}
+
+	// Forget purged namespaces.
+	s.mtx.Lock()
+	for key := range s.drifted {
+		if !seen[key] {
+			delete(s.drifted, key)
+		}
+	}
+	s.mtx.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// CheckNamespace checks one namespace now.
+func (s *DriftScanner) CheckNamespace(ctx context.Context, tenantID, name string) (_ *Drift, err error) {
+	ctx, span := tracer.Start(ctx, "DriftScanner.CheckNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if _, err := s.namespaces.GetNamespace(ctx, tenantID, name); err != nil {
+		return nil, err
+	}
+	return s.check(ctx, tenantID, name, false)
+}
+
+// RemediateNamespace reconciles one namespace and checks it again.
+func (s *DriftScanner) RemediateNamespace(ctx context.Context, tenantID, name string) (_ *Drift, err error) {
+	ctx, span := tracer.Start(ctx, "DriftScanner.RemediateNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if _, err := s.namespaces.GetNamespace(ctx, tenantID, name); err != nil {
+		return nil, err
+	}
+	if err := s.reconciler.ReconcileNamespace(ctx, tenantID, name); err != nil {
+		return nil, err
+	}
+	return s.check(ctx, tenantID, name, false)
+}
+
+// ListDrift returns the namespaces found drifted by the last checks, of
+// tenantID or of all tenants when empty.
+func (s *DriftScanner) ListDrift(ctx context.Context, tenantID string) ([]Drift, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	result := make([]Drift, 0, len(s.drifted))
+	for _, drift := range s.drifted {
+		if tenantID == "" || drift.TenantID == tenantID {
+			result = append(result, drift)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return driftKey(result[i].TenantID, result[i].Namespace) < driftKey(result[j].TenantID, result[j].Namespace)
+	})
+	return result, nil
+}
+
+// Run scans for drift every interval until ctx is cancelled.
+func (s *DriftScanner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A scan may remediate, which is completed on shutdown.
+			if err := s.Scan(context.WithoutCancel(ctx)); err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "drift scan incomplete", "error", err)
+			}
+		}
+	}
+}
+
+func (s *DriftScanner) check(ctx context.Context, tenantID, name string, remediate bool) (*Drift, error) {
+	drift, err := s.reconciler.DetectDrift(ctx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if drift == nil {
+		return nil, ErrNotConnected
+	}
+
+	key := driftKey(tenantID, name)
+	if !drift.Drifted() {
+		s.mtx.Lock()
+		delete(s.drifted, key)
+		s.mtx.Unlock()
+		return drift, nil
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.WarnContext(ctx, "namespace drifted from its record",
+		"tenant_id", tenantID, "namespace", name, "cluster", drift.Cluster, "differences", len(drift.Differences))
+	if remediate {
+		if err := s.reconciler.ReconcileNamespace(ctx, tenantID, name); err == nil {
+			drift.Remediated = true
+			logger.InfoContext(ctx, "drifted namespace remediated", "tenant_id", tenantID, "namespace", name)
+		}
+	}
+
+	s.mtx.Lock()
+	s.drifted[key] = *drift
+	s.mtx.Unlock()
+	return drift, nil
+}
+
+func driftKey(tenantID, name string) string {
+	return tenantID + "/" + name
+}
@@ -0,0 +1,30 @@
+package service
+
+import "errors"
+
+var (
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrTenantSuspended   = errors.New("tenant is suspended")
+	ErrTenantArchived    = errors.New("tenant is archived")
+	ErrRetentionExpired  = errors.New("retention period has expired")
+	ErrInvalidExpiry     = errors.New("invalid expiry")
+	ErrNotEphemeral      = errors.New("namespace has no expiry")
+	ErrInvalidTemplate   = errors.New("invalid template")
+	ErrInvalidSpec       = errors.New("invalid namespace spec")
+	ErrInvalidTenant     = errors.New("invalid tenant")
+	ErrInvalidMember     = errors.New("invalid member")
+	ErrInvalidQuota      = errors.New("invalid quota")
+	ErrQuotaExceeded     = errors.New("tenant quota exceeded")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrParentNotFound    = errors.New("parent namespace not found")
+	ErrNamespaceCycle    = errors.New("namespace would be its own ancestor")
+	ErrHasChildren       = errors.New("namespace has child namespaces")
+	ErrInvalidCluster    = errors.New("invalid cluster")
+	ErrClusterInUse      = errors.New("cluster has namespaces")
+	ErrNoCluster         = errors.New("no cluster can take the namespace")
+	ErrNotConnected      = errors.New("naas isn't connected to the namespace's cluster")
+	ErrInvalidAdoption   = errors.New("invalid adoption")
+	ErrInvalidTransfer   = errors.New("invalid transfer")
+	ErrInvalidBulk       = errors.New("invalid bulk request")
+	ErrInvalidPatch      = errors.New("invalid patch")
+)
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
+	. "naas/repositories"
+)
+
+// Built-in finalizers.
+const (
+	// FinalizerCluster deletes the namespace from the cluster.
+	FinalizerCluster = "naas.io/cluster"
+	// FinalizerNamespaces holds a tenant back until all of its namespaces
+	// are purged.
+	FinalizerNamespaces = "naas.io/namespaces"
+)
+
+// NamespaceFinalizer cleans up after a namespace that is being purged. It
+// must be idempotent: it is retried on the next purge until it succeeds.
+type NamespaceFinalizer func(ctx context.Context, tenantID string, namespace *Namespace) error
+
+// TenantFinalizer cleans up after a tenant that is being purged. It must be
+// idempotent: it is retried on the next purge until it succeeds.
+type TenantFinalizer func(ctx context.Context, tenant *Tenant) error
+
+type finalizer[F any] struct {
+	name string
+	fn   F
+}
+
+// Finalizers is the registry of cleanup steps run before deleted tenants
+// and namespaces are removed for good. New records get the names of all
+// registered finalizers; a record is only removed once every name has been
+// taken off it by its handler. Finalizers run one at a time, in the reverse
+// order of registration, so that the built-in ones run last.
+//
+// A nil *Finalizers is valid and has no finalizers.
+type Finalizers struct {
+	tenantRepo    *TenantRepository
+	namespaceRepo *NamespaceRepository
+
+	mtx        sync.RWMutex
+	tenants    []finalizer[TenantFinalizer]
+	namespaces []finalizer[NamespaceFinalizer]
+}
+
+// NewFinalizers returns a registry holding the built-in finalizers.
+func NewFinalizers(tenants *TenantRepository, namespaces *NamespaceRepository, reconciler *reconciler.Reconciler) *Finalizers {
+	f := &Finalizers{tenantRepo: tenants, namespaceRepo: namespaces}
+
+	f.RegisterNamespace(FinalizerCluster, func(ctx context.Context, tenantID string, namespace *Namespace) error {
+		return reconciler.DeleteNamespace(ctx, tenantID, namespace.Cluster, namespace.Name)
+	})
+	f.RegisterTenant(FinalizerNamespaces, func(ctx context.Context, tenant *Tenant) error {
+		remaining, err := namespaces.GetAllNamespaces(ctx, tenant.ID, IncludeDeleted(true))
+		if errors.Is(err, ErrNoTenantNamespaces) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%d namespaces remain", len(remaining))
+	})
+
+	return f
+}
+
+func (f *Finalizers) RegisterTenant(name string, fn TenantFinalizer) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.tenants = append(f.tenants, finalizer[TenantFinalizer]{name: name, fn: fn})
+}
+
+func (f *Finalizers) RegisterNamespace(name string, fn NamespaceFinalizer) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.namespaces = append(f.namespaces, finalizer[NamespaceFinalizer]{name: name, fn: fn})
+}
+
+// tenantNames returns the finalizers given to new tenants, in run order.
+func (f *Finalizers) tenantNames() []string {
+	if f == nil {
+		return nil
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return names(f.tenants)
+}
+
+// namespaceNames returns the finalizers given to new namespaces, in run
+// order.
+func (f *Finalizers) namespaceNames() []string {
+	if f == nil {
+		return nil
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return names(f.namespaces)
+}
+
+// finalizeTenant runs the pending finalizers of tenant, removing each from
+// the stored record once it succeeds. It stops at the first failure.
+func (f *Finalizers) finalizeTenant(ctx context.Context, tenant *Tenant) error {
+	for len(tenant.Finalizers) > 0 {
+		name := tenant.Finalizers[0]
+		fn, ok := f.tenantFinalizer(name)
+		if !ok {
+			return fmt.Errorf("finalizer %s: no handler registered", name)
+		}
+		if err := fn(ctx, tenant); err != nil {
+			return fmt.Errorf("finalizer %s: %w", name, err)
+		}
+
+		tenant.Finalizers = tenant.Finalizers[1:]
+		if err := f.tenantRepo.UpdateTenant(ctx, tenant); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "tenant finalizer completed", "tenant_id", tenant.ID, "finalizer", name)
+	}
+	return nil
+}
+
+// finalizeNamespace runs the pending finalizers of namespace, removing each
+// from the stored record once it succeeds. It stops at the first failure.
+func (f *Finalizers) finalizeNamespace(ctx context.Context, tenantID string, namespace *Namespace) error {
+	for len(namespace.Finalizers) > 0 {
+		name := namespace.Finalizers[0]
+		fn, ok := f.namespaceFinalizer(name)
+		if !ok {
+			return fmt.Errorf("finalizer %s: no handler registered", name)
+		}
+		if err := fn(ctx, tenantID, namespace); err != nil {
+			return fmt.Errorf("finalizer %s: %w", name, err)
+		}
+
+		namespace.Finalizers = namespace.Finalizers[1:]
+		if err := f.namespaceRepo.UpdateNamespace(ctx, tenantID, namespace); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).InfoContext(ctx, "namespace finalizer completed",
+			"tenant_id", tenantID, "namespace", namespace.Name, "finalizer", name)
+	}
+	return nil
+}
+
+func (f *Finalizers) tenantFinalizer(name string) (TenantFinalizer, bool) {
+	if f == nil {
+		return nil, false
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return find(f.tenants, name)
+}
+
+func (f *Finalizers) namespaceFinalizer(name string) (NamespaceFinalizer, bool) {
+	if f == nil {
+		return nil, false
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return find(f.namespaces, name)
+}
+
+func find[F any](finalizers []finalizer[F], name string) (F, bool) {
+	for _, fin := range finalizers {
+		if fin.name == name {
+			return fin.fn, true
+		}
+	}
+	var zero F
+	return zero, false
+}
+
+func names[F any](finalizers []finalizer[F]) []string {
+	if len(finalizers) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(finalizers))
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		result = append(result, finalizers[i].name)
+	}
+	return result
+}
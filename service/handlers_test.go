@@ -1,7 +1,13 @@
 package service_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"naas/domain"
@@ -11,97 +17,864 @@ import (
 
 func TestTenantService_CreateTenant(t *testing.T) {
 	repo := repositories.NewTenantRepository()
-	service := service.NewTenantService(repo)
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
 
 	tenant := &domain.Tenant{
 		ID:   "test-tenant",
 		Name: "Test Tenant",
 	}
 
-	err := service.CreateTenant(tenant)
+	err := service.CreateTenant(context.Background(), tenant)
 	assert.NoError(t, err)
 
-	err = service.CreateTenant(tenant)
+	err = service.CreateTenant(context.Background(), tenant)
 	assert.EqualError(t, err, "tenant already exists")
 }
 
 func TestTenantService_GetTenant(t *testing.T) {
 	repo := repositories.NewTenantRepository()
-	service := service.NewTenantService(repo)
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
 
 	tenant := &domain.Tenant{
 		ID:   "test-tenant",
 		Name: "Test Tenant",
 	}
-	err := repo.CreateTenant(tenant)
+	err := repo.CreateTenant(context.Background(), tenant)
 	assert.NoError(t, err)
 
-	result, err := service.GetTenant("test-tenant")
+	result, err := service.GetTenant(context.Background(), "test-tenant")
 	assert.NoError(t, err)
 	assert.Equal(t, tenant, result)
 
-	result, err = service.GetTenant("non-existent-tenant")
+	result, err = service.GetTenant(context.Background(), "non-existent-tenant")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "tenant not found")
 }
 
 func TestNamespaceService_CreateNamespace(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 
 	namespace := &domain.Namespace{
 		Name: "test-namespace",
 	}
 
-	err := service.CreateNamespace("test-tenant", namespace)
+	err := service.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.NoError(t, err)
 
-	err = service.CreateNamespace("test-tenant", namespace)
+	err = service.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.EqualError(t, err, "namespace already exists")
 }
 
 func TestNamespaceService_GetAllNamespaces(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 
 	namespaces := []domain.Namespace{
 		{Name: "test-namespace-1"},
 		{Name: "test-namespace-2"},
 		{Name: "test-namespace-3"},
 	}
-	for _, ns := range namespaces {
-		err := repo.CreateNamespace("test-tenant", &ns)
+	for i := range namespaces {
+		err := repo.CreateNamespace(context.Background(), "test-tenant", &namespaces[i])
 		assert.NoError(t, err)
 	}
 
-	result, err := service.GetAllNamespaces("test-tenant")
+	result, err := service.GetAllNamespaces(context.Background(), "test-tenant")
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, namespaces, result)
 
-	result, err = service.GetAllNamespaces("non-existent-tenant")
+	result, err = service.GetAllNamespaces(context.Background(), "non-existent-tenant")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "no namespaces found for tenant")
 }
 
 func TestNamespaceService_GetNamespace(t *testing.T) {
 	repo := repositories.NewNamespaceRepository()
-	service := service.NewNamespaceService(repo)
+	service := service.NewNamespaceService(repo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
 
 	namespace := &domain.Namespace{
 		Name: "test-namespace",
 	}
-	err := repo.CreateNamespace("test-tenant", namespace)
+	err := repo.CreateNamespace(context.Background(), "test-tenant", namespace)
 	assert.NoError(t, err)
 
-	result, err := service.GetNamespace("test-tenant", "test-namespace")
+	result, err := service.GetNamespace(context.Background(), "test-tenant", "test-namespace")
 	assert.NoError(t, err)
 	assert.Equal(t, namespace, result)
 
-	result, err = service.GetNamespace("test-tenant", "non-existent-namespace")
+	result, err = service.GetNamespace(context.Background(), "test-tenant", "non-existent-namespace")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "namespace not found")
 
-	result, err = service.GetNamespace("non-existent-tenant", "test-namespace")
+	result, err = service.GetNamespace(context.Background(), "non-existent-tenant", "test-namespace")
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "namespace not found")
 }
+
+func TestTenantService_TransitionTenant(t *testing.T) {
+	repo := repositories.NewTenantRepository()
+	service := service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+
+	tenant := &domain.Tenant{ID: "test-tenant", Name: "Test Tenant"}
+	assert.NoError(t, service.CreateTenant(context.Background(), tenant))
+	assert.Equal(t, domain.TenantActive, tenant.Status)
+
+	result, err := service.TransitionTenant(context.Background(), "test-tenant", domain.TenantSuspended, "unpaid")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TenantSuspended, result.Status)
+	assert.Equal(t, "unpaid", result.StatusReason)
+
+	_, err = service.TransitionTenant(context.Background(), "test-tenant", domain.TenantSuspended, "")
+	assert.EqualError(t, err, "invalid status transition: Suspended to Suspended")
+
+	result, err = service.TransitionTenant(context.Background(), "test-tenant", domain.TenantActive, "")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TenantActive, result.Status)
+	assert.Empty(t, result.StatusReason)
+
+	_, err = service.TransitionTenant(context.Background(), "test-tenant", domain.TenantArchived, "")
+	assert.NoError(t, err)
+
+	_, err = service.TransitionTenant(context.Background(), "test-tenant", domain.TenantActive, "")
+	assert.EqualError(t, err, "tenant is archived")
+	// Archived tenants are read-only, so they can't be deleted either
+	_, err = service.DeleteTenant(context.Background(), "test-tenant")
+	assert.EqualError(t, err, "tenant is archived")
+
+	_, err = service.TransitionTenant(context.Background(), "non-existent-tenant", domain.TenantSuspended, "")
+	assert.EqualError(t, err, "tenant not found")
+}
+
+func TestNamespaceService_CreateNamespace_InactiveTenant(t *testing.T) {
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(context.Background(), &domain.Tenant{ID: "test-tenant"}))
+	_, err := tenantService.TransitionTenant(context.Background(), "test-tenant", domain.TenantSuspended, "")
+	assert.NoError(t, err)
+
+	err = namespaceService.CreateNamespace(context.Background(), "test-tenant", &domain.Namespace{Name: "test-namespace"})
+	assert.ErrorIs(t, err, service.ErrTenantSuspended)
+
+	_, err = tenantService.TransitionTenant(context.Background(), "test-tenant", domain.TenantArchived, "")
+	assert.NoError(t, err)
+
+	err = namespaceService.CreateNamespace(context.Background(), "test-tenant", &domain.Namespace{Name: "test-namespace"})
+	assert.ErrorIs(t, err, service.ErrTenantArchived)
+}
+
+func TestNamespaceService_CreateNamespace_InvalidName(t *testing.T) {
+	namespaceRepo := repositories.NewNamespaceRepository()
+	namespaceService := service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+
+	for _, name := range []string{"", "../../../escape", "team/a", "Team-A", strings.Repeat("a", 64)} {
+		err := namespaceService.CreateNamespace(context.Background(), "acme", &domain.Namespace{Name: name})
+		assert.ErrorIs(t, err, service.ErrInvalidSpec, name)
+	}
+	_, err := namespaceRepo.GetAllNamespaces(context.Background(), "acme")
+	assert.ErrorIs(t, err, repositories.ErrNoTenantNamespaces)
+}
+
+func TestNamespaceService_Quota(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+
+	err := tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme", Quota: map[string]string{"pods": "lots"}})
+	assert.ErrorIs(t, err, service.ErrInvalidQuota)
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme", Quota: map[string]string{"pods": "20"}}))
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+	// Children count with the limits they inherit
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Parent: "org"}))
+	err = namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "5"},
+	}})
+	assert.ErrorIs(t, err, service.ErrQuotaExceeded)
+
+	_, err = namespaceService.UpdateNamespace(ctx, "acme", &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "15"},
+	}})
+	assert.ErrorIs(t, err, service.ErrQuotaExceeded)
+	_, err = namespaceService.UpdateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Parent: "org", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "2"},
+	}})
+	assert.NoError(t, err)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "5"},
+	}}))
+
+	// Lowering the quota keeps the namespaces, but they can't grow
+	_, err = tenantService.UpdateTenant(ctx, &domain.Tenant{ID: "acme", Quota: map[string]string{"pods": "10"}})
+	assert.NoError(t, err)
+	_, err = namespaceService.UpdateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b"})
+	assert.NoError(t, err)
+	_, err = namespaceService.SetParent(ctx, "acme", "team-b", "org")
+	assert.ErrorIs(t, err, service.ErrQuotaExceeded)
+}
+
+func TestNamespaceService_ConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterRepo := repositories.NewClusterRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, clusterRepo, nil, nil, time.Hour)
+
+	assert.NoError(t, clusterRepo.CreateCluster(ctx, &domain.Cluster{Name: "east", Kubeconfig: "/east", Capacity: 5}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme"}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "globex", Quota: map[string]string{"pods": "3"}}))
+
+	// Creates racing for the last room on a cluster or in a quota can't
+	// all get it
+	create := func(tenantID string) int {
+		var wg sync.WaitGroup
+		var mtx sync.Mutex
+		created := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := namespaceService.CreateNamespace(ctx, tenantID, &domain.Namespace{
+					Name:          fmt.Sprintf("team-%d", i),
+					NamespaceSpec: domain.NamespaceSpec{Quota: map[string]string{"pods": "1"}},
+				})
+				if err == nil {
+					mtx.Lock()
+					created++
+					mtx.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+		return created
+	}
+	assert.Equal(t, 3, create("globex"))
+	assert.Equal(t, 2, create("acme"))
+}
+
+func TestNamespaceService_NamesPerCluster(t *testing.T) {
+	ctx := context.Background()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	clusterRepo := repositories.NewClusterRepository()
+	namespaceService := service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, clusterRepo, nil, nil, time.Hour)
+	for _, name := range []string{"east", "west"} {
+		assert.NoError(t, clusterRepo.CreateCluster(ctx, &domain.Cluster{Name: name, Kubeconfig: "/" + name}))
+	}
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "shared", Cluster: "east"}))
+	err := namespaceService.CreateNamespace(ctx, "globex", &domain.Namespace{Name: "shared", Cluster: "east"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "globex", &domain.Namespace{Name: "shared", Cluster: "west"}))
+
+	// Deleted namespaces hold their name until they are purged
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "shared")
+	assert.NoError(t, err)
+	err = namespaceService.CreateNamespace(ctx, "initech", &domain.Namespace{Name: "shared", Cluster: "east"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+}
+
+func TestNamespaceService_TransferNamespace_Target(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme"}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "globex", Quota: map[string]string{"pods": "5"}}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "initech"}))
+	_, err := tenantService.TransitionTenant(ctx, "initech", domain.TenantSuspended, "")
+	assert.NoError(t, err)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-b"}))
+
+	_, err = namespaceService.TransferNamespace(ctx, "acme", "team-b", "missing", "")
+	assert.ErrorIs(t, err, service.ErrInvalidTransfer)
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+	_, err = namespaceService.TransferNamespace(ctx, "acme", "team-b", "initech", "")
+	assert.ErrorIs(t, err, service.ErrTenantSuspended)
+	_, err = namespaceService.TransferNamespace(ctx, "acme", "team-a", "globex", "")
+	assert.ErrorIs(t, err, service.ErrQuotaExceeded)
+
+	_, err = namespaceService.TransferNamespace(ctx, "acme", "team-b", "globex", "")
+	assert.NoError(t, err)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+}
+
+func TestTenantService_DeleteAndRestoreTenant(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "test-tenant"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "ns-1"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "ns-2"}))
+
+	// ns-1 is deleted on its own before the tenant, so it stays deleted.
+	_, err := namespaceService.DeleteNamespace(ctx, "test-tenant", "ns-1")
+	assert.NoError(t, err)
+
+	deleted, err := tenantService.DeleteTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.True(t, deleted.Deleted())
+
+	tenants, err := tenantService.ListTenants(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+	namespaces, err := namespaceService.GetAllNamespaces(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.Empty(t, namespaces)
+
+	err = namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "ns-3"})
+	assert.ErrorIs(t, err, repositories.ErrTenantDeleted)
+	_, err = namespaceService.RestoreNamespace(ctx, "test-tenant", "ns-2")
+	assert.ErrorIs(t, err, repositories.ErrTenantDeleted)
+	_, err = tenantService.TransitionTenant(ctx, "test-tenant", domain.TenantSuspended, "unpaid")
+	assert.ErrorIs(t, err, repositories.ErrTenantDeleted)
+
+	restored, err := tenantService.RestoreTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.False(t, restored.Deleted())
+
+	namespaces, err = namespaceService.GetAllNamespaces(ctx, "test-tenant")
+	assert.NoError(t, err)
+	if assert.Len(t, namespaces, 1) {
+		// Restoring is a write, so only the version differs from before
+		assert.Equal(t, domain.Namespace{Name: "ns-2", ResourceVersion: namespaces[0].ResourceVersion}, namespaces[0])
+	}
+
+	_, err = tenantService.RestoreTenant(ctx, "test-tenant")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotDeleted)
+}
+
+func TestNamespaceService_RestoreNamespace_Expired(t *testing.T) {
+	ctx := context.Background()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	namespaceService := service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, namespaceRepo.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "test-namespace"}))
+	_, err := namespaceRepo.DeleteNamespace(ctx, "test-tenant", "test-namespace", time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+
+	_, err = namespaceService.RestoreNamespace(ctx, "test-tenant", "test-namespace")
+	assert.ErrorIs(t, err, service.ErrRetentionExpired)
+}
+
+func TestPurger_Purge(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, nil)
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, finalizers, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, finalizers, time.Hour)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, time.Hour)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "tenant-a"}))
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "tenant-b"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "tenant-a", &domain.Namespace{Name: "ns-1"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "tenant-b", &domain.Namespace{Name: "ns-2"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "tenant-b", &domain.Namespace{Name: "ns-3"}))
+
+	_, err := tenantService.DeleteTenant(ctx, "tenant-a")
+	assert.NoError(t, err)
+	_, err = namespaceService.DeleteNamespace(ctx, "tenant-b", "ns-2")
+	assert.NoError(t, err)
+
+	// Nothing has expired yet.
+	assert.NoError(t, purger.Purge(ctx, time.Now()))
+	_, err = tenantRepo.GetTenant(ctx, "tenant-a")
+	assert.NoError(t, err)
+
+	assert.NoError(t, purger.Purge(ctx, time.Now().Add(2*time.Hour)))
+
+	_, err = tenantRepo.GetTenant(ctx, "tenant-a")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+	_, err = namespaceRepo.GetNamespace(ctx, "tenant-a", "ns-1")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = namespaceRepo.GetNamespace(ctx, "tenant-b", "ns-2")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	namespaces, err := namespaceRepo.GetAllNamespaces(ctx, "tenant-b", repositories.IncludeDeleted(true))
+	assert.NoError(t, err)
+	assert.Len(t, namespaces, 1)
+	assert.Equal(t, "ns-3", namespaces[0].Name)
+}
+
+func TestPurger_Finalizers(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	finalizers := service.NewFinalizers(tenantRepo, namespaceRepo, nil)
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, finalizers, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, finalizers, time.Hour)
+	purger := service.NewPurger(tenantRepo, namespaceRepo, finalizers, time.Hour)
+
+	var calls []string
+	dnsErr := errors.New("dns unavailable")
+	finalizers.RegisterNamespace("example.com/dns", func(ctx context.Context, tenantID string, ns *domain.Namespace) error {
+		calls = append(calls, "dns")
+		return dnsErr
+	})
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "test-tenant"}))
+	namespace := &domain.Namespace{Name: "test-namespace"}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", namespace))
+	// Registered finalizers run last to first.
+	assert.Equal(t, []string{"example.com/dns", service.FinalizerCluster}, namespace.Finalizers)
+
+	_, err := tenantService.DeleteTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+
+	later := time.Now().Add(2 * time.Hour)
+	err = purger.Purge(ctx, later)
+	assert.ErrorIs(t, err, dnsErr)
+
+	// The failing finalizer keeps the namespace, which keeps the tenant.
+	stored, err := namespaceRepo.GetNamespace(ctx, "test-tenant", "test-namespace")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com/dns", service.FinalizerCluster}, stored.Finalizers)
+	tenant, err := tenantRepo.GetTenant(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{service.FinalizerNamespaces}, tenant.Finalizers)
+
+	dnsErr = nil
+	assert.NoError(t, purger.Purge(ctx, later))
+	assert.Equal(t, []string{"dns", "dns"}, calls)
+
+	_, err = namespaceRepo.GetNamespace(ctx, "test-tenant", "test-namespace")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+	_, err = tenantRepo.GetTenant(ctx, "test-tenant")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+}
+
+func TestNamespaceService_RenewNamespace(t *testing.T) {
+	ctx := context.Background()
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+
+	past := time.Now().Add(-time.Minute)
+	err := namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "preview", ExpiresAt: &past})
+	assert.ErrorIs(t, err, service.ErrInvalidExpiry)
+
+	expiresAt := time.Now().Add(time.Hour)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "preview", ExpiresAt: &expiresAt}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "permanent"}))
+
+	renewed, err := namespaceService.RenewNamespace(ctx, "test-tenant", "preview", 24*time.Hour)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *renewed.ExpiresAt, time.Minute)
+
+	_, err = namespaceService.RenewNamespace(ctx, "test-tenant", "preview", 0)
+	assert.ErrorIs(t, err, service.ErrInvalidExpiry)
+	_, err = namespaceService.RenewNamespace(ctx, "test-tenant", "permanent", time.Hour)
+	assert.ErrorIs(t, err, service.ErrNotEphemeral)
+	_, err = namespaceService.RenewNamespace(ctx, "test-tenant", "missing", time.Hour)
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+}
+
+func TestReaper_Reap(t *testing.T) {
+	ctx := context.Background()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	namespaceService := service.NewNamespaceService(namespaceRepo, repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+	reaper := service.NewReaper(namespaceRepo, namespaceService, time.Hour)
+
+	soon := time.Now().Add(30 * time.Minute)
+	later := time.Now().Add(3 * time.Hour)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "soon", ExpiresAt: &soon}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "later", ExpiresAt: &later}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "test-tenant", &domain.Namespace{Name: "permanent"}))
+
+	assert.NoError(t, reaper.Reap(ctx, time.Now()))
+	namespaces, err := namespaceService.GetAllNamespaces(ctx, "test-tenant")
+	assert.NoError(t, err)
+	assert.Len(t, namespaces, 3)
+
+	assert.NoError(t, reaper.Reap(ctx, time.Now().Add(time.Hour)))
+
+	ns, err := namespaceService.GetNamespace(ctx, "test-tenant", "soon")
+	assert.NoError(t, err)
+	assert.True(t, ns.Deleted())
+	ns, err = namespaceService.GetNamespace(ctx, "test-tenant", "later")
+	assert.NoError(t, err)
+	assert.False(t, ns.Deleted())
+
+	// Reaped namespaces can be restored like any deleted namespace.
+	_, err = namespaceService.RestoreNamespace(ctx, "test-tenant", "soon")
+	assert.NoError(t, err)
+}
+
+func TestNamespaceService_CreateNamespace_Template(t *testing.T) {
+	ctx := context.Background()
+	templateService := service.NewTemplateService(repositories.NewTemplateRepository())
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), templateService, nil, nil, nil, time.Hour)
+
+	err := templateService.CreateTemplate(ctx, &domain.Template{Name: "bad", Spec: domain.NamespaceSpec{Labels: map[string]string{"a": "${b}"}}})
+	assert.ErrorIs(t, err, service.ErrInvalidTemplate)
+
+	assert.NoError(t, templateService.CreateTemplate(ctx, &domain.Template{
+		Name:       "team",
+		Parameters: []domain.TemplateParameter{{Name: "team", Required: true}},
+		Spec: domain.NamespaceSpec{
+			Labels: map[string]string{"team": "${team}", "scope": "global"},
+			Quota:  map[string]string{"pods": "10"},
+		},
+	}))
+	assert.NoError(t, templateService.CreateTemplate(ctx, &domain.Template{
+		Name:       "team",
+		TenantID:   "acme",
+		Parameters: []domain.TemplateParameter{{Name: "team", Required: true}},
+		Spec:       domain.NamespaceSpec{Labels: map[string]string{"team": "${team}", "scope": "tenant"}},
+	}))
+
+	// The tenant's template shadows the global one, and values given with
+	// the namespace win over the template's.
+	namespace := &domain.Namespace{
+		Name:          "payments",
+		Template:      "team",
+		Parameters:    map[string]string{"team": "payments"},
+		NamespaceSpec: domain.NamespaceSpec{Labels: map[string]string{"tier": "gold"}},
+	}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", namespace))
+	assert.Equal(t, map[string]string{"team": "payments", "scope": "tenant", "tier": "gold"}, namespace.Labels)
+
+	namespace = &domain.Namespace{Name: "ledger", Template: "team", Parameters: map[string]string{"team": "payments"}}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "other", namespace))
+	assert.Equal(t, "global", namespace.Labels["scope"])
+	assert.Equal(t, map[string]string{"pods": "10"}, namespace.Quota)
+
+	err = namespaceService.CreateNamespace(ctx, "other", &domain.Namespace{Name: "missing-param", Template: "team"})
+	assert.ErrorIs(t, err, service.ErrInvalidSpec)
+	err = namespaceService.CreateNamespace(ctx, "other", &domain.Namespace{Name: "unknown", Template: "unknown"})
+	assert.ErrorIs(t, err, repositories.ErrTemplateNotFound)
+	err = namespaceService.CreateNamespace(ctx, "other", &domain.Namespace{
+		Name:          "invalid",
+		NamespaceSpec: domain.NamespaceSpec{Quota: map[string]string{"pods": "many"}},
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidSpec)
+}
+
+func TestNamespaceService_Hierarchy(t *testing.T) {
+	ctx := context.Background()
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a", Parent: "org"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "service", Parent: "team-a"}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "other"}))
+
+	err := namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "lost", Parent: "missing"})
+	assert.ErrorIs(t, err, service.ErrParentNotFound)
+	// Parents must belong to the same tenant
+	err = namespaceService.CreateNamespace(ctx, "globex", &domain.Namespace{Name: "lost", Parent: "org"})
+	assert.ErrorIs(t, err, service.ErrParentNotFound)
+
+	_, err = namespaceService.SetParent(ctx, "acme", "org", "service")
+	assert.ErrorIs(t, err, service.ErrNamespaceCycle)
+	_, err = namespaceService.SetParent(ctx, "acme", "org", "org")
+	assert.ErrorIs(t, err, service.ErrNamespaceCycle)
+	moved, err := namespaceService.SetParent(ctx, "acme", "team-a", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", moved.Parent)
+
+	subtree, err := namespaceService.GetSubtree(ctx, "acme", "other")
+	assert.NoError(t, err)
+	if assert.Len(t, subtree, 3) {
+		assert.Equal(t, "service", subtree[2].Name)
+	}
+	_, err = namespaceService.GetSubtree(ctx, "acme", "missing")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, service.ErrHasChildren)
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "service")
+	assert.NoError(t, err)
+	_, err = namespaceService.DeleteNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+
+	// A child can't come back while its parent is deleted
+	_, err = namespaceService.RestoreNamespace(ctx, "acme", "service")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceDeleted)
+}
+
+func TestNamespaceService_CloneNamespace(t *testing.T) {
+	ctx := context.Background()
+	templateService := service.NewTemplateService(repositories.NewTemplateRepository())
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), templateService, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, templateService.CreateTemplate(ctx, &domain.Template{
+		Name:       "team",
+		Parameters: []domain.TemplateParameter{{Name: "team", Required: true}},
+		Spec:       domain.NamespaceSpec{Labels: map[string]string{"team": "${team}"}},
+	}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "10"},
+	}}))
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{
+		Name:          "staging",
+		Parent:        "org",
+		Template:      "team",
+		Parameters:    map[string]string{"team": "payments"},
+		NamespaceSpec: domain.NamespaceSpec{Labels: map[string]string{"tier": "gold"}},
+	}))
+
+	// Clones in the same tenant keep the parent, and overrides win
+	clone, err := namespaceService.CloneNamespace(ctx, "acme", "staging", "", &domain.Namespace{
+		Name:          "staging-2",
+		NamespaceSpec: domain.NamespaceSpec{Labels: map[string]string{"tier": "silver"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "org", clone.Parent)
+	assert.Equal(t, "team", clone.Template)
+	assert.Equal(t, map[string]string{"team": "payments", "tier": "silver"}, clone.Labels)
+	assert.Nil(t, clone.Quota)
+	_, err = namespaceService.GetNamespace(ctx, "acme", "staging-2")
+	assert.NoError(t, err)
+
+	// Clones in another tenant get the inherited spec as their own
+	clone, err = namespaceService.CloneNamespace(ctx, "acme", "staging", "globex", &domain.Namespace{
+		Name:       "staging-3",
+		Parameters: map[string]string{"team": "billing"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, clone.Parent)
+	assert.Equal(t, map[string]string{"pods": "10"}, clone.Quota)
+	assert.Equal(t, map[string]string{"team": "billing"}, clone.Parameters)
+
+	_, err = namespaceService.CloneNamespace(ctx, "acme", "staging", "", &domain.Namespace{Name: "staging"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+	// Names are unique on a cluster across tenants
+	_, err = namespaceService.CloneNamespace(ctx, "acme", "staging", "globex", &domain.Namespace{Name: "staging"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceExists)
+	_, err = namespaceService.CloneNamespace(ctx, "acme", "staging", "", &domain.Namespace{})
+	assert.ErrorIs(t, err, service.ErrInvalidSpec)
+	_, err = namespaceService.CloneNamespace(ctx, "acme", "missing", "", &domain.Namespace{Name: "copy"})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+}
+
+func TestBulkService_Apply(t *testing.T) {
+	ctx := context.Background()
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+	bulkService := service.NewBulkService(tenantService, namespaceService, nil)
+
+	_, err := bulkService.Apply(ctx, &domain.Bulk{})
+	assert.ErrorIs(t, err, service.ErrInvalidBulk)
+
+	// All or nothing: the duplicate undoes the tenant and namespace before it
+	report, err := bulkService.Apply(ctx, &domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: domain.BulkCreate, Tenant: &domain.Tenant{ID: "acme"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-b"}},
+	}})
+	assert.NoError(t, err)
+	assert.False(t, report.Committed)
+	assert.Equal(t, domain.BulkAllOrNothing, report.Mode)
+	var statuses []domain.BulkStatus
+	for _, r := range report.Results {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []domain.BulkStatus{domain.BulkRolledBack, domain.BulkRolledBack, domain.BulkFailed, domain.BulkSkipped}, statuses)
+	assert.ErrorIs(t, report.Results[2].Err, repositories.ErrNamespaceExists)
+	_, err = tenantRepo.GetTenant(ctx, "acme")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	// Best effort: only the failed operations are undone
+	report, err = bulkService.Apply(ctx, &domain.Bulk{Mode: domain.BulkBestEffort, Operations: []domain.BulkOperation{
+		{Action: domain.BulkCreate, Tenant: &domain.Tenant{ID: "acme"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a"}},
+		{Action: domain.BulkCreate, TenantID: "acme", Namespace: &domain.Namespace{Name: "lost", Parent: "missing"}},
+		{Action: domain.BulkUpdate, TenantID: "acme", Namespace: &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+			Quota: map[string]string{"pods": "10"},
+		}}},
+		{Action: domain.BulkUpdate, Tenant: &domain.Tenant{ID: "acme", Name: "Acme"}},
+		{Action: domain.BulkDelete, TenantID: "acme", Namespace: &domain.Namespace{Name: "missing"}},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, report.Committed)
+	statuses = nil
+	for _, r := range report.Results {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []domain.BulkStatus{
+		domain.BulkSucceeded, domain.BulkSucceeded, domain.BulkFailed, domain.BulkSucceeded, domain.BulkSucceeded, domain.BulkFailed,
+	}, statuses)
+	assert.ErrorIs(t, report.Results[2].Err, service.ErrParentNotFound)
+	assert.ErrorIs(t, report.Results[5].Err, repositories.ErrNamespaceNotFound)
+
+	tenant, err := tenantRepo.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme", tenant.Name)
+	namespace, err := namespaceRepo.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"pods": "10"}, namespace.Quota)
+	_, err = namespaceRepo.GetNamespace(ctx, "acme", "lost")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	// Undoing a tenant deletion restores its namespaces too
+	report, err = bulkService.Apply(ctx, &domain.Bulk{Operations: []domain.BulkOperation{
+		{Action: domain.BulkDelete, Tenant: &domain.Tenant{ID: "acme"}},
+		{Action: domain.BulkUpdate, Tenant: &domain.Tenant{ID: "acme"}},
+	}})
+	assert.NoError(t, err)
+	assert.False(t, report.Committed)
+	assert.ErrorIs(t, report.Results[1].Err, repositories.ErrTenantDeleted)
+	tenant, err = tenantRepo.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.False(t, tenant.Deleted())
+	namespace, err = namespaceRepo.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.False(t, namespace.Deleted())
+}
+
+func TestNamespaceService_PatchNamespace(t *testing.T) {
+	ctx := context.Background()
+	namespaceService := service.NewNamespaceService(repositories.NewNamespaceRepository(), repositories.NewTenantRepository(), nil, nil, nil, nil, time.Hour)
+
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "org"}))
+	created := &domain.Namespace{Name: "team-a", NamespaceSpec: domain.NamespaceSpec{
+		Labels: map[string]string{"tier": "gold", "team": "payments"},
+	}}
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", created))
+
+	patched, err := namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{
+		Type: domain.MergePatch,
+		Data: []byte(`{"labels":{"tier":null},"quota":{"pods":"10"},"parent":"org"}`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments"}, patched.Labels)
+	assert.Equal(t, map[string]string{"pods": "10"}, patched.Quota)
+	assert.Equal(t, "org", patched.Parent)
+	assert.NotEqual(t, created.ResourceVersion, patched.ResourceVersion)
+
+	patched, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{
+		Type:            domain.JSONPatch,
+		Data:            []byte(`[{"op":"replace","path":"/quota/pods","value":"20"}]`),
+		ResourceVersion: patched.ResourceVersion,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"pods": "20"}, patched.Quota)
+
+	// A stale version conflicts, while a failed test in the patch means the
+	// patch doesn't apply
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{
+		Type:            domain.MergePatch,
+		Data:            []byte(`{"quota":null}`),
+		ResourceVersion: created.ResourceVersion,
+	})
+	assert.ErrorIs(t, err, repositories.ErrConflict)
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{
+		Type: domain.JSONPatch,
+		Data: []byte(`[{"op":"test","path":"/resourceVersion","value":"` + created.ResourceVersion + `"},{"op":"remove","path":"/quota"}]`),
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidPatch)
+
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"name":"team-b","cluster":"eu"}`)})
+	assert.EqualError(t, err, "invalid patch: cluster, name cannot be patched")
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"color":"blue"}`)})
+	assert.ErrorIs(t, err, service.ErrInvalidPatch)
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "team-a", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"quota":{"pods":"lots"}}`)})
+	assert.ErrorIs(t, err, service.ErrInvalidSpec)
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "org", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"parent":"team-a"}`)})
+	assert.ErrorIs(t, err, service.ErrNamespaceCycle)
+	_, err = namespaceService.PatchNamespace(ctx, "acme", "missing", domain.Patch{Type: domain.MergePatch, Data: []byte(`{}`)})
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	// Nothing was written by the failed patches
+	namespace, err := namespaceService.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, patched, namespace)
+}
+
+func TestTenantService_PatchTenant(t *testing.T) {
+	ctx := context.Background()
+	tenantService := service.NewTenantService(repositories.NewTenantRepository(), repositories.NewNamespaceRepository(), nil, nil, time.Hour)
+
+	created := &domain.Tenant{ID: "acme", Name: "Acme", Members: []domain.Member{{User: "alice", Role: domain.MemberAdmin}}}
+	assert.NoError(t, tenantService.CreateTenant(ctx, created))
+
+	patched, err := tenantService.PatchTenant(ctx, "acme", domain.Patch{
+		Type: domain.JSONPatch,
+		Data: []byte(`[{"op":"replace","path":"/name","value":"Acme Corp"},{"op":"add","path":"/members/-","value":{"user":"bob","role":"viewer"}}]`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme Corp", patched.Name)
+	assert.Equal(t, []domain.Member{{User: "alice", Role: domain.MemberAdmin}, {User: "bob", Role: domain.MemberViewer}}, patched.Members)
+
+	_, err = tenantService.PatchTenant(ctx, "acme", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"name":"Acme"}`), ResourceVersion: created.ResourceVersion})
+	assert.ErrorIs(t, err, repositories.ErrConflict)
+	_, err = tenantService.PatchTenant(ctx, "acme", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"resourceVersion":"` + created.ResourceVersion + `"}`)})
+	assert.ErrorIs(t, err, repositories.ErrConflict)
+	_, err = tenantService.PatchTenant(ctx, "acme", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"status":"Archived"}`)})
+	assert.EqualError(t, err, "invalid patch: status cannot be patched")
+	_, err = tenantService.PatchTenant(ctx, "acme", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"members":[{"user":"carol","role":"owner"}]}`)})
+	assert.ErrorIs(t, err, service.ErrInvalidMember)
+	_, err = tenantService.PatchTenant(ctx, "acme", domain.Patch{Type: domain.JSONPatch, Data: []byte(`[{"op":"remove","path":"/members/5"}]`)})
+	assert.ErrorIs(t, err, service.ErrInvalidPatch)
+
+	tenant, err := tenantService.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, patched, tenant)
+}
+
+func TestNamespaceService_DryRun(t *testing.T) {
+	ctx := context.Background()
+	dryRun := repositories.DryRun(ctx)
+	tenantRepo := repositories.NewTenantRepository()
+	namespaceRepo := repositories.NewNamespaceRepository()
+	tenantService := service.NewTenantService(tenantRepo, namespaceRepo, nil, nil, time.Hour)
+	namespaceService := service.NewNamespaceService(namespaceRepo, tenantRepo, nil, nil, nil, nil, time.Hour)
+
+	tenant := &domain.Tenant{ID: "acme"}
+	assert.NoError(t, tenantService.CreateTenant(dryRun, tenant))
+	assert.Equal(t, domain.TenantActive, tenant.Status)
+	_, err := tenantService.GetTenant(ctx, "acme")
+	assert.ErrorIs(t, err, repositories.ErrTenantNotFound)
+
+	assert.NoError(t, tenantService.CreateTenant(ctx, &domain.Tenant{ID: "acme"}))
+	namespace := &domain.Namespace{Name: "team-a"}
+	assert.NoError(t, namespaceService.CreateNamespace(dryRun, "acme", namespace))
+	_, err = namespaceService.GetNamespace(ctx, "acme", "team-a")
+	assert.ErrorIs(t, err, repositories.ErrNamespaceNotFound)
+
+	// Dry runs are validated and checked for conflicts like other changes
+	err = namespaceService.CreateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-b", NamespaceSpec: domain.NamespaceSpec{
+		Quota: map[string]string{"pods": "lots"},
+	}})
+	assert.ErrorIs(t, err, service.ErrInvalidSpec)
+	assert.NoError(t, namespaceService.CreateNamespace(ctx, "acme", &domain.Namespace{Name: "team-a"}))
+	assert.ErrorIs(t, namespaceService.CreateNamespace(dryRun, "acme", &domain.Namespace{Name: "team-a"}), repositories.ErrNamespaceExists)
+	_, err = tenantService.TransitionTenant(dryRun, "acme", domain.TenantActive, "")
+	assert.ErrorIs(t, err, service.ErrInvalidTransition)
+
+	patched, err := namespaceService.PatchNamespace(dryRun, "acme", "team-a", domain.Patch{Type: domain.MergePatch, Data: []byte(`{"labels":{"tier":"gold"}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"tier": "gold"}, patched.Labels)
+	deleted, err := tenantService.DeleteTenant(dryRun, "acme")
+	assert.NoError(t, err)
+	assert.True(t, deleted.Deleted())
+
+	stored, err := namespaceService.GetNamespace(ctx, "acme", "team-a")
+	assert.NoError(t, err)
+	assert.Empty(t, stored.Labels)
+	assert.False(t, stored.Deleted())
+	stillThere, err := tenantService.GetTenant(ctx, "acme")
+	assert.NoError(t, err)
+	assert.False(t, stillThere.Deleted())
+}
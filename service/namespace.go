@@ -1,26 +1,923 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/resource"
 	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
 	. "naas/repositories"
+	"naas/tracing"
 )
 
 type NamespaceService struct {
-	repo *NamespaceRepository
+	repo       *NamespaceRepository
+	tenants    *TenantRepository
+	templates  *TemplateService
+	clusters   *ClusterRepository
+	reconciler *reconciler.Reconciler
+	finalizers *Finalizers
+	retention  time.Duration
+}
+
+func NewNamespaceService(repo *NamespaceRepository, tenants *TenantRepository, templates *TemplateService, clusters *ClusterRepository, reconciler *reconciler.Reconciler, finalizers *Finalizers, retention time.Duration) *NamespaceService {
+	return &NamespaceService{repo: repo, tenants: tenants, templates: templates, clusters: clusters, reconciler: reconciler, finalizers: finalizers, retention: retention}
+}
+
+func (s *NamespaceService) CreateNamespace(ctx context.Context, tenantID string, namespace *Namespace) (err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.CreateNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", namespace.Name),
+	))
+	defer tracing.End(span, &err)
+
+	if err := ValidateNamespaceName(namespace.Name); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+
+	// The checks and the write hold the tenants' and namespaces' write
+	// locks, so concurrent creates can't all pass them and overfill a
+	// cluster or the tenant's quota.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return err
+	}
+
+	if namespace.Template != "" {
+		spec, err := s.templates.Instantiate(txCtx, tenantID, namespace)
+		if errors.Is(err, ErrTemplateNotFound) {
+			return fmt.Errorf("%w: %w", ErrInvalidSpec, err)
+		}
+		if err != nil {
+			return err
+		}
+		// Values given with the namespace take precedence over the template.
+		namespace.NamespaceSpec = spec.Merge(namespace.NamespaceSpec)
+	}
+	if err := namespace.NamespaceSpec.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+	if namespace.Isolation != nil {
+		if err := namespace.Isolation.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+		}
+	}
+
+	if namespace.Parent != "" {
+		if err := s.checkParent(txCtx, tenantID, namespace.Parent); err != nil {
+			return err
+		}
+	}
+	if err := s.checkQuota(txCtx, tenantID, namespace); err != nil {
+		return err
+	}
+
+	if namespace.ExpiresAt != nil {
+		if !namespace.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidExpiry)
+		}
+		expiresAt := namespace.ExpiresAt.UTC()
+		namespace.ExpiresAt = &expiresAt
+	}
+
+	if err := s.place(txCtx, tenantID, namespace); err != nil {
+		return err
+	}
+	if err := s.checkNameFree(txCtx, namespace, tenantID); err != nil {
+		return err
+	}
+
+	// Transfers are only recorded by TransferNamespace: they let the
+	// namespace take over its former tenants' namespace in the cluster.
+	namespace.Transfers = nil
+	namespace.DeletionTimestamp = nil
+	namespace.Conditions = nil
+	namespace.Finalizers = s.finalizers.namespaceNames()
+	if err := s.repo.CreateNamespace(txCtx, tenantID, namespace); err != nil {
+		return err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace created", "tenant_id", tenantID, "namespace", namespace.Name)
+
+	s.reconcile(ctx, tenantID, namespace)
+	return nil
+}
+
+func (s *NamespaceService) GetAllNamespaces(ctx context.Context, tenantID string, opts ...ListOption) (_ []Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.GetAllNamespaces", trace.WithAttributes(attribute.String("naas.tenant.id", tenantID)))
+	defer tracing.End(span, &err)
+
+	return s.repo.GetAllNamespaces(ctx, tenantID, opts...)
+}
+
+func (s *NamespaceService) GetNamespace(ctx context.Context, tenantID string, name string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.GetNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	return s.repo.GetNamespace(ctx, tenantID, name)
+}
+
+// DeleteNamespace soft-deletes a namespace. It is frozen in the cluster
+// until it is restored or purged. Namespaces with children can only be
+// deleted once their children are.
+func (s *NamespaceService) DeleteNamespace(ctx context.Context, tenantID, name string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.DeleteNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	// No child can be created or moved under the namespace between the
+	// check and the delete.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkNotArchived(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+	hierarchy, err := s.hierarchy(txCtx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range hierarchy.Children(name) {
+		if !child.Deleted() {
+			return nil, fmt.Errorf("%w: %s", ErrHasChildren, child.Name)
+		}
+	}
+
+	namespace, err := s.repo.DeleteNamespace(txCtx, tenantID, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace deleted", "tenant_id", tenantID, "namespace", name)
+
+	s.reconcile(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// RestoreNamespace undoes DeleteNamespace within the retention period. The
+// tenant must be writable: namespaces of a deleted tenant come back by
+// restoring the tenant.
+func (s *NamespaceService) RestoreNamespace(ctx context.Context, tenantID, name string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.RestoreNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	// The parent can't be deleted between the check and the restore.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if !namespace.Deleted() {
+		return nil, ErrNamespaceNotDeleted
+	}
+	if err := checkRestorable(*namespace.DeletionTimestamp, s.retention); err != nil {
+		return nil, err
+	}
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+	if namespace.Parent != "" {
+		if err := s.checkParent(txCtx, tenantID, namespace.Parent); err != nil {
+			return nil, err
+		}
+	}
+
+	namespace, err = s.repo.RestoreNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace restored", "tenant_id", tenantID, "namespace", name)
+
+	s.reconcile(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// RenewNamespace extends the lease of an ephemeral namespace so that it
+// expires ttl from now.
+func (s *NamespaceService) RenewNamespace(ctx context.Context, tenantID, name string, ttl time.Duration) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.RenewNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiry)
+	}
+
+	// The namespace can't be deleted or its tenant archived meanwhile.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkNotArchived(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+	if namespace.ExpiresAt == nil {
+		return nil, ErrNotEphemeral
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC()
+	namespace.ExpiresAt = &expiresAt
+	if err := s.repo.UpdateNamespace(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace lease renewed",
+		"tenant_id", tenantID, "namespace", name, "expires_at", expiresAt)
+
+	s.reconcile(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// UpdateNamespace replaces the spec, isolation and parent of a namespace
+// with those of update. The namespace's other fields, like its template and
+// cluster, stay as they are. An update with a resource version fails with
+// ErrConflict unless the namespace is still at it.
+func (s *NamespaceService) UpdateNamespace(ctx context.Context, tenantID string, update *Namespace) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.UpdateNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", update.Name),
+	))
+	defer tracing.End(span, &err)
+
+	if err := update.NamespaceSpec.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+	if update.Isolation != nil {
+		if err := update.Isolation.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+		}
+	}
+
+	// The checks and the write hold the tenants' and namespaces' write
+	// locks, so concurrent changes can't all pass the quota check.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, update.Name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+	if update.ResourceVersion != "" && update.ResourceVersion != namespace.ResourceVersion {
+		return nil, ErrConflict
+	}
+	if update.Parent != "" && update.Parent != namespace.Parent {
+		if err := s.checkParent(txCtx, tenantID, update.Parent); err != nil {
+			return nil, err
+		}
+		hierarchy, err := s.hierarchy(txCtx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if hierarchy.WouldCycle(update.Name, update.Parent) {
+			return nil, fmt.Errorf("%w: %s is %s or one of its descendants", ErrNamespaceCycle, update.Parent, update.Name)
+		}
+	}
+
+	namespace.NamespaceSpec = update.NamespaceSpec
+	namespace.Isolation = update.Isolation
+	namespace.Parent = update.Parent
+	if err := s.checkQuota(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateNamespace(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace updated", "tenant_id", tenantID, "namespace", namespace.Name)
+
+	s.reconcileSubtree(ctx, tenantID, namespace.Name)
+	s.readConditions(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// PatchNamespace applies a merge patch or JSON patch to a namespace. Only
+// the fields UpdateNamespace replaces may change, and the patched namespace
+// is validated as an update is. The patch is reapplied if the namespace
+// changes while it is being applied, unless it carries a precondition: a
+// resource version given with the patch, or one the patch sets or tests.
+func (s *NamespaceService) PatchNamespace(ctx context.Context, tenantID, name string, patch Patch) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.PatchNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+		attribute.String("naas.patch.type", string(patch.Type)),
+	))
+	defer tracing.End(span, &err)
+
+	return retryPatch(func() (*Namespace, error) {
+		current, err := s.repo.GetNamespace(ctx, tenantID, name)
+		if err != nil {
+			return nil, err
+		}
+		if current.Deleted() {
+			return nil, ErrNamespaceDeleted
+		}
+
+		var patched Namespace
+		if err := applyPatch(current, patch, &patched); err != nil {
+			return nil, err
+		}
+		want := *current
+		want.NamespaceSpec = patched.NamespaceSpec
+		want.Isolation = patched.Isolation
+		want.Parent = patched.Parent
+		want.ResourceVersion = patched.ResourceVersion
+		if err := checkImmutable(want, patched); err != nil {
+			return nil, err
+		}
+		if err := checkPrecondition(patch, current.ResourceVersion, patched.ResourceVersion); err != nil {
+			return nil, err
+		}
+
+		return s.UpdateNamespace(ctx, tenantID, &patched)
+	})
+}
+
+// SetIsolation replaces the network isolation overrides of a namespace. A
+// nil isolation restores the default policies.
+func (s *NamespaceService) SetIsolation(ctx context.Context, tenantID, name string, isolation *NetworkIsolation) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.SetIsolation", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if isolation != nil {
+		if err := isolation.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+		}
+	}
+
+	// The namespace can't be deleted or its tenant frozen meanwhile.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+
+	namespace.Isolation = isolation
+	if err := s.repo.UpdateNamespace(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace isolation updated", "tenant_id", tenantID, "namespace", name)
+
+	s.reconcile(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// SetParent moves a namespace, along with its descendants, under another
+// namespace of the tenant. An empty parent makes it a root.
+func (s *NamespaceService) SetParent(ctx context.Context, tenantID, name, parent string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.SetParent", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	// The checks and the write hold the tenants' and namespaces' write
+	// locks, so concurrent changes can't all pass the quota check.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+	if parent != "" {
+		if err := s.checkParent(txCtx, tenantID, parent); err != nil {
+			return nil, err
+		}
+		hierarchy, err := s.hierarchy(txCtx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if hierarchy.WouldCycle(name, parent) {
+			return nil, fmt.Errorf("%w: %s is %s or one of its descendants", ErrNamespaceCycle, parent, name)
+		}
+	}
+
+	namespace.Parent = parent
+	if err := s.checkQuota(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateNamespace(txCtx, tenantID, namespace); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace parent changed", "tenant_id", tenantID, "namespace", name, "parent", parent)
+
+	s.reconcileSubtree(ctx, tenantID, name)
+	s.readConditions(ctx, tenantID, namespace)
+	return namespace, nil
+}
+
+// TransferNamespace moves a namespace to another tenant, optionally under
+// parent there. Parents don't move along, so the namespace keeps the spec
+// it inherited as its own; namespaces with children can't be moved at all.
+// The namespace is taken over by the target tenant in the cluster, its
+// access following the new tenant's members, and the move is recorded in
+// its transfers.
+func (s *NamespaceService) TransferNamespace(ctx context.Context, tenantID, name, target, parent string) (_ *Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.TransferNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+		attribute.String("naas.transfer.to", target),
+	))
+	defer tracing.End(span, &err)
+
+	if target == "" {
+		return nil, fmt.Errorf("%w: target tenant is required", ErrInvalidTransfer)
+	}
+	if target == tenantID {
+		return nil, fmt.Errorf("%w: namespace already belongs to tenant %s", ErrInvalidTransfer, target)
+	}
+	// The namespace is held to the naming of new ones in the target tenant.
+	if err := ValidateNamespaceName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+
+	// The checks and the move hold the tenants' and namespaces' write
+	// locks, so the target tenant can't change or fill its quota meanwhile.
+	txCtx, tx := Begin(ctx, s.tenants, s.repo)
+	defer tx.Rollback()
+
+	if err := s.checkWritable(txCtx, tenantID); err != nil {
+		return nil, err
+	}
+	if _, err := s.tenants.GetTenant(txCtx, target); errors.Is(err, ErrTenantNotFound) {
+		return nil, fmt.Errorf("%w: %w: %s", ErrInvalidTransfer, err, target)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := s.checkWritable(txCtx, target); err != nil {
+		return nil, fmt.Errorf("target tenant %s: %w", target, err)
+	}
+
+	namespace, err := s.repo.GetNamespace(txCtx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+	hierarchy, err := s.hierarchy(txCtx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if children := hierarchy.Children(name); len(children) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrHasChildren, children[0].Name)
+	}
+
+	if namespace.Parent != "" {
+		namespace.NamespaceSpec = hierarchy.EffectiveSpec(name)
+	}
+	namespace.Parent = parent
+	if parent != "" {
+		if err := s.checkParent(txCtx, target, parent); err != nil {
+			return nil, err
+		}
+	}
+	if err := namespace.NamespaceSpec.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+	if err := s.checkQuota(txCtx, target, namespace); err != nil {
+		return nil, fmt.Errorf("target tenant %s: %w", target, err)
+	}
+	if err := s.checkNameFree(txCtx, namespace, tenantID, target); err != nil {
+		return nil, err
+	}
+	namespace.Transfers = append(append([]Transfer(nil), namespace.Transfers...), Transfer{
+		From: tenantID,
+		To:   target,
+		At:   time.Now().UTC(),
+	})
+
+	if err := s.repo.TransferNamespace(txCtx, tenantID, target, namespace); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "namespace transferred", "tenant_id", tenantID, "namespace", name, "to", target)
+
+	s.reconcile(ctx, target, namespace)
+	// Updates the manifests of the tenant the namespace left.
+	_ = s.reconciler.ReconcileTenant(ctx, tenantID)
+	return namespace, nil
+}
+
+// CloneNamespace creates a namespace like an existing one under the name
+// given with overrides, in the target tenant or, when target is empty, the
+// namespace's own. The clone gets the namespace's spec, template, placement
+// and isolation; the spec and parameters of overrides are merged on top and
+// any of its other fields that are set replace the namespace's. The clone
+// is placed anew unless overrides name a cluster. Values the namespace got
+// from its template are part of its spec and copied as they are. A clone in
+// another tenant can't share the namespace's parent, so it gets the spec
+// inherited from it as its own.
+func (s *NamespaceService) CloneNamespace(ctx context.Context, tenantID, name, target string, overrides *Namespace) (_ *Namespace, err error) {
+	if target == "" {
+		target = tenantID
+	}
+
+	ctx, span := tracer.Start(ctx, "NamespaceService.CloneNamespace", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+		attribute.String("naas.clone.tenant", target),
+		attribute.String("naas.clone.name", overrides.Name),
+	))
+	defer tracing.End(span, &err)
+
+	if overrides.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSpec)
+	}
+
+	source, err := s.repo.GetNamespace(ctx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	if source.Deleted() {
+		return nil, ErrNamespaceDeleted
+	}
+
+	spec := source.NamespaceSpec
+	parent := source.Parent
+	if target != tenantID && parent != "" {
+		hierarchy, err := s.hierarchy(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		spec = hierarchy.EffectiveSpec(name)
+		parent = ""
+	}
+
+	clone := &Namespace{
+		Name:          overrides.Name,
+		Parent:        parent,
+		Template:      source.Template,
+		Parameters:    mergeParameters(source.Parameters, overrides.Parameters),
+		NamespaceSpec: spec.Merge(overrides.NamespaceSpec),
+		Placement:     source.Placement,
+		Isolation:     source.Isolation,
+		Cluster:       overrides.Cluster,
+		ExpiresAt:     overrides.ExpiresAt,
+	}
+	if overrides.Parent != "" {
+		clone.Parent = overrides.Parent
+	}
+	if overrides.Template != "" {
+		clone.Template = overrides.Template
+	}
+	if overrides.Placement != nil {
+		clone.Placement = overrides.Placement
+	}
+	if overrides.Isolation != nil {
+		clone.Isolation = overrides.Isolation
+	}
+
+	if err := s.CreateNamespace(ctx, target, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
 }
 
-func NewNamespaceService(repo *NamespaceRepository) *NamespaceService {
-	return &NamespaceService{repo: repo}
+func mergeParameters(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
 }
 
-func (s *NamespaceService) CreateNamespace(tenantID string, namespace *Namespace) error {
-	return s.repo.CreateNamespace(tenantID, namespace)
+// GetSubtree returns a namespace followed by its descendants, depth first.
+// Deleted namespaces are left out unless IncludeDeleted is given.
+func (s *NamespaceService) GetSubtree(ctx context.Context, tenantID, name string, opts ...ListOption) (_ []Namespace, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.GetSubtree", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	namespaces, err := s.repo.GetAllNamespaces(ctx, tenantID, opts...)
+	if errors.Is(err, ErrNoTenantNamespaces) {
+		return nil, ErrNamespaceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	subtree := NewHierarchy(namespaces).Subtree(name)
+	if subtree == nil {
+		return nil, ErrNamespaceNotFound
+	}
+	return subtree, nil
+}
+
+// GetBindings returns the role bindings in effect in a namespace, from both
+// the tenant's membership and the namespace's spec.
+func (s *NamespaceService) GetBindings(ctx context.Context, tenantID, name string) (_ []Binding, err error) {
+	ctx, span := tracer.Start(ctx, "NamespaceService.GetBindings", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.namespace.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	namespace, err := s.repo.GetNamespace(ctx, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := s.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, ErrTenantNotFound) {
+		tenant = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if namespace.Parent != "" {
+		hierarchy, err := s.hierarchy(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		namespace.NamespaceSpec = hierarchy.EffectiveSpec(name)
+	}
+
+	bindings := EffectiveBindings(tenant, namespace)
+	if bindings == nil {
+		bindings = []Binding{}
+	}
+	return bindings, nil
+}
+
+func (s *NamespaceService) CountNamespaces(ctx context.Context) (map[string]int, error) {
+	return s.repo.CountNamespaces(ctx)
+}
+
+// QuotaUsage returns the quota used by each tenant's namespaces per
+// resource, counted as their tenant's quota counts it.
+func (s *NamespaceService) QuotaUsage(ctx context.Context) (map[string]map[string]resource.Quantity, error) {
+	byTenant, err := s.repo.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]map[string]resource.Quantity, len(byTenant))
+	for tenantID, namespaces := range byTenant {
+		usage[tenantID] = NewHierarchy(namespaces).QuotaUsage()
+	}
+	return usage, nil
+}
+
+// place sets the cluster of a new namespace: the one it names, provided it
+// matches the placement and has room, or else the one the placement
+// schedules it onto. Without registered clusters namespaces stay on the
+// cluster naas runs with.
+func (s *NamespaceService) place(ctx context.Context, tenantID string, namespace *Namespace) error {
+	clusters, err := listClusters(ctx, s.clusters, s.repo)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		switch {
+		case namespace.Cluster != "":
+			return fmt.Errorf("%w: %w: %s", ErrInvalidSpec, ErrClusterNotFound, namespace.Cluster)
+		case namespace.Placement != nil:
+			return fmt.Errorf("%w: no clusters are registered", ErrNoCluster)
+		}
+		return nil
+	}
+
+	if namespace.Cluster != "" {
+		for _, c := range clusters {
+			if c.Name != namespace.Cluster {
+				continue
+			}
+			if !namespace.Placement.Matches(c) {
+				return fmt.Errorf("%w: cluster %s doesn't match the placement", ErrNoCluster, c.Name)
+			}
+			if c.Full() {
+				return fmt.Errorf("%w: cluster %s is full", ErrNoCluster, c.Name)
+			}
+			return nil
+		}
+		return fmt.Errorf("%w: %w: %s", ErrInvalidSpec, ErrClusterNotFound, namespace.Cluster)
+	}
+
+	_, tenantAllocated, err := allocation(ctx, s.repo, tenantID)
+	if err != nil {
+		return err
+	}
+	cluster, ok := Schedule(clusters, namespace.Placement, tenantAllocated)
+	if !ok {
+		return ErrNoCluster
+	}
+	namespace.Cluster = cluster.Name
+	return nil
 }
 
-func (s *NamespaceService) GetAllNamespaces(tenantID string) ([]Namespace, error) {
-	return s.repo.GetAllNamespaces(tenantID)
+// checkNameFree rejects namespace if a tenant other than owners has one of
+// the same name on its cluster, which can hold only one of each name.
+// Deleted namespaces count until they are purged from the cluster.
+func (s *NamespaceService) checkNameFree(ctx context.Context, namespace *Namespace, owners ...string) error {
+	all, err := s.repo.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return err
+	}
+	for tenantID, namespaces := range all {
+		if slices.Contains(owners, tenantID) {
+			continue
+		}
+		for _, ns := range namespaces {
+			if ns.Name == namespace.Name && ns.Cluster == namespace.Cluster {
+				return fmt.Errorf("%w in another tenant on the same cluster", ErrNamespaceExists)
+			}
+		}
+	}
+	return nil
+}
+
+// checkParent rejects parents that don't exist in the tenant or are deleted.
+func (s *NamespaceService) checkParent(ctx context.Context, tenantID, parent string) error {
+	ns, err := s.repo.GetNamespace(ctx, tenantID, parent)
+	if errors.Is(err, ErrNamespaceNotFound) {
+		return fmt.Errorf("%w: %s", ErrParentNotFound, parent)
+	}
+	if err != nil {
+		return err
+	}
+	if ns.Deleted() {
+		return fmt.Errorf("parent %s: %w", parent, ErrNamespaceDeleted)
+	}
+	return nil
+}
+
+// checkQuota rejects namespace, new to the tenant or changed, if it takes
+// the tenant's namespaces over the tenant's quota on a resource. Namespaces
+// inheriting its limits count with it, and deleted namespaces count until
+// they are purged.
+func (s *NamespaceService) checkQuota(ctx context.Context, tenantID string, namespace *Namespace) error {
+	tenant, err := s.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, ErrTenantNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(tenant.Quota) == 0 {
+		return nil
+	}
+
+	hierarchy, err := s.hierarchy(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	before := hierarchy.QuotaUsage()
+	hierarchy[namespace.Name] = *namespace
+	if exceeded := tenant.ExceedsQuota(hierarchy.QuotaUsage(), before); len(exceeded) > 0 {
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, strings.Join(exceeded, ", "))
+	}
+	return nil
+}
+
+// hierarchy returns the tree of the tenant's namespaces, deleted ones
+// included.
+func (s *NamespaceService) hierarchy(ctx context.Context, tenantID string) (Hierarchy, error) {
+	namespaces, err := s.repo.GetAllNamespaces(ctx, tenantID, IncludeDeleted(true))
+	if err != nil && !errors.Is(err, ErrNoTenantNamespaces) {
+		return nil, err
+	}
+	return NewHierarchy(namespaces), nil
+}
+
+// reconcile applies a namespace to its cluster after a write. Failing to
+// doesn't fail the write, which is kept and retried on resync: the
+// reconciler logs the failure and records it in the namespace's Synced
+// condition, which is copied into namespace for the response.
+func (s *NamespaceService) reconcile(ctx context.Context, tenantID string, namespace *Namespace) {
+	_ = s.reconciler.ReconcileNamespace(ctx, tenantID, namespace.Name)
+	s.readConditions(ctx, tenantID, namespace)
+}
+
+// readConditions copies the stored conditions of a namespace into
+// namespace.
+func (s *NamespaceService) readConditions(ctx context.Context, tenantID string, namespace *Namespace) {
+	if stored, err := s.repo.GetNamespace(ctx, tenantID, namespace.Name); err == nil {
+		namespace.Conditions = stored.Conditions
+	}
+}
+
+// reconcileSubtree reconciles a namespace and its descendants, whose
+// inherited spec depends on it.
+func (s *NamespaceService) reconcileSubtree(ctx context.Context, tenantID, name string) {
+	hierarchy, err := s.hierarchy(ctx, tenantID)
+	if err != nil {
+		return
+	}
+	for _, ns := range hierarchy.Subtree(name) {
+		// Failures are recorded on each namespace, as in reconcile
+		_ = s.reconciler.ReconcileNamespace(ctx, tenantID, ns.Name)
+	}
+}
+
+// checkWritable rejects changes to the namespaces of suspended, archived and
+// deleted tenants. Namespaces of tenants without a record are left
+// unrestricted.
+func (s *NamespaceService) checkWritable(ctx context.Context, tenantID string) error {
+	tenant, err := s.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, ErrTenantNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if tenant.Deleted() {
+		return ErrTenantDeleted
+	}
+	switch tenant.Status {
+	case TenantSuspended:
+		return ErrTenantSuspended
+	case TenantArchived:
+		return ErrTenantArchived
+	}
+	return nil
 }
 
-func (s *NamespaceService) GetNamespace(tenantID string, name string) (*Namespace, error) {
-	return s.repo.GetNamespace(tenantID, name)
+// checkNotArchived rejects changes to the namespaces of archived tenants,
+// which are read-only.
+func (s *NamespaceService) checkNotArchived(ctx context.Context, tenantID string) error {
+	tenant, err := s.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, ErrTenantNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tenant.Status == TenantArchived {
+		return ErrTenantArchived
+	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	. "naas/domain"
+	. "naas/repositories"
+)
+
+// maxPatchAttempts bounds how often a patch is reapplied when the object
+// changes between reading and writing it.
+const maxPatchAttempts = 5
+
+// retryPatch calls apply until it doesn't fail with ErrConflict, at most
+// maxPatchAttempts times. Each attempt rereads the object, so a patch with a
+// precondition keeps failing once the object has moved on.
+func retryPatch[T any](apply func() (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for i := 0; i < maxPatchAttempts; i++ {
+		result, err = apply()
+		if !errors.Is(err, ErrConflict) {
+			break
+		}
+	}
+	return result, err
+}
+
+// applyPatch applies patch to current and decodes the result into patched.
+// The patched document must still describe the object: unknown fields are
+// rejected.
+func applyPatch(current any, patch Patch, patched any) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	doc, err = patch.Apply(doc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(patched); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return nil
+}
+
+// checkImmutable returns ErrInvalidPatch naming the fields of patched that
+// differ from want, which is the current object with the mutable fields
+// taken from patched.
+func checkImmutable(want, patched any) error {
+	changed, err := changedFields(want, patched)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: %s cannot be patched", ErrInvalidPatch, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// changedFields returns the top-level JSON fields that differ between a and
+// b, sorted.
+func changedFields(a, b any) ([]string, error) {
+	fieldsA, err := jsonFields(a)
+	if err != nil {
+		return nil, err
+	}
+	fieldsB, err := jsonFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, value := range fieldsA {
+		if other, ok := fieldsB[name]; !ok || !bytes.Equal(value, other) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range fieldsB {
+		if _, ok := fieldsA[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// checkPrecondition returns ErrConflict when the patch was written against
+// another version of the object than current, or changes its version.
+func checkPrecondition(patch Patch, current, patched string) error {
+	if patch.ResourceVersion != "" && patch.ResourceVersion != current {
+		return ErrConflict
+	}
+	if patched != current {
+		return ErrConflict
+	}
+	return nil
+}
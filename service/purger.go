@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"naas/logging"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+// Purger hard-deletes tenants and namespaces once their retention period
+// has passed. Expired records are terminating: their finalizers run first
+// and the record is removed only once none are left.
+type Purger struct {
+	tenants    *TenantRepository
+	namespaces *NamespaceRepository
+	finalizers *Finalizers
+	retention  time.Duration
+}
+
+func NewPurger(tenants *TenantRepository, namespaces *NamespaceRepository, finalizers *Finalizers, retention time.Duration) *Purger {
+	return &Purger{tenants: tenants, namespaces: namespaces, finalizers: finalizers, retention: retention}
+}
+
+// Purge finalizes and removes the records deleted longer than the retention
+// period before now. Namespaces go first so that their tenants' finalizers
+// can wait for them. Records whose finalizers fail are kept, to be retried
+// on the next run.
+func (p *Purger) Purge(ctx context.Context, now time.Time) (err error) {
+	ctx, span := tracer.Start(ctx, "Purger.Purge")
+	defer tracing.End(span, &err)
+
+	logger := logging.FromContext(ctx)
+	var errs []error
+
+	all, err := p.namespaces.ListAllNamespaces(ctx, IncludeDeleted(true))
+	if err != nil {
+		return err
+	}
+	for tenantID, namespaces := range all {
+		for _, ns := range namespaces {
+			if !ns.Deleted() || !p.expired(*ns.DeletionTimestamp, now) {
+				continue
+			}
+			if err := p.finalizers.finalizeNamespace(ctx, tenantID, &ns); err != nil {
+				errs = append(errs, fmt.Errorf("namespace %s/%s: %w", tenantID, ns.Name, err))
+				continue
+			}
+			if err := p.namespaces.PurgeNamespace(ctx, tenantID, ns.Name); err != nil {
+				errs = append(errs, fmt.Errorf("namespace %s/%s: %w", tenantID, ns.Name, err))
+				continue
+			}
+			logger.InfoContext(ctx, "namespace purged", "tenant_id", tenantID, "namespace", ns.Name)
+		}
+	}
+
+	tenants, err := p.tenants.ListTenants(ctx, IncludeDeleted(true))
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, tenant := range tenants {
+		if !tenant.Deleted() || !p.expired(*tenant.DeletionTimestamp, now) {
+			continue
+		}
+		if err := p.finalizers.finalizeTenant(ctx, &tenant); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+			continue
+		}
+		if err := p.tenants.PurgeTenant(ctx, tenant.ID); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+			continue
+		}
+		logger.InfoContext(ctx, "tenant purged", "tenant_id", tenant.ID)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run purges expired records every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Shutting down lets the purge under way complete.
+			if err := p.Purge(context.WithoutCancel(ctx), now); err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "purge incomplete", "error", err)
+			}
+		}
+	}
+}
+
+func (p *Purger) expired(deletedAt, now time.Time) bool {
+	return now.Sub(deletedAt) >= p.retention
+}
+
+// checkRestorable rejects restoring a record deleted at deletedAt once the
+// retention period has passed, even if it hasn't been purged yet.
+func checkRestorable(deletedAt time.Time, retention time.Duration) error {
+	if time.Since(deletedAt) >= retention {
+		return fmt.Errorf("%w: deleted at %s", ErrRetentionExpired, deletedAt.Format(time.RFC3339))
+	}
+	return nil
+}
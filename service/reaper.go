@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"naas/logging"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+// Reaper deletes ephemeral namespaces whose lease has expired, and warns
+// about the ones that are about to expire. Reaped namespaces go through the
+// regular soft delete, so they can be restored within the retention period.
+type Reaper struct {
+	namespaces    *NamespaceRepository
+	service       *NamespaceService
+	warningPeriod time.Duration
+
+	mtx sync.Mutex
+	// warned holds the expiry each namespace was last warned about, so that
+	// a renewed lease is warned about again.
+	warned map[string]time.Time
+}
+
+func NewReaper(namespaces *NamespaceRepository, service *NamespaceService, warningPeriod time.Duration) *Reaper {
+	return &Reaper{
+		namespaces:    namespaces,
+		service:       service,
+		warningPeriod: warningPeriod,
+		warned:        make(map[string]time.Time),
+	}
+}
+
+// Reap deletes the namespaces that expired by now.
+func (r *Reaper) Reap(ctx context.Context, now time.Time) (err error) {
+	ctx, span := tracer.Start(ctx, "Reaper.Reap")
+	defer tracing.End(span, &err)
+
+	all, err := r.namespaces.ListAllNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	logger := logging.FromContext(ctx)
+	seen := make(map[string]bool)
+	var errs []error
+	for tenantID, namespaces := range all {
+		for _, ns := range namespaces {
+			if ns.ExpiresAt == nil {
+				continue
+			}
+			key := tenantID + "/" + ns.Name
+			expiresAt := *ns.ExpiresAt
+
+			if !now.Before(expiresAt) {
+				_, err := r.service.DeleteNamespace(ctx, tenantID, ns.Name)
+				if errors.Is(err, ErrTenantArchived) {
+					// Archived tenants are read-only; their namespaces stay.
+					continue
+				}
+				if errors.Is(err, ErrHasChildren) {
+					// Reaped once its children are gone.
+					continue
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("namespace %s: %w", key, err))
+					continue
+				}
+				logger.InfoContext(ctx, "expired namespace deleted",
+					"tenant_id", tenantID, "namespace", ns.Name, "expires_at", expiresAt)
+				continue
+			}
+
+			seen[key] = true
+			if expiresAt.Sub(now) <= r.warningPeriod && !r.warned[key].Equal(expiresAt) {
+				r.warned[key] = expiresAt
+				logger.WarnContext(ctx, "namespace expires soon",
+					"tenant_id", tenantID, "namespace", ns.Name, "expires_at", expiresAt,
+					"remaining", expiresAt.Sub(now).Round(time.Second))
+			}
+		}
+	}
+	for key := range r.warned {
+		if !seen[key] {
+			delete(r.warned, key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run reaps expired namespaces every interval until ctx is cancelled.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Namespaces being reaped are reaped before shutting down.
+			if err := r.Reap(context.WithoutCancel(ctx), now); err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "reap incomplete", "error", err)
+			}
+		}
+	}
+}
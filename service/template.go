@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	. "naas/domain"
+	"naas/logging"
+	. "naas/repositories"
+	"naas/tracing"
+)
+
+type TemplateService struct {
+	repo *TemplateRepository
+}
+
+func NewTemplateService(repo *TemplateRepository) *TemplateService {
+	return &TemplateService{repo: repo}
+}
+
+// CreateTemplate stores a template. template.TenantID selects its scope;
+// empty makes it global.
+func (s *TemplateService) CreateTemplate(ctx context.Context, template *Template) (err error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.CreateTemplate", trace.WithAttributes(
+		attribute.String("naas.tenant.id", template.TenantID),
+		attribute.String("naas.template.name", template.Name),
+	))
+	defer tracing.End(span, &err)
+
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	if err := s.repo.CreateTemplate(ctx, template); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "template created", "tenant_id", template.TenantID, "template", template.Name)
+	return nil
+}
+
+func (s *TemplateService) GetTemplate(ctx context.Context, tenantID, name string) (_ *Template, err error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.GetTemplate", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.template.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	return s.repo.GetTemplate(ctx, tenantID, name)
+}
+
+func (s *TemplateService) ListTemplates(ctx context.Context, tenantID string) (_ []Template, err error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.ListTemplates", trace.WithAttributes(attribute.String("naas.tenant.id", tenantID)))
+	defer tracing.End(span, &err)
+
+	return s.repo.ListTemplates(ctx, tenantID)
+}
+
+// DeleteTemplate removes a template. Namespaces created from it keep their
+// configuration.
+func (s *TemplateService) DeleteTemplate(ctx context.Context, tenantID, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "TemplateService.DeleteTemplate", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.template.name", name),
+	))
+	defer tracing.End(span, &err)
+
+	if err := s.repo.DeleteTemplate(ctx, tenantID, name); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "template deleted", "tenant_id", tenantID, "template", name)
+	return nil
+}
+
+// Instantiate renders the template namespace refers to, looking it up in
+// the tenant's templates first and the global ones second.
+func (s *TemplateService) Instantiate(ctx context.Context, tenantID string, namespace *Namespace) (_ *NamespaceSpec, err error) {
+	if s == nil {
+		return nil, ErrTemplateNotFound
+	}
+
+	ctx, span := tracer.Start(ctx, "TemplateService.Instantiate", trace.WithAttributes(
+		attribute.String("naas.tenant.id", tenantID),
+		attribute.String("naas.template.name", namespace.Template),
+	))
+	defer tracing.End(span, &err)
+
+	template, err := s.repo.GetTemplate(ctx, tenantID, namespace.Template)
+	if errors.Is(err, ErrTemplateNotFound) {
+		template, err = s.repo.GetTemplate(ctx, "", namespace.Template)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := template.Instantiate(tenantID, namespace.Name, namespace.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("%w: template %s: %v", ErrInvalidSpec, template.Name, err)
+	}
+	return spec, nil
+}
@@ -1,26 +1,380 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	. "naas/domain"
+	"naas/logging"
+	"naas/reconciler"
 	. "naas/repositories"
+	"naas/tracing"
 )
 
 type TenantService struct {
-	repo *TenantRepository
+	repo       *TenantRepository
+	namespaces *NamespaceRepository
+	reconciler *reconciler.Reconciler
+	finalizers *Finalizers
+	retention  time.Duration
+}
+
+func NewTenantService(repo *TenantRepository, namespaces *NamespaceRepository, reconciler *reconciler.Reconciler, finalizers *Finalizers, retention time.Duration) *TenantService {
+	return &TenantService{repo: repo, namespaces: namespaces, reconciler: reconciler, finalizers: finalizers, retention: retention}
+}
+
+func (s *TenantService) CreateTenant(ctx context.Context, tenant *Tenant) (err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.CreateTenant", trace.WithAttributes(attribute.String("naas.tenant.id", tenant.ID)))
+	defer tracing.End(span, &err)
+
+	if err := ValidateTenantID(tenant.ID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTenant, err)
+	}
+	if err := ValidateMembers(tenant.Members); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMember, err)
+	}
+	if err := ValidateQuota(tenant.Quota); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidQuota, err)
+	}
+
+	tenant.Status = TenantActive
+	tenant.StatusReason = ""
+	tenant.DeletionTimestamp = nil
+	tenant.Finalizers = s.finalizers.tenantNames()
+
+	if err := s.repo.CreateTenant(ctx, tenant); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant created", "tenant_id", tenant.ID)
+	return nil
+}
+
+func (s *TenantService) GetTenant(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.GetTenant", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	return s.repo.GetTenant(ctx, id)
+}
+
+// RenderManifests renders the manifests of every namespace of a tenant, as
+// they are committed to a GitOps repository.
+func (s *TenantService) RenderManifests(ctx context.Context, id string) (_ []reconciler.Manifest, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.RenderManifests", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	// Namespaces may exist for tenants without a record.
+	_, err = s.repo.GetTenant(ctx, id)
+	if errors.Is(err, ErrTenantNotFound) {
+		if _, err := s.namespaces.GetAllNamespaces(ctx, id, IncludeDeleted(true)); err != nil {
+			return nil, ErrTenantNotFound
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.reconciler.RenderTenant(ctx, id)
+}
+
+func (s *TenantService) ListTenants(ctx context.Context, opts ...ListOption) (_ []Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.ListTenants")
+	defer tracing.End(span, &err)
+
+	return s.repo.ListTenants(ctx, opts...)
+}
+
+// TransitionTenant moves a tenant to status and applies the consequences to
+// its namespaces in the cluster. reason is recorded for suspensions.
+func (s *TenantService) TransitionTenant(ctx context.Context, id string, status TenantStatus, reason string) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.TransitionTenant", trace.WithAttributes(
+		attribute.String("naas.tenant.id", id),
+		attribute.String("naas.tenant.status", string(status)),
+	))
+	defer tracing.End(span, &err)
+
+	// The status is checked and changed without another write in between.
+	txCtx, tx := Begin(ctx, s.repo, s.namespaces)
+	defer tx.Rollback()
+
+	tenant, err := s.repo.GetTenant(txCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Deleted() {
+		return nil, ErrTenantDeleted
+	}
+	if tenant.Status == TenantArchived {
+		return nil, ErrTenantArchived
+	}
+	if !tenant.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, tenant.Status, status)
+	}
+
+	previous := tenant.Status
+	tenant.Status = status
+	tenant.StatusReason = ""
+	if status != TenantActive {
+		tenant.StatusReason = reason
+	}
+	if err := s.repo.UpdateTenant(txCtx, tenant); err != nil {
+		return nil, err
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant status changed",
+		"tenant_id", id, "from", previous, "to", status, "reason", reason)
+
+	// Failures are retried by the periodic resync; the new status is
+	// already authoritative for the API.
+	_ = s.reconciler.ReconcileTenant(ctx, id)
+
+	return tenant, nil
+}
+
+// UpdateTenant replaces the name, members and quota of a tenant. Its
+// status has its own transitions and is left as it is. An update with a
+// resource version fails with ErrConflict unless the tenant is still at it.
+func (s *TenantService) UpdateTenant(ctx context.Context, update *Tenant) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.UpdateTenant", trace.WithAttributes(attribute.String("naas.tenant.id", update.ID)))
+	defer tracing.End(span, &err)
+
+	if err := ValidateMembers(update.Members); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMember, err)
+	}
+	if err := ValidateQuota(update.Quota); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuota, err)
+	}
+
+	tenant, err := s.membershipTenant(ctx, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	if update.ResourceVersion != "" && update.ResourceVersion != tenant.ResourceVersion {
+		return nil, ErrConflict
+	}
+
+	tenant.Name = update.Name
+	tenant.Members = append([]Member(nil), update.Members...)
+	tenant.Quota = update.Quota
+	if err := s.repo.UpdateTenant(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant updated", "tenant_id", tenant.ID)
+
+	_ = s.reconciler.ReconcileTenant(ctx, tenant.ID)
+	return tenant, nil
+}
+
+// PatchTenant applies a merge patch or JSON patch to a tenant. Only its
+// name, members and quota may change, and they are validated as an
+// update's are. The patch is reapplied if the tenant changes while it is
+// being applied, unless it carries a precondition: a resource version given
+// with the patch, or one the patch sets or tests.
+func (s *TenantService) PatchTenant(ctx context.Context, id string, patch Patch) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.PatchTenant", trace.WithAttributes(
+		attribute.String("naas.tenant.id", id),
+		attribute.String("naas.patch.type", string(patch.Type)),
+	))
+	defer tracing.End(span, &err)
+
+	return retryPatch(func() (*Tenant, error) {
+		current, err := s.membershipTenant(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		var patched Tenant
+		if err := applyPatch(current, patch, &patched); err != nil {
+			return nil, err
+		}
+		want := *current
+		want.Name = patched.Name
+		want.Members = patched.Members
+		want.Quota = patched.Quota
+		want.ResourceVersion = patched.ResourceVersion
+		if err := checkImmutable(want, patched); err != nil {
+			return nil, err
+		}
+		if err := checkPrecondition(patch, current.ResourceVersion, patched.ResourceVersion); err != nil {
+			return nil, err
+		}
+
+		return s.UpdateTenant(ctx, &patched)
+	})
+}
+
+// DeleteTenant soft-deletes a tenant together with its namespaces, which
+// are frozen in the cluster until the tenant is restored or purged.
+func (s *TenantService) DeleteTenant(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.DeleteTenant", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	// The tenant and its namespaces are deleted together or not at all.
+	txCtx, tx := Begin(ctx, s.repo, s.namespaces)
+	defer tx.Rollback()
+
+	current, err := s.repo.GetTenant(txCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == TenantArchived {
+		return nil, ErrTenantArchived
+	}
+
+	tenant, err := s.repo.DeleteTenant(txCtx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := s.namespaces.GetAllNamespaces(txCtx, id)
+	if err != nil && !errors.Is(err, ErrNoTenantNamespaces) {
+		return nil, err
+	}
+	for _, ns := range namespaces {
+		if _, err := s.namespaces.DeleteNamespace(txCtx, id, ns.Name, *tenant.DeletionTimestamp); err != nil {
+			return nil, err
+		}
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant deleted", "tenant_id", id, "namespaces", len(namespaces))
+
+	_ = s.reconciler.ReconcileTenant(ctx, id)
+	return tenant, nil
+}
+
+// RestoreTenant undoes DeleteTenant within the retention period. Namespaces
+// deleted along with the tenant are restored too; those deleted before it
+// stay deleted.
+func (s *TenantService) RestoreTenant(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.RestoreTenant", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	txCtx, tx := Begin(ctx, s.repo, s.namespaces)
+	defer tx.Rollback()
+
+	tenant, err := s.repo.GetTenant(txCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !tenant.Deleted() {
+		return nil, ErrTenantNotDeleted
+	}
+	if err := checkRestorable(*tenant.DeletionTimestamp, s.retention); err != nil {
+		return nil, err
+	}
+	deletedAt := *tenant.DeletionTimestamp
+
+	tenant, err = s.repo.RestoreTenant(txCtx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := s.namespaces.GetAllNamespaces(txCtx, id, IncludeDeleted(true))
+	if err != nil && !errors.Is(err, ErrNoTenantNamespaces) {
+		return nil, err
+	}
+	for _, ns := range namespaces {
+		if ns.Deleted() && ns.DeletionTimestamp.Equal(deletedAt) {
+			if _, err := s.namespaces.RestoreNamespace(txCtx, id, ns.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	tx.Commit()
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant restored", "tenant_id", id)
+
+	_ = s.reconciler.ReconcileTenant(ctx, id)
+	return tenant, nil
 }
 
-func NewTenantService(repo *TenantRepository) *TenantService {
-	return &TenantService{repo: repo}
+// SetMember adds a member to a tenant, or changes the role of an existing
+// one, and syncs the access of the tenant's namespaces.
+func (s *TenantService) SetMember(ctx context.Context, id string, member Member) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.SetMember", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	if err := ValidateMembers([]Member{member}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMember, err)
+	}
+
+	tenant, err := s.membershipTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored record shares its members with tenant, so they are copied
+	// rather than changed in place.
+	members := append([]Member{}, tenant.Members...)
+	if i := memberIndex(members, member.User); i < 0 {
+		members = append(members, member)
+	} else {
+		members[i] = member
+	}
+	tenant.Members = members
+	if err := s.repo.UpdateTenant(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant member set", "tenant_id", id, "user", member.User, "role", member.Role)
+
+	_ = s.reconciler.ReconcileTenant(ctx, id)
+	return tenant, nil
 }
 
-func (s *TenantService) CreateTenant(tenant *Tenant) error {
-	return s.repo.CreateTenant(tenant)
+// RemoveMember revokes a member's access to the namespaces of a tenant.
+func (s *TenantService) RemoveMember(ctx context.Context, id, user string) (_ *Tenant, err error) {
+	ctx, span := tracer.Start(ctx, "TenantService.RemoveMember", trace.WithAttributes(attribute.String("naas.tenant.id", id)))
+	defer tracing.End(span, &err)
+
+	tenant, err := s.membershipTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	i := memberIndex(tenant.Members, user)
+	if i < 0 {
+		return nil, ErrMemberNotFound
+	}
+	members := append([]Member{}, tenant.Members[:i]...)
+	tenant.Members = append(members, tenant.Members[i+1:]...)
+	if err := s.repo.UpdateTenant(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "tenant member removed", "tenant_id", id, "user", user)
+
+	_ = s.reconciler.ReconcileTenant(ctx, id)
+	return tenant, nil
 }
 
-func (s *TenantService) GetTenant(id string) (*Tenant, error) {
-	return s.repo.GetTenant(id)
+// membershipTenant returns a tenant whose membership may change: deleted
+// and archived tenants are left as they are.
+func (s *TenantService) membershipTenant(ctx context.Context, id string) (*Tenant, error) {
+	tenant, err := s.repo.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Deleted() {
+		return nil, ErrTenantDeleted
+	}
+	if tenant.Status == TenantArchived {
+		return nil, ErrTenantArchived
+	}
+	return tenant, nil
 }
 
-func (s *TenantService) ListTenants() ([]Tenant, error) {
-	return s.repo.ListTenants()
+func memberIndex(members []Member, user string) int {
+	for i, m := range members {
+		if m.User == user {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,80 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"naas/config"
+	"naas/handlers"
+	"naas/repositories"
+	"naas/service"
+	"naas/tracing"
+)
+
+func TestSpansPropagateFromRequestToRepository(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), config.Tracing{Exporter: config.TracingNone})
+	assert.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	repo := repositories.NewTenantRepository()
+	handler := handlers.NewTenantHandler(service.NewTenantService(repo, repositories.NewNamespaceRepository(), nil, nil, time.Hour))
+
+	router := gin.New()
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	router.GET("/tenants/:id", handler.GetTenant)
+
+	req, err := http.NewRequest(http.MethodGet, "/tenants/missing", nil)
+	assert.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		byName[span.Name()] = span
+	}
+
+	server := byName["/tenants/:id"]
+	svc := byName["TenantService.GetTenant"]
+	repoSpan := byName["TenantRepository.GetTenant"]
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), svc.Parent().SpanID())
+	assert.Equal(t, svc.SpanContext().SpanID(), repoSpan.Parent().SpanID())
+	assert.Equal(t, codes.Error, repoSpan.Status().Code)
+	assert.Equal(t, "tenant not found", repoSpan.Status().Description)
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "ok")
+	var err error
+	tracing.End(span, &err)
+
+	_, span = tracer.Start(context.Background(), "failed")
+	err = errors.New("boom")
+	tracing.End(span, &err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Len(t, spans[1].Events(), 1)
+}